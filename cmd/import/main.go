@@ -0,0 +1,109 @@
+// Command import loads request logs from JSONL or CSV files into the monitor's
+// database, the way POST /api/requests/import does.
+//
+//	go run ./cmd/import [-format jsonl|csv] [-detect] [-batch 500] [-tz Europe/Zagreb] file...
+//
+// A file named - is read from standard input. The database is DB_PATH, or
+// ./api_monitor.db, unless -db is given. Rejected lines are listed on
+// standard error and make the command exit with status 1.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+	"treblle_project/internal/database"
+	"treblle_project/internal/detector"
+	"treblle_project/internal/importer"
+	"treblle_project/internal/repository"
+)
+
+func main() {
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = "./api_monitor.db"
+	}
+
+	flag.StringVar(&dbPath, "db", dbPath, "database path")
+	format := flag.String("format", "", "file format, jsonl or csv (default: from the file extension)")
+	detect := flag.Bool("detect", false, "run problem detection on the imported rows")
+	batchSize := flag.Int("batch", importer.DefaultBatchSize, "rows inserted per transaction")
+	tz := flag.String("tz", "", "IANA time zone of created_at values without an offset (default: local time)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := importer.Options{BatchSize: *batchSize}
+	if *detect {
+		opts.Detector = detector.Default
+	}
+	if *tz != "" {
+		loc, err := time.LoadLocation(*tz)
+		if err != nil {
+			log.Fatalf("Invalid time zone %q: %v", *tz, err)
+		}
+		opts.Location = loc
+	}
+
+	db, err := database.New(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.RunMigrations(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	imp := importer.NewImporter(repository.NewRequestRepository(db))
+	failed := false
+	for _, path := range flag.Args() {
+		opts.Format = *format
+		if opts.Format == "" {
+			opts.Format = importer.FormatOf(path, "")
+		}
+		if opts.Format == "" {
+			log.Fatalf("%s: can't tell the format from the file name, set -format", path)
+		}
+
+		result, err := importFile(imp, path, opts)
+		if err != nil {
+			log.Fatalf("%s: %v (%d rows imported before the error)", path, err, result.Imported)
+		}
+
+		for _, lineErr := range result.Errors {
+			fmt.Fprintf(os.Stderr, "%s:%d: %s\n", path, lineErr.Line, lineErr.Error)
+		}
+		if result.ErrorsTruncated {
+			fmt.Fprintf(os.Stderr, "%s: %d more rejected lines not shown\n", path, result.Failed-len(result.Errors))
+		}
+		fmt.Printf("%s: %d imported, %d rejected, %d problems detected\n", path, result.Imported, result.Failed, result.Problems)
+		failed = failed || result.Failed > 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// importFile imports the file at path, standard input for -
+func importFile(imp *importer.Importer, path string, opts importer.Options) (*importer.Result, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return &importer.Result{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return imp.Import(r, opts)
+}
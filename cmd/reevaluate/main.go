@@ -0,0 +1,106 @@
+// Command reevaluate replays the current problem detection rules over the
+// requests logged in a time range, the way POST /api/admin/reevaluate does.
+//
+//	go run ./cmd/reevaluate [-from -7d] [-to 2026-02-01] [-tz Europe/Zagreb] [-apply] [-v]
+//
+// -from and -to take the formats of the API's created_after and
+// created_before: RFC3339, YYYY-MM-DD, Unix epochs and relative times, read
+// in UTC unless -tz is given. -to is exclusive. Without -apply it is a dry
+// run that only reports the problems the rules would add and remove. The
+// database is DB_PATH, or ./api_monitor.db, unless -db is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"treblle_project/internal/database"
+	"treblle_project/internal/models"
+	"treblle_project/internal/reevaluation"
+	"treblle_project/internal/repository"
+	"treblle_project/internal/timeparse"
+)
+
+func main() {
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = "./api_monitor.db"
+	}
+
+	flag.StringVar(&dbPath, "db", dbPath, "database path")
+	from := flag.String("from", "", "only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)")
+	to := flag.String("to", "", "only requests created before this time (exclusive), same formats as -from")
+	tz := flag.String("tz", "", "IANA time zone of -from and -to without an offset (default: UTC)")
+	apply := flag.Bool("apply", false, "store the changes instead of only reporting them")
+	verbose := flag.Bool("v", false, "list every added and removed problem")
+	flag.Parse()
+
+	loc := time.UTC
+	if *tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(*tz); err != nil {
+			log.Fatalf("Invalid time zone %q: %v", *tz, err)
+		}
+	}
+	opts := reevaluation.Options{Apply: *apply, Rules: reevaluation.DefaultRules}
+	now := time.Now()
+	for _, bound := range []struct {
+		name  string
+		value string
+		dst   *time.Time
+	}{{"-from", *from, &opts.From}, {"-to", *to, &opts.To}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := timeparse.Parse(bound.value, now, loc)
+		if err != nil {
+			log.Fatalf("Invalid %s %q: %v", bound.name, bound.value, err)
+		}
+		*bound.dst = t
+	}
+	if !opts.From.IsZero() && !opts.To.IsZero() && !opts.From.Before(opts.To) {
+		log.Fatalf("Invalid -to %q: must be after -from", *to)
+	}
+
+	db, err := database.New(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.RunMigrations(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	job := reevaluation.NewJob(repository.NewRequestRepository(db), repository.NewProblemRepository(db))
+	report, err := job.Run(opts)
+	if err != nil {
+		log.Fatalf("Re-evaluation failed after %d requests: %v", report.Checked, err)
+	}
+
+	if *verbose {
+		for _, p := range report.Removed {
+			printProblem("-", p)
+		}
+		for _, p := range report.Added {
+			printProblem("+", p)
+		}
+		if report.Truncated {
+			fmt.Printf("... only the first %d added and removed problems are listed\n", reevaluation.MaxListedChanges)
+		}
+	}
+
+	mode := "dry run, nothing changed"
+	if report.Applied {
+		mode = "applied"
+	}
+	fmt.Printf("Rules %s over %d requests (%s): %d added, %d removed, %d unchanged (%d retagged)\n",
+		report.RuleVersion, report.Checked, mode, report.AddedCount, report.RemovedCount, report.Unchanged, report.Retagged)
+}
+
+// printProblem prints a changed problem prefixed with sign
+func printProblem(sign string, p models.Problem) {
+	fmt.Printf("%s request %d %s %s (%d, %dms) %s: %s\n", sign, p.RequestID, p.Method, p.Path, p.ResponseStatus, p.ResponseTimeMs, p.ProblemType, p.Description)
+}
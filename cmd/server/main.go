@@ -4,9 +4,11 @@ import (
 	"log"
 	"os"
 	_ "treblle_project/docs"
+	"treblle_project/internal/cache"
 	"treblle_project/internal/database"
 	"treblle_project/internal/handlers"
 	"treblle_project/internal/jikan"
+	"treblle_project/internal/models"
 	"treblle_project/internal/repository"
 
 	swaggerFiles "github.com/swaggo/files"
@@ -40,6 +42,20 @@ import (
 // @tag.name jikan
 // @tag.description Proxy to Jikan API with monitoring
 
+// @tag.name upstreams
+// @tag.description Health of the proxied upstream APIs
+
+// @tag.name admin
+// @tag.description Maintenance jobs over the logged data
+
+// @tag.name views
+// @tag.description Saved views of filters, sort and columns
+
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description "Bearer " followed by the ADMIN_TOKEN the server was started with
+
 func main() {
 	// Initialize database with configurable path
 	dbPath := os.Getenv("DB_PATH")
@@ -62,14 +78,23 @@ func main() {
 	// Initialize repositories
 	requestRepo := repository.NewRequestRepository(db)
 	problemRepo := repository.NewProblemRepository(db)
+	viewRepo := repository.NewViewRepository(db)
 
-	// Initialize Jikan client
-	jikanClient := jikan.NewClient()
+	// Initialize Jikan client, configurable through JIKAN_* environment variables
+	jikanConfig := jikan.ConfigFromEnv()
+	if jikanConfig.Cache.Enabled && jikanConfig.Cache.SQLite {
+		jikanConfig.Cache.SecondTier = cache.NewSQLiteStore(db.DB, jikanConfig.Cache.SQLiteMaxBytes)
+	}
+	jikanClient := jikan.NewClientWithConfig(jikanConfig)
 
 	// Initialize handlers
 	requestHandler := handlers.NewRequestHandler(requestRepo)
+	requestHandler.UpstreamURL = jikanConfig.BaseURL
 	problemHandler := handlers.NewProblemHandler(problemRepo)
 	jikanHandler := handlers.NewJikanHandler(jikanClient, requestRepo, problemRepo)
+	upstreamHandler := handlers.NewUpstreamHandler(jikanClient)
+	viewHandler := handlers.NewViewHandler(viewRepo)
+	adminHandler := handlers.NewAdminHandler(requestRepo, problemRepo)
 
 	// Setup router
 	r := gin.Default()
@@ -77,18 +102,47 @@ func main() {
 	// API routes
 	api := r.Group("/api")
 	{
-		// Request viewing endpoints
-		api.GET("/requests", requestHandler.ListRequests)
-		api.GET("/requests/table", requestHandler.TableView)
-		api.GET("/requests/csv", requestHandler.CSVExport)
-
-		// Problem viewing endpoints
-		api.GET("/problems", problemHandler.ListProblems)
-		api.GET("/problems/table", problemHandler.TableView)
-		api.GET("/problems/csv", problemHandler.CSVExport)
+		// Request viewing endpoints, ?view= applies a saved view
+		requestView := viewHandler.Apply(models.ViewResourceRequests)
+		api.GET("/requests", requestView, requestHandler.ListRequests)
+		api.GET("/requests/table", requestView, requestHandler.TableView)
+		api.GET("/requests/csv", requestView, requestHandler.CSVExport)
+		api.GET("/requests/export", requestView, requestHandler.Export)
+		api.GET("/requests/har", requestView, requestHandler.HAR)
+		api.GET("/requests/stats", requestHandler.Stats)
+
+		// Bulk writes into the monitoring data need the admin token, they are
+		// disabled (503) when ADMIN_TOKEN is not set
+		adminToken := os.Getenv("ADMIN_TOKEN")
+		api.POST("/requests/import", handlers.RequireAdminToken(adminToken), requestHandler.Import)
+
+		// Problem viewing endpoints, ?view= applies a saved view
+		problemView := viewHandler.Apply(models.ViewResourceProblems)
+		api.GET("/problems", problemView, problemHandler.ListProblems)
+		api.GET("/problems/table", problemView, problemHandler.TableView)
+		api.GET("/problems/csv", problemView, problemHandler.CSVExport)
+		api.GET("/problems/export", problemView, problemHandler.Export)
+
+		// Saved view endpoints
+		api.GET("/views", viewHandler.ListViews)
+		api.POST("/views", viewHandler.CreateView)
+		api.GET("/views/:name", viewHandler.GetView)
+		api.PUT("/views/:name", viewHandler.UpdateView)
+		api.DELETE("/views/:name", viewHandler.DeleteView)
 
 		// Jikan proxy endpoint - matches any path
 		api.GET("/jikan/*path", jikanHandler.ProxyRequest)
+
+		// Upstream health endpoints
+		api.GET("/upstreams/status", upstreamHandler.Status)
+
+		// Maintenance endpoints, only served when ADMIN_TOKEN is set to authenticate them
+		if adminToken != "" {
+			admin := api.Group("/admin", handlers.RequireAdminToken(adminToken))
+			admin.POST("/reevaluate", adminHandler.Reevaluate)
+		} else {
+			log.Println("ADMIN_TOKEN is not set, maintenance endpoints are disabled")
+		}
 	}
 
 	// Health check
@@ -23,9 +23,82 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/reevaluate": {
+            "post": {
+                "description": "Replay the current detection rules over the requests logged in a time range and report the problems they add and remove compared to the stored ones. A dry run by default; with apply=true the stored problems are changed, added ones and the ones found again tagged with the current rule version. Circuit breaker problems are left alone.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin",
+                    "problems"
+                ],
+                "summary": "Re-evaluate problem detection",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created before this time (exclusive), same formats as created_after",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Store the changes instead of only reporting them (default: false)",
+                        "name": "apply",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Problems added and removed by the current rules",
+                        "schema": {
+                            "$ref": "#/definitions/reevaluation.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error, pages changed before it are kept",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ]
+            }
+        },
         "/jikan/{path}": {
             "get": {
-                "description": "Forwards requests to the Jikan API, logs metrics, and detects problems (404, 403, 400, slow responses, etc.). Returns the proxied response with the same status code from Jikan.",
+                "description": "Forwards requests to the Jikan API, logs metrics (including response size, content type and selected upstream headers), and detects problems (404, 403, 400, slow or large responses, etc.). Returns the proxied response with the same status code from Jikan and the upstream headers allowed by the header policy (hop-by-hop headers are always stripped).",
                 "consumes": [
                     "application/json"
                 ],
@@ -44,11 +117,41 @@ const docTemplate = `{
                         "name": "path",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deadline for the request, as a duration (2s, 1500ms) or milliseconds, capped at 1m",
+                        "name": "X-Request-Timeout",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "Successfully proxied response from Jikan API (returns whatever status Jikan returns: 200, 404, etc.)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        },
+                        "headers": {
+                            "X-Cache": {
+                                "type": "string",
+                                "description": "HIT, MISS or STALE when the response cache is enabled"
+                            },
+                            "X-Monitor-Request-Id": {
+                                "type": "string",
+                                "description": "Id of the logged request"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid X-Request-Timeout header",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "Client-side rate limit for Jikan exceeded, see Retry-After (request is logged with throttle_status rejected)",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -67,6 +170,20 @@ const docTemplate = `{
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "503": {
+                        "description": "Circuit breaker for Jikan is open, see Retry-After (request is logged with outcome circuit_open)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "The upstream did not respond within the X-Request-Timeout deadline (request is logged with outcome deadline_exceeded)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             }
@@ -91,13 +208,13 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "HTTP method filter (GET, POST, etc.)",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
                         "name": "method",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Response status code filter",
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
                         "name": "response",
                         "in": "query"
                     },
@@ -115,16 +232,22 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Filter problems created after date (format: 2006-01-02)",
+                        "description": "Only problems created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
                         "name": "created_after",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter problems created before date (format: 2006-01-02)",
+                        "description": "Only problems created before this time (exclusive), same formats as created_after",
                         "name": "created_before",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Search in request path",
@@ -133,21 +256,75 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Sort by field (response_time, created_at)",
+                        "description": "Problem types, comma separated, ! to exclude (e.g. not_found,server_error)",
+                        "name": "problem_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Versions of the detection rules that recorded the problems, comma separated, none for unversioned, ! to exclude (e.g. !1)",
+                        "name": "rule_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "request to list each request with its matching problems once, sorted by created_at, response_time, method, path, response or problem_count",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)",
                         "name": "sort",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Maximum number of results (default: 100)",
+                        "description": "Maximum number of results (default: 100, max: 1000)",
                         "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Number of results to skip (default: 0)",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
                         "name": "offset",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor or prev_cursor of a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row (meta.total, X-Total-Count on CSV)",
+                        "name": "total",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -158,6 +335,13 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -191,13 +375,13 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "HTTP method filter (GET, POST, etc.)",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
                         "name": "method",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Response status code filter",
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
                         "name": "response",
                         "in": "query"
                     },
@@ -215,16 +399,22 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Filter problems created after date (format: 2006-01-02)",
+                        "description": "Only problems created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
                         "name": "created_after",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter problems created before date (format: 2006-01-02)",
+                        "description": "Only problems created before this time (exclusive), same formats as created_after",
                         "name": "created_before",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps",
+                        "name": "tz",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Search in request path",
@@ -233,30 +423,103 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Sort by field (response_time, created_at)",
+                        "description": "Problem types, comma separated, ! to exclude (e.g. not_found,server_error)",
+                        "name": "problem_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Versions of the detection rules that recorded the problems, comma separated, none for unversioned, ! to exclude (e.g. !1)",
+                        "name": "rule_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "request to list each request with its matching problems once, sorted by created_at, response_time, method, path, response or problem_count",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns in order (id, request_id, problem_type, description, method, response, path, response_time, threshold_ms, created_at, rule_version; grouped by request: request_id, method, response, path, response_time, problem_count, problem_types, created_at)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format of timestamps: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms",
+                        "name": "time_format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)",
                         "name": "sort",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Maximum number of results (default: 100)",
+                        "description": "Maximum number of rows (default: every matching row)",
                         "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Number of results to skip (default: 0)",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
                         "name": "offset",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor of a previous page to start from",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row in X-Total-Count",
+                        "name": "total",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "CSV file download",
+                        "description": "CSV file download, streamed and gzip compressed when accepted",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -269,33 +532,36 @@ const docTemplate = `{
                 }
             }
         },
-        "/problems/table": {
+        "/problems/export": {
             "get": {
-                "description": "Get an ordered table of failed or problematic external API calls, optional filtering, ordering and searching, intended for further processing",
+                "description": "Stream every problem matching the filters as a file for notebooks and spreadsheets, with the columns and sort of the table and CSV views",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                    "application/vnd.apache.parquet",
+                    "text/csv"
                 ],
                 "tags": [
                     "problems",
-                    "order",
-                    "filter",
+                    "download",
                     "search",
-                    "table"
+                    "filter",
+                    "order"
                 ],
-                "summary": "Table of failed or problematic API calls",
+                "summary": "Export problems as NDJSON, Excel, Parquet or CSV",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "HTTP method filter (GET, POST, etc.)",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
                         "name": "method",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Response status code filter",
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
                         "name": "response",
                         "in": "query"
                     },
@@ -313,16 +579,22 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Filter problems created after date (format: 2006-01-02)",
+                        "description": "Only problems created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
                         "name": "created_after",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter problems created before date (format: 2006-01-02)",
+                        "description": "Only problems created before this time (exclusive), same formats as created_after",
                         "name": "created_before",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps",
+                        "name": "tz",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Search in request path",
@@ -331,26 +603,104 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Sort by field (response_time, created_at)",
+                        "description": "Problem types, comma separated, ! to exclude (e.g. not_found,server_error)",
+                        "name": "problem_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Versions of the detection rules that recorded the problems, comma separated, none for unversioned, ! to exclude (e.g. !1)",
+                        "name": "rule_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "request to list each request with its matching problems once, sorted by created_at, response_time, method, path, response or problem_count",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "File format: csv (default), ndjson, xlsx or parquet",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns in order (id, request_id, problem_type, description, method, response, path, response_time, threshold_ms, created_at, rule_version; grouped by request: request_id, method, response, path, response_time, problem_count, problem_types, created_at)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format of timestamps in csv and ndjson: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms",
+                        "name": "time_format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)",
                         "name": "sort",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Maximum number of results (default: 100)",
+                        "description": "Maximum number of rows (default: every matching row)",
                         "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Number of results to skip (default: 0)",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
                         "name": "offset",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor of a previous page to start from",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row in X-Total-Count",
+                        "name": "total",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Table data with columns and rows",
+                        "description": "File download, streamed; csv and ndjson gzip compressed when accepted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -368,9 +718,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/requests": {
+        "/problems/table": {
             "get": {
-                "description": "Get a list of logged API requests calls with optional filtering, ordering and searching",
+                "description": "Get an ordered table of failed or problematic external API calls, optional filtering, ordering and searching, intended for further processing",
                 "consumes": [
                     "application/json"
                 ],
@@ -378,23 +728,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "requests",
-                    "filter",
+                    "problems",
                     "order",
+                    "filter",
                     "search",
-                    "list"
+                    "table"
                 ],
-                "summary": "List of API requests successfully completed",
+                "summary": "Table of failed or problematic API calls",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "HTTP method filter (GET, POST, etc.)",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
                         "name": "method",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Response status code filter",
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
                         "name": "response",
                         "in": "query"
                     },
@@ -412,16 +762,22 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Filter requests created after date (format: 2006-01-02)",
+                        "description": "Only problems created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
                         "name": "created_after",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter requests created before date (format: 2006-01-02)",
+                        "description": "Only problems created before this time (exclusive), same formats as created_after",
                         "name": "created_before",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps",
+                        "name": "tz",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Search in request path",
@@ -430,26 +786,99 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Sort by field (response_time, created_at)",
+                        "description": "Problem types, comma separated, ! to exclude (e.g. not_found,server_error)",
+                        "name": "problem_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Versions of the detection rules that recorded the problems, comma separated, none for unversioned, ! to exclude (e.g. !1)",
+                        "name": "rule_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "request to list each request with its matching problems once, sorted by created_at, response_time, method, path, response or problem_count",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns in order (id, request_id, problem_type, description, method, response, path, response_time, threshold_ms, created_at, rule_version; grouped by request: request_id, method, response, path, response_time, problem_count, problem_types, created_at)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format of timestamps: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms",
+                        "name": "time_format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)",
                         "name": "sort",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Maximum number of results (default: 100)",
+                        "description": "Maximum number of results (default: 100, max: 1000)",
                         "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Number of results to skip (default: 0)",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
                         "name": "offset",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor or prev_cursor of a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row (meta.total, X-Total-Count on CSV)",
+                        "name": "total",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of requests with metadata",
+                        "description": "Table data with columns and rows",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -467,34 +896,33 @@ const docTemplate = `{
                 }
             }
         },
-        "/requests/csv": {
+        "/requests": {
             "get": {
-                "description": "Download successfully completed API requests as a CSV file, supports filtering, ordering and searching, ready for use",
+                "description": "Get a list of logged API requests calls with optional filtering, ordering and searching",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "text/csv"
+                    "application/json"
                 ],
                 "tags": [
                     "requests",
-                    "download",
-                    "csv",
                     "filter",
                     "order",
-                    "search"
+                    "search",
+                    "list"
                 ],
-                "summary": "Export successfully completed API requests as CSV",
+                "summary": "List of API requests successfully completed",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "HTTP method filter (GET, POST, etc.)",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
                         "name": "method",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Response status code filter",
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
                         "name": "response",
                         "in": "query"
                     },
@@ -512,16 +940,92 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Filter requests created after date (format: 2006-01-02)",
+                        "description": "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
                         "name": "created_after",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter requests created before date (format: 2006-01-02)",
+                        "description": "Only requests created before this time (exclusive), same formats as created_after",
                         "name": "created_before",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response size in bytes",
+                        "name": "min_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response size in bytes",
+                        "name": "max_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response Content-Type prefix (e.g. application/json)",
+                        "name": "content_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)",
+                        "name": "header",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rate limiting outcome (none, queued, rejected)",
+                        "name": "throttle",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request outcome (none, circuit_open)",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)",
+                        "name": "cache_status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only requests served (true) or not served (false) by another request's upstream call",
+                        "name": "coalesced",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests produced by this upstream call",
+                        "name": "upstream_call_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only the retry attempts of this request",
+                        "name": "parent_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out retry attempt rows",
+                        "name": "exclude_attempts",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Search in request path",
@@ -530,28 +1034,72 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Sort by field (response_time, created_at)",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)",
                         "name": "sort",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Maximum number of results (default: 100)",
+                        "description": "Maximum number of results (default: 100, max: 1000)",
                         "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Number of results to skip (default: 0)",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
                         "name": "offset",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor or prev_cursor of a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row (meta.total, X-Total-Count on CSV)",
+                        "name": "total",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "CSV file download",
+                        "description": "List of requests with metadata",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "500": {
@@ -566,33 +1114,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/requests/table": {
+        "/requests/csv": {
             "get": {
-                "description": "Get successfully completed API request calls formatted for table display after further proccessing with columns and rows, supports ordering, filtering and searching",
+                "description": "Download successfully completed API requests as a CSV file, supports filtering, ordering and searching, ready for use",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "text/csv"
                 ],
                 "tags": [
                     "requests",
-                    "table",
-                    "search",
+                    "download",
+                    "csv",
                     "filter",
-                    "order"
+                    "order",
+                    "search"
                 ],
-                "summary": "Table of successfully completed API request calls",
+                "summary": "Export successfully completed API requests as CSV",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "HTTP method filter (GET, POST, etc.)",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
                         "name": "method",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Response status code filter",
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
                         "name": "response",
                         "in": "query"
                     },
@@ -610,16 +1159,92 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Filter requests created after date (format: 2006-01-02)",
+                        "description": "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
                         "name": "created_after",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter requests created before date (format: 2006-01-02)",
+                        "description": "Only requests created before this time (exclusive), same formats as created_after",
                         "name": "created_before",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response size in bytes",
+                        "name": "min_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response size in bytes",
+                        "name": "max_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response Content-Type prefix (e.g. application/json)",
+                        "name": "content_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)",
+                        "name": "header",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rate limiting outcome (none, queued, rejected)",
+                        "name": "throttle",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request outcome (none, circuit_open)",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)",
+                        "name": "cache_status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only requests served (true) or not served (false) by another request's upstream call",
+                        "name": "coalesced",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests produced by this upstream call",
+                        "name": "upstream_call_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only the retry attempts of this request",
+                        "name": "parent_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out retry attempt rows",
+                        "name": "exclude_attempts",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Search in request path",
@@ -628,26 +1253,80 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Sort by field (response_time, created_at)",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns in order (id, method, response, path, response_time, response_bytes, content_type, upstream_headers, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, conn_reused, throttle_status, queue_wait_ms, outcome, cache_status, coalesced, upstream_call_id, parent_id, attempt, created_at)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format of timestamps: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms",
+                        "name": "time_format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)",
                         "name": "sort",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Maximum number of results (default: 100)",
+                        "description": "Maximum number of rows (default: every matching row)",
                         "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Number of results to skip (default: 0)",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
                         "name": "offset",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor of a previous page to start from",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row in X-Total-Count",
+                        "name": "total",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Table data with columns and rows",
+                        "description": "CSV file download, streamed and gzip compressed when accepted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -664,27 +1343,1616 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "tags": [
-        {
-            "description": "Operations for viewing API request call logs",
-            "name": "requests"
-        },
-        {
-            "description": "Operations for viewing detected failed or problematic API calls",
-            "name": "problems"
+        },
+        "/requests/export": {
+            "get": {
+                "description": "Stream every request matching the filters as a file for notebooks and spreadsheets, with the columns and sort of the table and CSV views",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                    "application/vnd.apache.parquet",
+                    "text/csv"
+                ],
+                "tags": [
+                    "requests",
+                    "download",
+                    "search",
+                    "filter",
+                    "order"
+                ],
+                "summary": "Export requests as NDJSON, Excel, Parquet or CSV",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
+                        "name": "response",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response time in milliseconds",
+                        "name": "min_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response time in milliseconds",
+                        "name": "max_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created before this time (exclusive), same formats as created_after",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response size in bytes",
+                        "name": "min_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response size in bytes",
+                        "name": "max_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response Content-Type prefix (e.g. application/json)",
+                        "name": "content_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)",
+                        "name": "header",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rate limiting outcome (none, queued, rejected)",
+                        "name": "throttle",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request outcome (none, circuit_open)",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)",
+                        "name": "cache_status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only requests served (true) or not served (false) by another request's upstream call",
+                        "name": "coalesced",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests produced by this upstream call",
+                        "name": "upstream_call_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only the retry attempts of this request",
+                        "name": "parent_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out retry attempt rows",
+                        "name": "exclude_attempts",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search in request path",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "File format: csv (default), ndjson, xlsx or parquet",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns in order (id, method, response, path, response_time, response_bytes, content_type, upstream_headers, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, conn_reused, throttle_status, queue_wait_ms, outcome, cache_status, coalesced, upstream_call_id, parent_id, attempt, created_at)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format of timestamps in csv and ndjson: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms",
+                        "name": "time_format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of rows (default: every matching row)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor of a previous page to start from",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row in X-Total-Count",
+                        "name": "total",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File download, streamed; csv and ndjson gzip compressed when accepted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/requests/har": {
+            "get": {
+                "description": "Stream the matching requests as an HTTP Archive (HAR 1.2) with timings and the recorded upstream response headers, oldest first unless sorted, to open in browser devtools or other HAR viewers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "requests",
+                    "download",
+                    "search",
+                    "filter",
+                    "order"
+                ],
+                "summary": "Export requests as a HAR file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
+                        "name": "response",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response time in milliseconds",
+                        "name": "min_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response time in milliseconds",
+                        "name": "max_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created before this time (exclusive), same formats as created_after",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response size in bytes",
+                        "name": "min_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response size in bytes",
+                        "name": "max_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response Content-Type prefix (e.g. application/json)",
+                        "name": "content_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)",
+                        "name": "header",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rate limiting outcome (none, queued, rejected)",
+                        "name": "throttle",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request outcome (none, circuit_open)",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)",
+                        "name": "cache_status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only requests served (true) or not served (false) by another request's upstream call",
+                        "name": "coalesced",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests produced by this upstream call",
+                        "name": "upstream_call_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only the retry attempts of this request",
+                        "name": "parent_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out retry attempt rows",
+                        "name": "exclude_attempts",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search in request path",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (default: created_at, oldest first)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of rows (default: every matching row)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor of a previous page to start from",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row in X-Total-Count",
+                        "name": "total",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "HAR document download, streamed and gzip compressed when accepted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/requests/import": {
+            "post": {
+                "description": "Import logged requests from a JSONL file, with objects as the NDJSON export writes them or as the API lists them, or from a CSV file with a header line of export column names. Every line is validated, valid rows are inserted in batches of one transaction each and invalid lines are reported with their line number. Ids only link retry attempts to their parent within the file, imported rows get new ids. Bodies are limited to 256 MiB.",
+                "consumes": [
+                    "text/plain",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "requests",
+                    "import"
+                ],
+                "summary": "Import request logs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File format (jsonl, csv), guessed from the file name or Content-Type when absent",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run problem detection on the imported rows (default: false)",
+                        "name": "detect",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Rows inserted per transaction (default: 500, max: 10000)",
+                        "name": "batch_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of created_at values without an offset (default: the server's local time)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "file",
+                        "description": "File to import, when uploading a multipart form instead of sending the file as the body",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Import summary with the rejected lines",
+                        "schema": {
+                            "$ref": "#/definitions/importer.Result"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json) or a file that can't be imported",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "413": {
+                        "description": "Body over the size limit, batches inserted before it are kept",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error, batches inserted before it are kept",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Imports are disabled because ADMIN_TOKEN is not set",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ]
+            }
+        },
+        "/requests/stats": {
+            "get": {
+                "description": "Get request count, response time range and the average latency breakdown (DNS, connect, TLS, time to first byte, transfer) for the matching requests, to tell upstream processing time apart from network time",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "requests",
+                    "stats",
+                    "filter",
+                    "search"
+                ],
+                "summary": "Aggregated latency statistics for API requests",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
+                        "name": "response",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response time in milliseconds",
+                        "name": "min_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response time in milliseconds",
+                        "name": "max_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created before this time (exclusive), same formats as created_after",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response size in bytes",
+                        "name": "min_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response size in bytes",
+                        "name": "max_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response Content-Type prefix (e.g. application/json)",
+                        "name": "content_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)",
+                        "name": "header",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rate limiting outcome (none, queued, rejected)",
+                        "name": "throttle",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request outcome (none, circuit_open)",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)",
+                        "name": "cache_status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only requests served (true) or not served (false) by another request's upstream call",
+                        "name": "coalesced",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests produced by this upstream call",
+                        "name": "upstream_call_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only the retry attempts of this request",
+                        "name": "parent_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out retry attempt rows",
+                        "name": "exclude_attempts",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search in request path",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Aggregated statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.RequestStats"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/requests/table": {
+            "get": {
+                "description": "Get successfully completed API request calls formatted for table display after further proccessing with columns and rows, supports ordering, filtering and searching",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "requests",
+                    "table",
+                    "search",
+                    "filter",
+                    "order"
+                ],
+                "summary": "Table of successfully completed API request calls",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)",
+                        "name": "response",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response time in milliseconds",
+                        "name": "min_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response time in milliseconds",
+                        "name": "max_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests created before this time (exclusive), same formats as created_after",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum response size in bytes",
+                        "name": "min_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum response size in bytes",
+                        "name": "max_bytes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response Content-Type prefix (e.g. application/json)",
+                        "name": "content_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)",
+                        "name": "header",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rate limiting outcome (none, queued, rejected)",
+                        "name": "throttle",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request outcome (none, circuit_open)",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)",
+                        "name": "cache_status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only requests served (true) or not served (false) by another request's upstream call",
+                        "name": "coalesced",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests produced by this upstream call",
+                        "name": "upstream_call_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only the retry attempts of this request",
+                        "name": "parent_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out retry attempt rows",
+                        "name": "exclude_attempts",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search in request path",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression the path must match",
+                        "name": "path_regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GLOB pattern the path must match (e.g. /anime/*)",
+                        "name": "path_glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression (e.g. status \u003e= 500 OR (response_time \u003e 800 AND path ~ '/anime/'))",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns in order (id, method, response, path, response_time, response_bytes, content_type, upstream_headers, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, conn_reused, throttle_status, queue_wait_ms, outcome, cache_status, coalesced, upstream_call_id, parent_id, attempt, created_at)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format of timestamps: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms",
+                        "name": "time_format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view to apply, other parameters override its settings",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of results (default: 100, max: 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default: 0), ignored with cursor",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor or prev_cursor of a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also count every matching row (meta.total, X-Total-Count on CSV)",
+                        "name": "total",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Table data with columns and rows",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/upstreams/status": {
+            "get": {
+                "description": "Get the state (closed, open, half_open) of every upstream circuit breaker, with its failure counters and the time left until the next probe",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "upstreams",
+                    "status"
+                ],
+                "summary": "Upstream circuit breaker status",
+                "responses": {
+                    "200": {
+                        "description": "Circuit breaker states",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/views": {
+            "get": {
+                "description": "Get every saved view, optionally only those of one resource",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views",
+                    "list"
+                ],
+                "summary": "List saved views",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only views of this resource (requests, problems)",
+                        "name": "resource",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of views",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Save a named combination of filters, sort and columns, applied with ?view=name on the list, table and CSV endpoints of its resource",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "Save a view",
+                "parameters": [
+                    {
+                        "description": "View to save (id and timestamps are ignored)",
+                        "name": "view",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.View"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The saved view",
+                        "schema": {
+                            "$ref": "#/definitions/models.View"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid view (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "A view with this name exists",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/views/{name}": {
+            "get": {
+                "description": "Get one saved view by name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "Get a saved view",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "View name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The view",
+                        "schema": {
+                            "$ref": "#/definitions/models.View"
+                        }
+                    },
+                    "404": {
+                        "description": "View not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the filters, sort and columns of a saved view, or rename it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "Replace a saved view",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "View name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New view (id and timestamps are ignored)",
+                        "name": "view",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.View"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The updated view",
+                        "schema": {
+                            "$ref": "#/definitions/models.View"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid view (application/problem+json)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "View not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "A view with the new name exists",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a saved view by name",
+                "tags": [
+                    "views"
+                ],
+                "summary": "Delete a saved view",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "View name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "View deleted"
+                    },
+                    "404": {
+                        "description": "View not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "importer.LineError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Why the line was rejected",
+                    "type": "string",
+                    "example": "path: must start with /"
+                },
+                "line": {
+                    "description": "Line number, from 1, the header line of a CSV file included",
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "importer.Result": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "description": "The first MaxReportedErrors rejected lines",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/importer.LineError"
+                    }
+                },
+                "errors_truncated": {
+                    "description": "More lines were rejected than errors lists",
+                    "type": "boolean",
+                    "example": false
+                },
+                "failed": {
+                    "description": "Lines rejected",
+                    "type": "integer",
+                    "example": 2
+                },
+                "imported": {
+                    "description": "Requests inserted",
+                    "type": "integer",
+                    "example": 998
+                },
+                "problems": {
+                    "description": "Problems detected on the inserted requests",
+                    "type": "integer",
+                    "example": 31
+                }
+            }
+        },
+        "models.Problem": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "When the problem was detected",
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "description": {
+                    "description": "Human-readable description",
+                    "type": "string",
+                    "example": "The requested resource could not be found"
+                },
+                "id": {
+                    "description": "Unique identifier",
+                    "type": "integer",
+                    "example": 1
+                },
+                "method": {
+                    "description": "Joined fields from api_requests",
+                    "type": "string",
+                    "example": "GET"
+                },
+                "path": {
+                    "description": "Request path from related request",
+                    "type": "string",
+                    "example": "/anime/999"
+                },
+                "problem_type": {
+                    "description": "Type of problem (not_found, slow_response, forbidden, etc.)",
+                    "type": "string",
+                    "example": "not_found"
+                },
+                "request_id": {
+                    "description": "Related request ID",
+                    "type": "integer",
+                    "example": 5
+                },
+                "response": {
+                    "description": "Response status from related request",
+                    "type": "integer",
+                    "example": 404
+                },
+                "response_time": {
+                    "description": "Response time from related request",
+                    "type": "integer",
+                    "example": 150
+                },
+                "rule_version": {
+                    "description": "Version of the detection rules that last recorded it, empty for problems from before versioning",
+                    "type": "string",
+                    "example": "1"
+                },
+                "threshold_ms": {
+                    "description": "Threshold that triggered this problem (for slow_response)",
+                    "type": "integer",
+                    "example": 400
+                }
+            }
+        },
+        "models.RequestStats": {
+            "type": "object",
+            "properties": {
+                "avg_connect_ms": {
+                    "description": "Average TCP connect time in milliseconds",
+                    "type": "number",
+                    "example": 3.4
+                },
+                "avg_dns_ms": {
+                    "description": "Average DNS lookup time in milliseconds",
+                    "type": "number",
+                    "example": 1.2
+                },
+                "avg_queue_wait_ms": {
+                    "description": "Average rate limit wait of queued requests in milliseconds",
+                    "type": "number",
+                    "example": 310
+                },
+                "avg_response_bytes": {
+                    "description": "Average response size in bytes",
+                    "type": "number",
+                    "example": 20480
+                },
+                "avg_response_time": {
+                    "description": "Average total response time in milliseconds",
+                    "type": "number",
+                    "example": 210.5
+                },
+                "avg_tls_ms": {
+                    "description": "Average TLS handshake time in milliseconds",
+                    "type": "number",
+                    "example": 8.1
+                },
+                "avg_transfer_ms": {
+                    "description": "Average body transfer time in milliseconds",
+                    "type": "number",
+                    "example": 17.6
+                },
+                "avg_ttfb_ms": {
+                    "description": "Average time to first byte in milliseconds",
+                    "type": "number",
+                    "example": 180.2
+                },
+                "cache_hit_count": {
+                    "description": "Requests served from a fresh cache entry",
+                    "type": "integer",
+                    "example": 40
+                },
+                "cache_miss_count": {
+                    "description": "Cacheable requests fetched from the upstream",
+                    "type": "integer",
+                    "example": 25
+                },
+                "cache_stale_count": {
+                    "description": "Requests served from an expired cache entry",
+                    "type": "integer",
+                    "example": 3
+                },
+                "coalesced_count": {
+                    "description": "Requests served by another request's upstream call",
+                    "type": "integer",
+                    "example": 12
+                },
+                "conn_reused_ratio": {
+                    "description": "Share of requests that reused a connection (0-1)",
+                    "type": "number",
+                    "example": 0.85
+                },
+                "count": {
+                    "description": "Number of matching proxied requests, retry attempts not included",
+                    "type": "integer",
+                    "example": 120
+                },
+                "max_response_time": {
+                    "description": "Slowest response time in milliseconds",
+                    "type": "integer",
+                    "example": 1900
+                },
+                "min_response_time": {
+                    "description": "Fastest response time in milliseconds",
+                    "type": "integer",
+                    "example": 80
+                },
+                "queued_count": {
+                    "description": "Requests that waited for a rate limit token",
+                    "type": "integer",
+                    "example": 7
+                },
+                "rejected_count": {
+                    "description": "Requests rejected by the client-side rate limiter",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "models.View": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "description": "Columns of the table and CSV views, in order, all when empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "description": "When the view was created",
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "filters": {
+                    "description": "Query parameters applied by the view, such as response or q",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "description": "Unique identifier",
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "description": "Unique name used in ?view=",
+                    "type": "string",
+                    "example": "slow-anime"
+                },
+                "resource": {
+                    "description": "Target resource: requests or problems",
+                    "type": "string",
+                    "example": "problems"
+                },
+                "sort": {
+                    "description": "Sort spec, same syntax as the sort parameter",
+                    "type": "string",
+                    "example": "-response_time"
+                },
+                "updated_at": {
+                    "description": "When the view was last changed",
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                }
+            }
+        },
+        "reevaluation.Report": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "description": "The first MaxListedChanges added problems",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Problem"
+                    }
+                },
+                "added_count": {
+                    "description": "Problems the rules record that aren't stored",
+                    "type": "integer",
+                    "example": 14
+                },
+                "applied": {
+                    "description": "Whether the changes were stored, false for a dry run",
+                    "type": "boolean",
+                    "example": false
+                },
+                "checked": {
+                    "description": "Requests checked",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "from": {
+                    "description": "Lower bound on created_at, absent for none",
+                    "type": "string",
+                    "example": "2024-01-01T00:00:00Z"
+                },
+                "removed": {
+                    "description": "The first MaxListedChanges removed problems",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Problem"
+                    }
+                },
+                "removed_count": {
+                    "description": "Stored problems the rules no longer record",
+                    "type": "integer",
+                    "example": 3
+                },
+                "retagged": {
+                    "description": "Unchanged problems tagged with an older rule version, retagged when applied",
+                    "type": "integer",
+                    "example": 12
+                },
+                "rule_version": {
+                    "description": "Version of the replayed rules",
+                    "type": "string",
+                    "example": "2"
+                },
+                "to": {
+                    "description": "Upper bound on created_at, absent for none",
+                    "type": "string",
+                    "example": "2024-02-01T00:00:00Z"
+                },
+                "truncated": {
+                    "description": "More problems changed than added and removed list",
+                    "type": "boolean",
+                    "example": false
+                },
+                "unchanged": {
+                    "description": "Stored problems the rules record again",
+                    "type": "integer",
+                    "example": 80
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "\"Bearer \" followed by the ADMIN_TOKEN the server was started with",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
+    },
+    "tags": [
+        {
+            "description": "Operations for viewing API request call logs",
+            "name": "requests"
+        },
+        {
+            "description": "Operations for viewing detected failed or problematic API calls",
+            "name": "problems"
         },
         {
             "description": "Proxy to Jikan API with monitoring",
             "name": "jikan"
+        },
+        {
+            "description": "Health of the proxied upstream APIs",
+            "name": "upstreams"
+        },
+        {
+            "description": "Maintenance jobs over the logged data",
+            "name": "admin"
+        },
+        {
+            "description": "Saved views of filters, sort and columns",
+            "name": "views"
         }
     ]
 }`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
-	Version:          "1.0",
+	Version:          "1.1.1",
 	Host:             "localhost:8080",
 	BasePath:         "/api",
 	Schemes:          []string{"http", "https"},
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"net/http"
+	"time"
+)
+
+// Entry is a cached upstream response
+type Entry struct {
+	Key          string
+	Status       int
+	Header       http.Header
+	Body         []byte
+	StoredAt     time.Time
+	ExpiresAt    time.Time     // Fresh until this time
+	StaleWindow  time.Duration // stale-while-revalidate: how long after ExpiresAt the entry may be served while refreshing
+	ErrorWindow  time.Duration // stale-if-error: how long after ExpiresAt the entry may be served when the upstream fails
+	ETag         string
+	LastModified string
+}
+
+// Fresh reports whether the entry can be served without contacting the upstream
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// ServableStale reports whether an expired entry may still be served while it is revalidated in the background
+func (e *Entry) ServableStale(now time.Time) bool {
+	return now.Before(e.ExpiresAt.Add(e.StaleWindow))
+}
+
+// ServableOnError reports whether an expired entry may be served because the upstream failed
+func (e *Entry) ServableOnError(now time.Time) bool {
+	return now.Before(e.ExpiresAt.Add(e.ErrorWindow))
+}
+
+// Revalidatable reports whether the upstream can answer a conditional request for the entry
+func (e *Entry) Revalidatable() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+// Size approximates the memory held by the entry
+func (e *Entry) Size() int64 {
+	size := int64(len(e.Key) + len(e.Body) + len(e.ETag) + len(e.LastModified))
+	for name, values := range e.Header {
+		size += int64(len(name))
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+	return size
+}
+
+// Store keeps cached responses. Implementations must be safe for concurrent use.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(entry *Entry) error
+	Delete(key string) error
+}
+
+// Tiered looks entries up in a fast first tier (usually an LRU) and falls back
+// to a slower, larger second tier, promoting what it finds there
+type Tiered struct {
+	first  Store
+	second Store
+}
+
+// NewTiered combines two stores. second may be nil, in which case first is returned as is.
+func NewTiered(first, second Store) Store {
+	if second == nil {
+		return first
+	}
+	return &Tiered{first: first, second: second}
+}
+
+func (t *Tiered) Get(key string) (*Entry, bool) {
+	if entry, ok := t.first.Get(key); ok {
+		return entry, true
+	}
+	entry, ok := t.second.Get(key)
+	if ok {
+		_ = t.first.Set(entry)
+	}
+	return entry, ok
+}
+
+func (t *Tiered) Set(entry *Entry) error {
+	_ = t.first.Set(entry) // Entries too large for the first tier only live in the second
+	return t.second.Set(entry)
+}
+
+func (t *Tiered) Delete(key string) error {
+	_ = t.first.Delete(key)
+	return t.second.Delete(key)
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"treblle_project/internal/database"
+)
+
+func newTestEntry(key string, bodySize int) *Entry {
+	return &Entry{
+		Key:       key,
+		Status:    200,
+		Header:    http.Header{"Content-Type": {"application/json"}},
+		Body:      []byte(strings.Repeat("x", bodySize)),
+		StoredAt:  time.Now(),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+}
+
+// Test 1: The LRU evicts the least recently used entries once over its size cap
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	lru := NewLRU(300)
+
+	for _, key := range []string{"/a", "/b"} {
+		if err := lru.Set(newTestEntry(key, 100)); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	lru.Get("/a") // /b is now the least recently used
+	if err := lru.Set(newTestEntry("/c", 100)); err != nil {
+		t.Fatalf("Failed to set /c: %v", err)
+	}
+
+	if _, ok := lru.Get("/b"); ok {
+		t.Error("Expected /b to be evicted")
+	}
+	for _, key := range []string{"/a", "/c"} {
+		if _, ok := lru.Get(key); !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+	if lru.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", lru.Len())
+	}
+
+	if err := lru.Set(newTestEntry("/huge", 1000)); err == nil {
+		t.Error("Expected an entry larger than the cache to be refused")
+	}
+}
+
+// Test 2: Entries round-trip through the SQLite store
+func TestSQLiteStore_RoundTrip(t *testing.T) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	store := NewSQLiteStore(db.DB, 1024)
+
+	entry := newTestEntry("/anime/1", 10)
+	entry.ETag = `"v1"`
+	entry.StaleWindow = 30 * time.Second
+	if err := store.Set(entry); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+	entry.ETag = `"v2"`
+	if err := store.Set(entry); err != nil {
+		t.Fatalf("Failed to replace entry: %v", err)
+	}
+
+	got, ok := store.Get("/anime/1")
+	if !ok {
+		t.Fatal("Expected stored entry to be found")
+	}
+	if got.ETag != `"v2"` || string(got.Body) != string(entry.Body) || got.StaleWindow != 30*time.Second {
+		t.Errorf("Unexpected entry %+v", got)
+	}
+	if got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected headers to round-trip, got %v", got.Header)
+	}
+
+	if err := store.Delete("/anime/1"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if _, ok := store.Get("/anime/1"); ok {
+		t.Error("Expected deleted entry to be gone")
+	}
+}
+
+// Test 3: Tiered promotes second tier hits into the first tier
+func TestTiered_PromotesHits(t *testing.T) {
+	first, second := NewLRU(1024), NewLRU(1024)
+	store := NewTiered(first, second)
+
+	if err := second.Set(newTestEntry("/top/anime", 10)); err != nil {
+		t.Fatalf("Failed to seed second tier: %v", err)
+	}
+
+	if _, ok := store.Get("/top/anime"); !ok {
+		t.Fatal("Expected a second tier hit")
+	}
+	if _, ok := first.Get("/top/anime"); !ok {
+		t.Error("Expected the entry to be promoted to the first tier")
+	}
+}
+
+// Test 4: The SQLite store evicts the oldest stored entries once over its size cap and purges dead ones
+func TestSQLiteStore_EvictsAndPurges(t *testing.T) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	store := NewSQLiteStore(db.DB, 300)
+
+	// Expired with no stale window left, gone on the next write
+	dead := newTestEntry("/dead", 10)
+	dead.ExpiresAt = time.Now().Add(-time.Minute)
+	dead.StaleWindow = 30 * time.Second
+	if _, err := db.Exec(`INSERT INTO response_cache (key, status, body, stored_at, expires_at, purge_at) VALUES (?, 200, '', ?, ?, ?)`,
+		dead.Key, time.Now(), dead.ExpiresAt, dead.ExpiresAt.Add(dead.StaleWindow)); err != nil {
+		t.Fatalf("Failed to insert expired entry: %v", err)
+	}
+
+	base := time.Now()
+	for i, key := range []string{"/b", "/a", "/c"} {
+		entry := newTestEntry(key, 100)
+		entry.StoredAt = base.Add(time.Duration(i) * time.Second)
+		if err := store.Set(entry); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+
+	for _, key := range []string{"/dead", "/b"} {
+		if _, ok := store.Get(key); ok {
+			t.Errorf("Expected %s to be removed", key)
+		}
+	}
+	for _, key := range []string{"/a", "/c"} {
+		if _, ok := store.Get(key); !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+
+	if err := store.Set(newTestEntry("/huge", 1000)); err == nil {
+		t.Error("Expected an entry larger than the cache to be refused")
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// LRU is an in-memory store bounded by the total size of its entries.
+// The least recently used entries are evicted first.
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // Front is most recently used
+	items    map[string]*list.Element
+}
+
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *LRU) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*Entry), true
+}
+
+func (c *LRU) Set(entry *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := entry.Size()
+	if size > c.maxBytes {
+		return fmt.Errorf("cache entry %s (%d bytes) exceeds the cache size of %d bytes", entry.Key, size, c.maxBytes)
+	}
+
+	if elem, ok := c.items[entry.Key]; ok {
+		c.removeElement(elem)
+	}
+	c.items[entry.Key] = c.order.PushFront(entry)
+	c.size += size
+
+	for c.size > c.maxBytes {
+		c.removeElement(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	return nil
+}
+
+// Len returns the number of cached entries
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) removeElement(elem *list.Element) {
+	entry := c.order.Remove(elem).(*Entry)
+	delete(c.items, entry.Key)
+	c.size -= entry.Size()
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// sqlitePurgeInterval is how often Set deletes entries past their stale windows
+const sqlitePurgeInterval = time.Minute
+
+// SQLiteStore persists entries in the response_cache table, so the cache
+// survives restarts and can hold more than fits in memory. It is bounded by
+// the total size of its entries, the oldest stored are evicted first.
+type SQLiteStore struct {
+	db       *sql.DB
+	maxBytes int64
+
+	mu        sync.Mutex
+	nextPurge time.Time
+}
+
+func NewSQLiteStore(db *sql.DB, maxBytes int64) *SQLiteStore {
+	return &SQLiteStore{db: db, maxBytes: maxBytes}
+}
+
+func (s *SQLiteStore) Get(key string) (*Entry, bool) {
+	entry := &Entry{Key: key}
+	var header string
+	var staleMs, errorMs int64
+	err := s.db.QueryRow(
+		`SELECT status, header, body, stored_at, expires_at, stale_window_ms, error_window_ms, etag, last_modified
+		FROM response_cache WHERE key = ?`,
+		key,
+	).Scan(&entry.Status, &header, &entry.Body, &entry.StoredAt, &entry.ExpiresAt, &staleMs, &errorMs, &entry.ETag, &entry.LastModified)
+	if err != nil {
+		return nil, false
+	}
+
+	if err := json.Unmarshal([]byte(header), &entry.Header); err != nil {
+		return nil, false
+	}
+	entry.StaleWindow = time.Duration(staleMs) * time.Millisecond
+	entry.ErrorWindow = time.Duration(errorMs) * time.Millisecond
+
+	return entry, true
+}
+
+func (s *SQLiteStore) Set(entry *Entry) error {
+	size := entry.Size()
+	if size > s.maxBytes {
+		return fmt.Errorf("cache entry %s (%d bytes) exceeds the cache size of %d bytes", entry.Key, size, s.maxBytes)
+	}
+	header, err := json.Marshal(entry.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached headers: %w", err)
+	}
+
+	// Past both stale windows the entry can't be served anymore
+	purgeAt := entry.ExpiresAt.Add(max(entry.StaleWindow, entry.ErrorWindow))
+	_, err = s.db.Exec(
+		`INSERT INTO response_cache (key, status, header, body, stored_at, expires_at, stale_window_ms, error_window_ms, etag, last_modified, size, purge_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			status = excluded.status,
+			header = excluded.header,
+			body = excluded.body,
+			stored_at = excluded.stored_at,
+			expires_at = excluded.expires_at,
+			stale_window_ms = excluded.stale_window_ms,
+			error_window_ms = excluded.error_window_ms,
+			etag = excluded.etag,
+			last_modified = excluded.last_modified,
+			size = excluded.size,
+			purge_at = excluded.purge_at`,
+		entry.Key, entry.Status, string(header), entry.Body, entry.StoredAt, entry.ExpiresAt,
+		entry.StaleWindow.Milliseconds(), entry.ErrorWindow.Milliseconds(), entry.ETag, entry.LastModified,
+		size, purgeAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+
+	if err := s.purge(time.Now()); err != nil {
+		return err
+	}
+	return s.evict()
+}
+
+// purge deletes the entries past their stale windows, at most once per
+// sqlitePurgeInterval. Entries stored before sizes were tracked go too.
+func (s *SQLiteStore) purge(now time.Time) error {
+	s.mu.Lock()
+	if now.Before(s.nextPurge) {
+		s.mu.Unlock()
+		return nil
+	}
+	s.nextPurge = now.Add(sqlitePurgeInterval)
+	s.mu.Unlock()
+
+	if _, err := s.db.Exec(`DELETE FROM response_cache WHERE purge_at IS NULL OR purge_at < ?`, now); err != nil {
+		return fmt.Errorf("failed to purge expired cache entries: %w", err)
+	}
+	return nil
+}
+
+// evict deletes the oldest stored entries until the rest fit in maxBytes
+func (s *SQLiteStore) evict() error {
+	_, err := s.db.Exec(
+		`DELETE FROM response_cache WHERE key IN (
+			SELECT key FROM (
+				SELECT key, SUM(size) OVER (ORDER BY stored_at DESC, key DESC) AS total FROM response_cache
+			) WHERE total > ?
+		)`,
+		s.maxBytes,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to evict cache entries: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Delete(key string) error {
+	if _, err := s.db.Exec(`DELETE FROM response_cache WHERE key = ?`, key); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -11,12 +13,35 @@ type DB struct {
 	*sql.DB
 }
 
+// column describes a column added to an existing table after its initial
+// CREATE TABLE, so databases created by older versions get upgraded in place
+type column struct {
+	table      string
+	name       string
+	definition string
+}
+
+// connectionPragmas are applied to every pooled connection. WAL lets writes
+// proceed while a long read, such as a streaming export, holds its cursor open,
+// and busy_timeout makes a writer wait out another writer instead of failing
+// with SQLITE_BUSY.
+var connectionPragmas = []string{
+	"journal_mode(WAL)",
+	"busy_timeout(5000)",
+}
+
 func New(dbPath string) (*DB, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// Every connection to ":memory:" opens a separate empty database, so keep
+	// the pool at a single connection to make in-memory databases usable
+	if dbPath == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
@@ -24,6 +49,20 @@ func New(dbPath string) (*DB, error) {
 	return &DB{db}, nil
 }
 
+// dsn appends connectionPragmas to dbPath as _pragma parameters, keeping any
+// parameters already given
+func dsn(dbPath string) string {
+	params := make([]string, len(connectionPragmas))
+	for i, pragma := range connectionPragmas {
+		params[i] = "_pragma=" + pragma
+	}
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + strings.Join(params, "&")
+}
+
 func (db *DB) RunMigrations() error {
 	queries := []string{
 		`CREATE TABLE IF NOT EXISTS api_requests (
@@ -49,6 +88,28 @@ func (db *DB) RunMigrations() error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_problem_created_at ON problems(created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_problem_request_id ON problems(request_id)`,
+		`CREATE TABLE IF NOT EXISTS response_cache (
+			key TEXT PRIMARY KEY,
+			status INTEGER NOT NULL,
+			header TEXT NOT NULL DEFAULT '{}',
+			body BLOB NOT NULL,
+			stored_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			stale_window_ms INTEGER NOT NULL DEFAULT 0,
+			error_window_ms INTEGER NOT NULL DEFAULT 0,
+			etag TEXT NOT NULL DEFAULT '',
+			last_modified TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS views (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			resource TEXT NOT NULL,
+			filters TEXT NOT NULL DEFAULT '{}',
+			sort TEXT NOT NULL DEFAULT '',
+			columns TEXT NOT NULL DEFAULT '[]',
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
 	}
 
 	for _, query := range queries {
@@ -57,5 +118,128 @@ func (db *DB) RunMigrations() error {
 		}
 	}
 
+	columns := []column{
+		{"api_requests", "response_bytes", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "content_type", "TEXT NOT NULL DEFAULT ''"},
+		{"api_requests", "upstream_headers", "TEXT NOT NULL DEFAULT '{}'"},
+		{"api_requests", "dns_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "connect_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "tls_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "ttfb_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "transfer_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "conn_reused", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "throttle_status", "TEXT NOT NULL DEFAULT ''"},
+		{"api_requests", "queue_wait_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "parent_id", "INTEGER REFERENCES api_requests(id)"},
+		{"api_requests", "attempt", "INTEGER NOT NULL DEFAULT 1"},
+		{"api_requests", "outcome", "TEXT NOT NULL DEFAULT ''"},
+		{"api_requests", "cache_status", "TEXT NOT NULL DEFAULT ''"},
+		{"api_requests", "coalesced", "INTEGER NOT NULL DEFAULT 0"},
+		{"api_requests", "upstream_call_id", "TEXT NOT NULL DEFAULT ''"},
+		{"problems", "rule_version", "TEXT NOT NULL DEFAULT ''"},
+		{"response_cache", "size", "INTEGER NOT NULL DEFAULT 0"},
+		{"response_cache", "purge_at", "DATETIME"},
+	}
+
+	for _, col := range columns {
+		if err := db.addColumnIfMissing(col); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_response_bytes ON api_requests(response_bytes DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_parent_id ON api_requests(parent_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_upstream_call_id ON api_requests(upstream_call_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_response_cache_stored_at ON response_cache(stored_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_response_cache_purge_at ON response_cache(purge_at)`,
+	}
+
+	for _, query := range indexes {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	if err := db.addUniqueProblemIndex(); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	return nil
+}
+
+// addUniqueProblemIndex allows a single problem of each type per request.
+// Databases from before the index may hold duplicates, only the latest of
+// which is kept: it was recorded by the newest rules.
+func (db *DB) addUniqueProblemIndex() error {
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_problem_request_type'").Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect indexes: %w", err)
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	res, err := db.Exec(`DELETE FROM problems WHERE id NOT IN (SELECT MAX(id) FROM problems GROUP BY request_id, problem_type)`)
+	if err != nil {
+		return fmt.Errorf("failed to remove duplicate problems: %w", err)
+	}
+	if removed, err := res.RowsAffected(); err == nil && removed > 0 {
+		log.Printf("Removed %d duplicate problems before adding the unique problem index", removed)
+	}
+	_, err = db.Exec(`CREATE UNIQUE INDEX idx_problem_request_type ON problems(request_id, problem_type)`)
+	if err != nil {
+		return fmt.Errorf("failed to create unique problem index: %w", err)
+	}
+	return nil
+}
+
+// addColumnIfMissing adds col to its table unless a column with the same name
+// already exists (SQLite has no ADD COLUMN IF NOT EXISTS)
+func (db *DB) addColumnIfMissing(col column) error {
+	exists, err := db.hasColumn(col.table, col.name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.name, col.definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", col.table, col.name, err)
+	}
+
 	return nil
 }
+
+func (db *DB) hasColumn(table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("error iterating table info: %w", err)
+	}
+
+	return false, nil
+}
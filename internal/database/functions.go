@@ -0,0 +1,68 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"modernc.org/sqlite"
+)
+
+// maxCompiledPatterns bounds the pattern cache, patterns come from user input
+const maxCompiledPatterns = 128
+
+// compiledPatterns caches the regular expressions used in queries, a query
+// evaluates the same pattern once per row
+var (
+	patternsMu       sync.Mutex
+	compiledPatterns = map[string]*regexp.Regexp{}
+)
+
+func init() {
+	// SQLite rewrites "X REGEXP Y" to regexp(Y, X) but ships no implementation
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, regexpFunction)
+}
+
+func regexpFunction(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("regexp: pattern must be text")
+	}
+
+	var subject string
+	switch v := args[1].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		subject = v
+	case []byte:
+		subject = string(v)
+	default:
+		subject = fmt.Sprint(v)
+	}
+
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(subject), nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+
+	if re, ok := compiledPatterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regexp: %w", err)
+	}
+	if len(compiledPatterns) >= maxCompiledPatterns {
+		clear(compiledPatterns)
+	}
+	compiledPatterns[pattern] = re
+	return re, nil
+}
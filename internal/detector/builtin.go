@@ -0,0 +1,94 @@
+package detector
+
+import (
+	"fmt"
+	"time"
+	"treblle_project/internal/models"
+)
+
+// SlowResponseThresholdMs is the response time from which a slow_response problem is recorded
+const SlowResponseThresholdMs = 400
+
+// LargeResponseThresholdBytes is the response body size above which a large_response problem is recorded
+const LargeResponseThresholdBytes = 2 * 1024 * 1024
+
+// statusProblems describe the problem recorded for an upstream status code
+var statusProblems = map[int]struct {
+	problemType string
+	description string
+}{
+	400: {"bad_request", "The server cannot or will not process the request."},
+	403: {"forbidden", "The request was valid, but the server is refusing action."},
+	404: {"not_found", "The requested resource could not be found."},
+	418: {"im_a_teapot", "The server is literally a teapot"},
+	429: {"rate_limited", "Too many requests were sent to the upstream in a given amount of time."},
+}
+
+// Status records requests that did not get a successful upstream response:
+// throttled, short-circuited and timed out calls, network errors, the client
+// errors of statusProblems and server errors. Placeholders of streamed
+// responses have no outcome yet and are skipped.
+type Status struct{}
+
+func (Status) Detect(req *models.APIRequest) []*models.Problem {
+	switch {
+	case req.Outcome == models.OutcomeStreaming:
+		return nil
+	case req.ThrottleStatus == models.ThrottleRejected:
+		return one(req, "throttled", "The request was rejected by the client-side rate limiter, the upstream was not called.", 0)
+	case req.Outcome == models.OutcomeCircuitOpen:
+		return one(req, "circuit_open", "The request was short-circuited because the upstream circuit breaker is open.", 0)
+	case req.Outcome == models.OutcomeDeadlineExceeded:
+		return one(req, "deadline_exceeded", fmt.Sprintf("The upstream did not respond within the deadline set by the client (%dms).", req.ResponseTimeMs), 0)
+	case req.ResponseStatus == 0:
+		return one(req, "network_error", "The upstream could not be reached or the response could not be read.", 0)
+	case req.ResponseStatus >= 500:
+		return one(req, "server_error", fmt.Sprintf("The upstream failed to fulfil the request (status %d).", req.ResponseStatus), 0)
+	}
+	if p, ok := statusProblems[req.ResponseStatus]; ok {
+		return one(req, p.problemType, p.description, 0)
+	}
+	return nil
+}
+
+// SlowResponse records requests whose response took ThresholdMs or longer.
+// Cached and coalesced responses say nothing about upstream latency, the
+// leader of a coalesced call is checked instead. Calls that got no response,
+// or whose streamed response was never completed, are left to Status.
+type SlowResponse struct {
+	ThresholdMs int64
+}
+
+func (s SlowResponse) Detect(req *models.APIRequest) []*models.Problem {
+	notMeasured := req.CacheStatus == models.CacheHit || req.CacheStatus == models.CacheStale || req.Coalesced
+	noResponse := req.ResponseStatus == 0 || req.Outcome == models.OutcomeCircuitOpen || req.Outcome == models.OutcomeDeadlineExceeded ||
+		req.Outcome == models.OutcomeStreaming
+	if notMeasured || noResponse || req.ResponseTimeMs < s.ThresholdMs {
+		return nil
+	}
+	return one(req, "slow_response", fmt.Sprintf("Response time (%dms) exceeded threshold (%dms)", req.ResponseTimeMs, s.ThresholdMs), s.ThresholdMs)
+}
+
+// LargeResponse records requests whose response body is over ThresholdBytes
+type LargeResponse struct {
+	ThresholdBytes int64
+}
+
+func (l LargeResponse) Detect(req *models.APIRequest) []*models.Problem {
+	if req.ResponseBytes <= l.ThresholdBytes {
+		return nil
+	}
+	return one(req, "large_response", fmt.Sprintf("Response size (%d bytes) exceeded threshold (%d bytes)", req.ResponseBytes, l.ThresholdBytes), 0)
+}
+
+// one returns a single problem of req, tagged with Version
+func one(req *models.APIRequest, problemType, description string, thresholdMs int64) []*models.Problem {
+	return []*models.Problem{{
+		RequestID:   req.ID,
+		ProblemType: problemType,
+		Description: description,
+		ThresholdMs: thresholdMs,
+		RuleVersion: Version,
+		CreatedAt:   time.Now(),
+	}}
+}
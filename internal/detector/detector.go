@@ -0,0 +1,62 @@
+// Package detector finds problems on logged requests. It works on request
+// records alone, so the proxy, imports and re-evaluation share the same rules.
+package detector
+
+import (
+	"treblle_project/internal/models"
+)
+
+// Version tags the problems recorded by the built-in detectors. Bump it
+// whenever a rule or threshold changes, so problems recorded by older rules
+// can be told apart and re-evaluated.
+const Version = "4"
+
+// Types are the problem types the built-in detectors record. Circuit
+// breaker events are problems too, but not of a single request.
+var Types = []string{
+	"throttled", "circuit_open", "deadline_exceeded", "network_error", "bad_request", "forbidden", "not_found",
+	"im_a_teapot", "rate_limited", "server_error", "slow_response", "large_response",
+}
+
+// Detector checks a logged request for problems
+type Detector interface {
+	// Detect returns the problems found on req, none when it is fine
+	Detect(req *models.APIRequest) []*models.Problem
+}
+
+// Func adapts a function to a Detector
+type Func func(req *models.APIRequest) []*models.Problem
+
+func (f Func) Detect(req *models.APIRequest) []*models.Problem {
+	return f(req)
+}
+
+// Chain runs every detector in order and returns all the problems they find
+type Chain []Detector
+
+func (c Chain) Detect(req *models.APIRequest) []*models.Problem {
+	var problems []*models.Problem
+	for _, d := range c {
+		problems = append(problems, d.Detect(req)...)
+	}
+	return problems
+}
+
+// SkipCancelled wraps d so requests the client walked away from have no
+// problems, they are not the upstream's problem
+func SkipCancelled(d Detector) Detector {
+	return Func(func(req *models.APIRequest) []*models.Problem {
+		if req.Outcome == models.OutcomeClientCancelled {
+			return nil
+		}
+		return d.Detect(req)
+	})
+}
+
+// Default is the detector problems are recorded with, every problem of a
+// request is recorded: a slow 404 is both not_found and slow_response
+var Default = SkipCancelled(Chain{
+	Status{},
+	SlowResponse{ThresholdMs: SlowResponseThresholdMs},
+	LargeResponse{ThresholdBytes: LargeResponseThresholdBytes},
+})
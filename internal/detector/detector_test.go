@@ -0,0 +1,87 @@
+package detector
+
+import (
+	"testing"
+	"treblle_project/internal/models"
+)
+
+// types returns the problem types of problems
+func types(problems []*models.Problem) []string {
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.ProblemType)
+	}
+	return got
+}
+
+// Test 1: Built-in detectors record the problem of a request, or none
+func TestBuiltin(t *testing.T) {
+	tests := []struct {
+		name     string
+		detector Detector
+		req      models.APIRequest
+		expected string
+	}{
+		{"circuit open", Status{}, models.APIRequest{Outcome: models.OutcomeCircuitOpen}, "circuit_open"},
+		{"deadline exceeded", Status{}, models.APIRequest{Outcome: models.OutcomeDeadlineExceeded}, "deadline_exceeded"},
+		{"network error", Status{}, models.APIRequest{ResponseStatus: 0}, "network_error"},
+		{"streaming placeholder", Status{}, models.APIRequest{ResponseStatus: 0, Outcome: models.OutcomeStreaming}, ""},
+		{"not found", Status{}, models.APIRequest{ResponseStatus: 404}, "not_found"},
+		{"rate limited", Status{}, models.APIRequest{ResponseStatus: 429}, "rate_limited"},
+		{"throttled", Status{}, models.APIRequest{ResponseStatus: 429, ThrottleStatus: models.ThrottleRejected}, "throttled"},
+		{"server error", Status{}, models.APIRequest{ResponseStatus: 503}, "server_error"},
+		{"ok", Status{}, models.APIRequest{ResponseStatus: 200}, ""},
+		{"other client error", Status{}, models.APIRequest{ResponseStatus: 410}, ""},
+		{"slow", SlowResponse{ThresholdMs: 400}, models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 400}, "slow_response"},
+		{"fast", SlowResponse{ThresholdMs: 400}, models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 399}, ""},
+		{"slow cache hit", SlowResponse{ThresholdMs: 400}, models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 900, CacheStatus: models.CacheHit}, ""},
+		{"slow coalesced", SlowResponse{ThresholdMs: 400}, models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 900, Coalesced: true}, ""},
+		{"slow streaming placeholder", SlowResponse{ThresholdMs: 400}, models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 900, Outcome: models.OutcomeStreaming}, ""},
+		{"slow without response", SlowResponse{ThresholdMs: 400}, models.APIRequest{ResponseStatus: 504, ResponseTimeMs: 900, Outcome: models.OutcomeDeadlineExceeded}, ""},
+		{"large", LargeResponse{ThresholdBytes: 100}, models.APIRequest{ResponseBytes: 101}, "large_response"},
+		{"small", LargeResponse{ThresholdBytes: 100}, models.APIRequest{ResponseBytes: 100}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.ID = 7
+			problems := tt.detector.Detect(&tt.req)
+			if tt.expected == "" {
+				if len(problems) != 0 {
+					t.Errorf("Expected no problem, got %v", types(problems))
+				}
+				return
+			}
+			if len(problems) != 1 || problems[0].ProblemType != tt.expected {
+				t.Fatalf("Expected %s, got %v", tt.expected, types(problems))
+			}
+			if problems[0].RequestID != 7 || problems[0].RuleVersion != Version || problems[0].CreatedAt.IsZero() {
+				t.Errorf("Expected the problem tied to the request and tagged, got %+v", problems[0])
+			}
+		})
+	}
+}
+
+// Test 2: Chain returns every problem of every detector
+func TestChain(t *testing.T) {
+	detectors := []Detector{Status{}, SlowResponse{ThresholdMs: 400}}
+	slowNotFound := &models.APIRequest{ResponseStatus: 404, ResponseTimeMs: 3000}
+
+	if got := types(Chain(detectors).Detect(slowNotFound)); len(got) != 2 || got[0] != "not_found" || got[1] != "slow_response" {
+		t.Errorf("Expected Chain to find not_found and slow_response, got %v", got)
+	}
+	if got := Chain(detectors).Detect(&models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 10}); len(got) != 0 {
+		t.Errorf("Expected no problems, got %v", types(got))
+	}
+}
+
+// Test 3: Requests the client walked away from have no problems
+func TestSkipCancelled(t *testing.T) {
+	req := &models.APIRequest{ResponseStatus: 0, ResponseTimeMs: 3000, Outcome: models.OutcomeClientCancelled}
+	if got := Default.Detect(req); len(got) != 0 {
+		t.Errorf("Expected no problems for a cancelled request, got %v", types(got))
+	}
+	req.Outcome = ""
+	if got := types(Default.Detect(req)); len(got) != 1 || got[0] != "network_error" {
+		t.Errorf("Expected network_error, got %v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"treblle_project/internal/reevaluation"
+	"treblle_project/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	job *reevaluation.Job
+}
+
+func NewAdminHandler(requests *repository.RequestRepository, problems *repository.ProblemRepository) *AdminHandler {
+	return &AdminHandler{job: reevaluation.NewJob(requests, problems)}
+}
+
+// RequireAdminToken lets through requests authenticated with token as a
+// bearer token, answering 401 to the others. With an empty token nothing is
+// let through: the endpoint answers 503 until a token is configured.
+func RequireAdminToken(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "this endpoint is disabled, set ADMIN_TOKEN to enable it"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "a valid admin token is required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Reevaluate godoc
+// @Summary      Re-evaluate problem detection
+// @Description  Replay the current detection rules over the requests logged in a time range and report the problems they add and remove compared to the stored ones. A dry run by default; with apply=true the stored problems are changed, added ones and the ones found again tagged with the current rule version. Circuit breaker problems are left alone.
+// @Tags         admin, problems
+// @Produce      json
+// @Security     AdminToken
+// @Param        created_after  query    string  false  "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only requests created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC)"
+// @Param        apply          query    bool    false  "Store the changes instead of only reporting them (default: false)"
+// @Success      200  {object}  reevaluation.Report  "Problems added and removed by the current rules"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
+// @Failure      401  {object}  map[string]interface{}  "Missing or invalid admin token"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error, pages changed before it are kept"
+// @Router       /admin/reevaluate [post]
+func (h *AdminHandler) Reevaluate(c *gin.Context) {
+	p := newQueryParser(c.Request.URL.Query())
+	opts := reevaluation.Options{Rules: reevaluation.DefaultRules}
+	loc := p.location()
+	p.time("created_after", &opts.From, loc)
+	p.time("created_before", &opts.To, loc)
+	p.bool("apply", &opts.Apply)
+	if !opts.From.IsZero() && !opts.To.IsZero() {
+		p.ensure(opts.From.Before(opts.To), "created_before", "must be after created_after")
+	}
+	if err := p.err(); err != nil {
+		listError(c, err)
+		return
+	}
+
+	report, err := h.job.Run(opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
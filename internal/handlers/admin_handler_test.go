@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"treblle_project/internal/detector"
+	"treblle_project/internal/reevaluation"
+	"treblle_project/internal/repository"
+	"treblle_project/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Test 1: Reevaluate reports missing problems in a dry run and records them with apply=true
+func TestReevaluate(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+	testutil.CreateTestRequest(t, requestRepo, "GET", "/anime/999", 404, 100)
+	testutil.CreateTestRequest(t, requestRepo, "GET", "/anime/1", 200, 100)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/admin/reevaluate", NewAdminHandler(requestRepo, problemRepo).Reevaluate)
+
+	run := func(query string) (*httptest.ResponseRecorder, reevaluation.Report) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/reevaluate"+query, nil))
+		var report reevaluation.Report
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w, report
+	}
+
+	w, report := run("?created_after=-1h")
+	if w.Code != http.StatusOK || report.Applied || report.Checked != 2 || report.AddedCount != 1 || report.Added[0].ProblemType != "not_found" {
+		t.Fatalf("Unexpected dry run %d %s", w.Code, w.Body.String())
+	}
+	if count, _ := problemRepo.Count(repository.ProblemFilters{}); count != 0 {
+		t.Errorf("Expected a dry run to store nothing, got %d problems", count)
+	}
+
+	w, report = run("?created_after=-1h&apply=true")
+	if w.Code != http.StatusOK || !report.Applied || report.AddedCount != 1 {
+		t.Fatalf("Unexpected run %d %s", w.Code, w.Body.String())
+	}
+	problems, err := problemRepo.List(repository.ProblemFilters{})
+	if err != nil || len(problems) != 1 || problems[0].RuleVersion != detector.Version {
+		t.Errorf("Expected not_found tagged with rule version %s, got %+v (%v)", detector.Version, problems, err)
+	}
+
+	for _, query := range []string{"?created_after=yesterday", "?created_after=-1h&created_before=-2h", "?apply=maybe"} {
+		w, _ := run(query)
+		if w.Code != http.StatusBadRequest || !strings.HasPrefix(w.Header().Get("Content-Type"), ProblemJSONContentType) {
+			t.Errorf("%s: expected 400 problem details, got %d %s", query, w.Code, w.Body.String())
+		}
+	}
+}
+
+// Test 2: Maintenance endpoints require the admin token, and are disabled without one
+func TestRequireAdminToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/admin/ping", RequireAdminToken("s3cret"), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		authorization string
+		expected      int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", http.StatusUnauthorized},
+		{"Bearer s3cret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/admin/ping", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != tt.expected {
+			t.Errorf("%q: expected %d, got %d", tt.authorization, tt.expected, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%q: expected a WWW-Authenticate challenge", tt.authorization)
+		}
+	}
+
+	// Without a configured token the endpoint stays disabled, whatever is sent
+	router.POST("/api/requests/import", RequireAdminToken(""), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	for _, authorization := range []string{"", "Bearer ", "Bearer s3cret"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/requests/import", nil)
+		req.Header.Set("Authorization", authorization)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%q: expected 503 without a configured token, got %d", authorization, w.Code)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time formats of the time_format parameter
+const (
+	TimeFormatRFC3339     = "rfc3339"
+	TimeFormatRFC3339Nano = "rfc3339nano"
+	TimeFormatDateTime    = "datetime" // 2006-01-02 15:04:05, read as a date by spreadsheets
+	TimeFormatUnix        = "unix"
+	TimeFormatUnixMilli   = "unix_ms"
+)
+
+var timeFormats = []string{TimeFormatRFC3339, TimeFormatRFC3339Nano, TimeFormatDateTime, TimeFormatUnix, TimeFormatUnixMilli}
+
+// column is one column of the table and export views of T. value returns a
+// string, an integer, an *int for an optional integer, a bool, a time.Time
+// or a map. Its type for a zero T is the type of the column.
+type column[T any] struct {
+	name  string
+	value func(*T) any
+}
+
+// columnSet is the registry of every column of a resource, shared by its
+// table and CSV views
+type columnSet[T any] struct {
+	columns  []column[T]
+	defaults []string // Shown when no fields are picked
+}
+
+// names lists every column name in registry order
+func (s columnSet[T]) names() []string {
+	names := make([]string, len(s.columns))
+	for i, col := range s.columns {
+		names[i] = col.name
+	}
+	return names
+}
+
+func (s columnSet[T]) lookup(name string) (column[T], bool) {
+	i := slices.IndexFunc(s.columns, func(col column[T]) bool { return col.name == name })
+	if i < 0 {
+		return column[T]{}, false
+	}
+	return s.columns[i], true
+}
+
+// tableFormat is how a table view or an export renders rows of T
+type tableFormat[T any] struct {
+	columns    []column[T]
+	timeFormat string
+	loc        *time.Location // Zone of output times, the stored zone when nil
+}
+
+// tableFormatOf reads the fields, time_format and tz parameters of a table
+// or CSV view of set
+func tableFormatOf[T any](p *queryParser, set columnSet[T]) tableFormat[T] {
+	f := tableFormat[T]{timeFormat: TimeFormatRFC3339}
+
+	names := set.defaults
+	if value := p.query.Get("fields"); value != "" {
+		names = strings.Split(value, ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+	}
+	for i, name := range names {
+		col, ok := set.lookup(name)
+		switch {
+		case !ok:
+			p.reject("fields", "unknown field %q, expected one of %s", name, strings.Join(set.names(), ", "))
+		case slices.Contains(names[:i], name):
+			p.reject("fields", "duplicate field %q", name)
+		default:
+			f.columns = append(f.columns, col)
+		}
+	}
+
+	p.oneOf("time_format", &f.timeFormat, timeFormats...)
+	if p.query.Get("tz") != "" {
+		f.loc = p.location()
+	}
+	return f
+}
+
+// header returns the column names
+func (f tableFormat[T]) header() []string {
+	names := make([]string, len(f.columns))
+	for i, col := range f.columns {
+		names[i] = col.name
+	}
+	return names
+}
+
+// values returns the values of item, with times in the output zone and
+// optional integers as an int or nil
+func (f tableFormat[T]) values(item *T) []any {
+	values := make([]any, len(f.columns))
+	for i, col := range f.columns {
+		switch v := col.value(item).(type) {
+		case *int:
+			if v != nil {
+				values[i] = *v
+			}
+		case time.Time:
+			if f.loc != nil {
+				v = v.In(f.loc)
+			}
+			values[i] = v
+		default:
+			values[i] = v
+		}
+	}
+	return values
+}
+
+// row returns the values of item for a JSON table
+func (f tableFormat[T]) row(item *T) []any {
+	values := f.values(item)
+	for i, value := range values {
+		values[i] = f.render(value)
+	}
+	return values
+}
+
+// render returns value with times in the configured format
+func (f tableFormat[T]) render(value any) any {
+	if t, ok := value.(time.Time); ok {
+		return f.formatTime(t)
+	}
+	return value
+}
+
+// layout describes the columns of f to the encoder of an export named name
+func (f tableFormat[T]) layout(name string) exportLayout {
+	var zero T
+	layout := exportLayout{name: name, columns: f.header(), samples: make([]any, len(f.columns)), render: f.render}
+	for i, col := range f.columns {
+		layout.samples[i] = col.value(&zero)
+	}
+	return layout
+}
+
+// formatTime renders t in the configured format
+func (f tableFormat[T]) formatTime(t time.Time) any {
+	switch f.timeFormat {
+	case TimeFormatRFC3339Nano:
+		return t.Format(time.RFC3339Nano)
+	case TimeFormatDateTime:
+		return t.Format(time.DateTime)
+	case TimeFormatUnix:
+		return t.Unix()
+	case TimeFormatUnixMilli:
+		return t.UnixMilli()
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatCell renders a column value as text
+func formatCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]string:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushRows is how many rows a streamed export writes between flushes
+const exportFlushRows = 500
+
+// File formats of the format parameter of the export endpoints
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatXLSX    = "xlsx"
+	ExportFormatParquet = "parquet"
+)
+
+// exportFormat is a file format of the export endpoints
+type exportFormat struct {
+	contentType string
+	ext         string
+	compress    bool // Gzip when accepted, off for formats compressed already
+	maxRows     int  // Most rows a file holds, 0 for no limit
+	encoder     func(io.Writer, exportLayout) (exportEncoder, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	ExportFormatCSV:    {contentType: "text/csv", ext: "csv", compress: true, encoder: newCSVEncoder},
+	ExportFormatNDJSON: {contentType: "application/x-ndjson", ext: "ndjson", compress: true, encoder: newNDJSONEncoder},
+	ExportFormatXLSX: {contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ext: "xlsx",
+		maxRows: xlsxMaxRows, encoder: newXLSXEncoder},
+	ExportFormatParquet: {contentType: "application/vnd.apache.parquet", ext: "parquet", encoder: newParquetEncoder},
+}
+
+// exportFormatOf reads the format parameter of the export endpoints
+func exportFormatOf(p *queryParser) string {
+	name := ExportFormatCSV
+	p.oneOf("format", &name, ExportFormatCSV, ExportFormatNDJSON, ExportFormatXLSX, ExportFormatParquet)
+	return name
+}
+
+// exportLayout describes the exported columns to an encoder
+type exportLayout struct {
+	name    string          // Resource exported, e.g. requests
+	columns []string        // Column names in order
+	samples []any           // Value of each column for a zero item, its type tells the column type
+	render  func(v any) any // Renders a value as text formats show it, times in the time_format
+}
+
+// exportEncoder writes the rows of an export in one file format. Values are
+// those of tableFormat.values, in the order of the layout columns.
+type exportEncoder interface {
+	writeRow(values []any) error
+	flush() error // Hands buffered rows to the underlying writer
+	close() error // Writes what ends the file
+}
+
+// csvEncoder writes CSV with a header line
+type csvEncoder struct {
+	w      *csv.Writer
+	layout exportLayout
+}
+
+func newCSVEncoder(w io.Writer, layout exportLayout) (exportEncoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w), layout: layout}
+	return e, e.w.Write(layout.columns)
+}
+
+func (e *csvEncoder) writeRow(values []any) error {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = formatCell(e.layout.render(value))
+	}
+	return e.w.Write(fields)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) close() error {
+	return e.flush()
+}
+
+// ndjsonEncoder writes one JSON object per line, keys in column order
+type ndjsonEncoder struct {
+	w      *bufio.Writer
+	layout exportLayout
+	buf    bytes.Buffer
+	enc    *json.Encoder // Encodes into buf, leaving <, > and & as they are
+}
+
+func newNDJSONEncoder(w io.Writer, layout exportLayout) (exportEncoder, error) {
+	e := &ndjsonEncoder{w: bufio.NewWriter(w), layout: layout}
+	e.enc = json.NewEncoder(&e.buf)
+	e.enc.SetEscapeHTML(false)
+	return e, nil
+}
+
+func (e *ndjsonEncoder) writeRow(values []any) error {
+	e.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		if err := e.encode(e.layout.columns[i]); err != nil {
+			return err
+		}
+		e.w.WriteByte(':')
+		if err := e.encode(e.layout.render(value)); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", e.layout.columns[i], err)
+		}
+	}
+	e.w.WriteByte('}')
+	return e.w.WriteByte('\n')
+}
+
+// encode writes v as JSON
+func (e *ndjsonEncoder) encode(v any) error {
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := e.w.Write(bytes.TrimSuffix(e.buf.Bytes(), []byte("\n")))
+	return err
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return e.w.Flush()
+}
+
+func (e *ndjsonEncoder) close() error {
+	return e.flush()
+}
+
+// exportFilenameParams are the filters named in export filenames, in order
+var exportFilenameParams = []struct {
+	param  string
+	prefix string
+}{
+	{"method", ""},
+	{"response", ""},
+	{"problem_type", ""},
+	{"created_after", "from-"},
+	{"created_before", "to-"},
+}
+
+// unsafeFilenameRun matches what export filenames replace with a dash
+var unsafeFilenameRun = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// exportFilename names the export of resource after its view, its main
+// filters and the current UTC time, e.g. requests-get-5xx-20261018T120000Z.csv
+func exportFilename(c *gin.Context, resource, ext string) string {
+	params := listParams(c)
+	parts := []string{resource}
+	if view := c.Query("view"); view != "" {
+		parts = append(parts, view)
+	}
+	for _, f := range exportFilenameParams {
+		value := strings.Trim(unsafeFilenameRun.ReplaceAllString(strings.ToLower(params.Get(f.param)), "-"), "-.")
+		if len(value) > 40 {
+			value = strings.Trim(value[:40], "-.")
+		}
+		if value != "" {
+			parts = append(parts, f.prefix+value)
+		}
+	}
+	parts = append(parts, time.Now().UTC().Format("20060102T150405Z"))
+	return strings.Join(parts, "-") + "." + ext
+}
+
+// acceptsGzip reports whether the client accepts gzip encoded responses
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(params), "q="), 64)
+		return strings.TrimSpace(params) == "" || (err == nil && q > 0)
+	}
+	return false
+}
+
+// exportStream is the body of a streamed download, gzip compressed when the
+// client accepts it
+type exportStream struct {
+	c  *gin.Context
+	w  io.Writer
+	gz *gzip.Writer
+}
+
+// newExportStream sets the headers of a download of file named filename and
+// returns its body
+func newExportStream(c *gin.Context, file exportFormat, filename string) *exportStream {
+	c.Header("Content-Type", file.contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	s := &exportStream{c: c, w: c.Writer}
+	if !file.compress {
+		return s
+	}
+	c.Header("Vary", "Accept-Encoding")
+	if acceptsGzip(c.Request) {
+		c.Header("Content-Encoding", "gzip")
+		s.gz = gzip.NewWriter(c.Writer)
+		s.w = s.gz
+	}
+	return s
+}
+
+func (s *exportStream) Write(b []byte) (int, error) {
+	return s.w.Write(b)
+}
+
+// Flush sends what was written so far to the client
+func (s *exportStream) Flush() error {
+	if s.gz != nil {
+		if err := s.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+// Close ends the body
+func (s *exportStream) Close() error {
+	if s.gz != nil {
+		return s.gz.Close()
+	}
+	return nil
+}
+
+// fail reports err as problem details when nothing was sent yet. Once rows
+// went out the status can't change anymore, so the error is only recorded.
+func (s *exportStream) fail(err error) {
+	if !s.c.Writer.Written() {
+		s.c.Writer.Header().Del("Content-Disposition")
+		s.c.Writer.Header().Del("Content-Encoding")
+		listError(s.c, err)
+		return
+	}
+	s.c.Error(err)
+}
+
+// exportSource is where an export reads its rows
+type exportSource[T any] struct {
+	each      func(fn func(*T) error) error
+	count     func() (int, error)
+	limit     int  // Most rows to export, 0 for every row
+	withTotal bool // Set the total count header
+}
+
+// writeExport streams the rows of src as a download of resource in file
+// format, columns and times rendered as format says
+func writeExport[T any](c *gin.Context, resource string, file exportFormat, format tableFormat[T], src exportSource[T]) {
+	if src.withTotal || file.maxRows > 0 {
+		total, err := src.count()
+		if err != nil {
+			listError(c, err)
+			return
+		}
+		if src.withTotal {
+			c.Header(TotalCountHeader, strconv.Itoa(total))
+		}
+		if src.limit > 0 {
+			total = min(total, src.limit)
+		}
+		if file.maxRows > 0 && total > file.maxRows {
+			reason := fmt.Sprintf("%s files hold at most %d rows but %d match, narrow the filters or lower the limit", file.ext, file.maxRows, total)
+			writeParamError(c, &ParamError{Params: []InvalidParam{{Name: "limit", Reason: reason}}})
+			return
+		}
+	}
+
+	stream := newExportStream(c, file, exportFilename(c, resource, file.ext))
+	encoder, err := file.encoder(stream, format.layout(resource))
+	if err != nil {
+		stream.fail(err)
+		return
+	}
+
+	rows := 0
+	err = src.each(func(item *T) error {
+		if err := encoder.writeRow(format.values(item)); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := encoder.flush(); err != nil {
+				return err
+			}
+			return stream.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = encoder.close()
+	}
+	if err == nil {
+		err = stream.Close()
+	}
+	if err != nil {
+		stream.fail(err)
+	}
+}
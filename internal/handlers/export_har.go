@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"treblle_project/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// harFormat is the file format of HAR exports
+var harFormat = exportFormat{contentType: "application/json", ext: "har", compress: true}
+
+// harCreator names this application in HAR documents
+var harCreator = harNameVersion{Name: "Treblle API Monitor", Version: "1.1.1"}
+
+// HAR 1.2 document, see http://www.softwareishard.com/blog/har-12-spec/.
+// Fields starting with _ are custom fields carrying what the monitor logs.
+type harNameVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// harTimings are in milliseconds, -1 when a phase didn't happen. connect
+// includes ssl.
+type harTimings struct {
+	Blocked int64 `json:"blocked"`
+	DNS     int64 `json:"dns"`
+	Connect int64 `json:"connect"`
+	SSL     int64 `json:"ssl"`
+	Send    int64 `json:"send"`
+	Wait    int64 `json:"wait"`
+	Receive int64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int64       `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Connection      string      `json:"connection,omitempty"`
+
+	ID             int    `json:"_id"`
+	Outcome        string `json:"_outcome,omitempty"`
+	CacheStatus    string `json:"_cacheStatus,omitempty"`
+	ThrottleStatus string `json:"_throttleStatus,omitempty"`
+	Coalesced      bool   `json:"_coalesced,omitempty"`
+	ParentID       *int   `json:"_parentId,omitempty"`
+	Attempt        int    `json:"_attempt"`
+}
+
+// harEntryOf converts a logged request to a HAR entry for the upstream at
+// baseURL. Only the recorded upstream response headers are known, and bodies
+// aren't logged, so the content has a size but no text.
+func harEntryOf(r *models.APIRequest, baseURL string) harEntry {
+	timings := harTimingsOf(r)
+	total := timings.Blocked + timings.Send + timings.Wait + timings.Receive
+	for _, phase := range []int64{timings.DNS, timings.Connect} {
+		total += max(phase, 0)
+	}
+	started := r.CreatedAt.Add(-time.Duration(total) * time.Millisecond) // Logged when the call completed
+
+	request := harRequest{
+		Method:      r.Method,
+		URL:         strings.TrimSuffix(baseURL, "/") + r.Path,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     []harNameValue{},
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+	}
+	if u, err := url.Parse(request.URL); err == nil {
+		query := u.Query()
+		for _, name := range slices.Sorted(maps.Keys(query)) {
+			for _, value := range query[name] {
+				request.QueryString = append(request.QueryString, harNameValue{name, value})
+			}
+		}
+	}
+
+	response := harResponse{
+		Status:      r.ResponseStatus,
+		StatusText:  http.StatusText(r.ResponseStatus),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     []harNameValue{},
+		Content:     harContent{Size: r.ResponseBytes, MimeType: r.ContentType},
+		HeadersSize: -1,
+		BodySize:    r.ResponseBytes,
+	}
+	if r.ContentType != "" {
+		response.Headers = append(response.Headers, harNameValue{"Content-Type", r.ContentType})
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.UpstreamHeaders)) {
+		response.Headers = append(response.Headers, harNameValue{name, r.UpstreamHeaders[name]})
+	}
+
+	return harEntry{
+		StartedDateTime: started.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            total,
+		Request:         request,
+		Response:        response,
+		Timings:         timings,
+		Connection:      r.UpstreamCallID,
+		ID:              r.ID,
+		Outcome:         r.Outcome,
+		CacheStatus:     r.CacheStatus,
+		ThrottleStatus:  r.ThrottleStatus,
+		Coalesced:       r.Coalesced,
+		ParentID:        r.ParentID,
+		Attempt:         r.Attempt,
+	}
+}
+
+// harTimingsOf maps the latency breakdown of r to HAR phases. Time queued
+// for a rate limit token is blocked time, and phases of a reused connection
+// are -1. Requests answered without a traced upstream call, such as cache
+// hits, spend their whole response time waiting.
+func harTimingsOf(r *models.APIRequest) harTimings {
+	t := harTimings{Blocked: r.QueueWaitMs, DNS: -1, Connect: -1, SSL: -1}
+	timing := r.Timing
+	if timing.TTFBMs == 0 && timing.TransferMs == 0 {
+		t.Wait = r.ResponseTimeMs
+		return t
+	}
+
+	if !timing.ConnReused {
+		t.DNS = timing.DNSMs
+		t.Connect = timing.ConnectMs + timing.TLSMs
+		t.SSL = timing.TLSMs
+	}
+	// TTFB runs from the start of the request, connection setup included
+	t.Wait = max(timing.TTFBMs-max(t.DNS, 0)-max(t.Connect, 0), 0)
+	t.Receive = timing.TransferMs
+	return t
+}
+
+// writeHAR streams the requests of src as a HAR document of calls to the
+// upstream at baseURL
+func writeHAR(c *gin.Context, baseURL string, src exportSource[models.APIRequest]) {
+	if src.withTotal {
+		total, err := src.count()
+		if err != nil {
+			listError(c, err)
+			return
+		}
+		c.Header(TotalCountHeader, strconv.Itoa(total))
+	}
+
+	stream := newExportStream(c, harFormat, exportFilename(c, "requests", harFormat.ext))
+	w := bufio.NewWriter(stream)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	creator, _ := json.Marshal(harCreator)
+	w.WriteString(`{"log":{"version":"1.2","creator":` + string(creator) + `,"pages":[],"entries":[`)
+
+	rows := 0
+	err := src.each(func(r *models.APIRequest) error {
+		if rows > 0 {
+			w.WriteByte(',')
+		}
+		if err := enc.Encode(harEntryOf(r, baseURL)); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			return stream.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		w.WriteString("]}}\n")
+		err = w.Flush()
+	}
+	if err == nil {
+		err = stream.Close()
+	}
+	if err != nil {
+		stream.fail(err)
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// xlsxMaxRows is how many rows below the header row an Excel sheet holds
+const xlsxMaxRows = 1<<20 - 1
+
+// xlsxMaxCellLength is the longest text an Excel cell holds
+const xlsxMaxCellLength = 32767
+
+// Cell styles of xlsxStyles
+const (
+	xlsxStyleDateTime = 1
+	xlsxStyleHeader   = 2
+)
+
+// xlsxEpoch is day zero of Excel serial dates
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+
+// xlsxStyles defines the default style, a date and time style and a bold header style
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs></styleSheet>`
+
+// xlsxEncoder writes an Excel workbook with one sheet, streaming rows into
+// the sheet entry of the zip archive. Text is written inline rather than in
+// a shared strings table, which would have to be held until the end.
+type xlsxEncoder struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+}
+
+func newXLSXEncoder(w io.Writer, layout exportLayout) (exportEncoder, error) {
+	e := &xlsxEncoder{zip: zip.NewWriter(w)}
+
+	sheetName := layout.name
+	if sheetName == "" {
+		sheetName = "export"
+	}
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` + xmlEscape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	}
+	for _, part := range parts {
+		f, err := e.zip.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	e.sheet = bufio.NewWriter(sheet)
+	e.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`)
+
+	// The header row is bold and frozen
+	e.sheet.WriteString("<row>")
+	for _, name := range layout.columns {
+		e.writeText(name, xlsxStyleHeader)
+	}
+	e.sheet.WriteString("</row>")
+	return e, nil
+}
+
+func (e *xlsxEncoder) writeRow(values []any) error {
+	e.sheet.WriteString("<row>")
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			e.sheet.WriteString("<c/>")
+		case string:
+			e.writeText(v, 0)
+		case int:
+			e.writeNumber(strconv.Itoa(v), 0)
+		case int64:
+			e.writeNumber(strconv.FormatInt(v, 10), 0)
+		case bool:
+			b := "0"
+			if v {
+				b = "1"
+			}
+			e.sheet.WriteString(`<c t="b"><v>` + b + `</v></c>`)
+		case time.Time:
+			e.writeNumber(strconv.FormatFloat(xlsxSerialTime(v), 'f', -1, 64), xlsxStyleDateTime)
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			e.writeText(string(encoded), 0)
+		}
+	}
+	_, err := e.sheet.WriteString("</row>")
+	return err
+}
+
+func (e *xlsxEncoder) flush() error {
+	return e.sheet.Flush()
+}
+
+func (e *xlsxEncoder) close() error {
+	e.sheet.WriteString("</sheetData></worksheet>")
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+// writeText writes an inline string cell, cut to the length a cell holds
+func (e *xlsxEncoder) writeText(s string, style int) {
+	if len(s) > xlsxMaxCellLength {
+		s = s[:xlsxMaxCellLength]
+		for !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+	}
+	e.sheet.WriteString(`<c t="inlineStr"` + xlsxStyleAttr(style) + `><is><t xml:space="preserve">` + xmlEscape(s) + `</t></is></c>`)
+}
+
+// writeNumber writes a numeric cell
+func (e *xlsxEncoder) writeNumber(n string, style int) {
+	e.sheet.WriteString(`<c` + xlsxStyleAttr(style) + `><v>` + n + `</v></c>`)
+}
+
+func xlsxStyleAttr(style int) string {
+	if style == 0 {
+		return ""
+	}
+	return ` s="` + strconv.Itoa(style) + `"`
+}
+
+// xlsxSerialTime converts the wall clock of t to an Excel serial date, the
+// number of days since xlsxEpoch. Excel has no time zones.
+func xlsxSerialTime(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return float64(wall.Sub(xlsxEpoch).Milliseconds()) / float64(24*time.Hour/time.Millisecond)
+}
+
+// xmlEscape escapes s as XML text, replacing characters XML can't hold
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
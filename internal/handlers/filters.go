@@ -0,0 +1,611 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"treblle_project/internal/models"
+	"treblle_project/internal/query"
+	"treblle_project/internal/repository"
+	"treblle_project/internal/timeparse"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultLimit = 100  // Page size when no limit is given
+	MaxLimit     = 1000 // Largest accepted limit
+)
+
+// ProblemJSONContentType is the media type of RFC 7807 problem details
+const ProblemJSONContentType = "application/problem+json"
+
+// knownMethods are the HTTP methods accepted by the method filter
+var knownMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// InvalidParam describes one rejected query parameter
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ParamError lists every invalid query parameter of a request
+type ParamError struct {
+	Params []InvalidParam
+}
+
+func (e *ParamError) Error() string {
+	reasons := make([]string, len(e.Params))
+	for i, p := range e.Params {
+		reasons[i] = p.Name + ": " + p.Reason
+	}
+	return "invalid query parameters: " + strings.Join(reasons, "; ")
+}
+
+// writeParamError responds with RFC 7807 problem details listing the invalid parameters
+func writeParamError(c *gin.Context, e *ParamError) {
+	writeInvalidParams(c, e.Params, "query parameter")
+}
+
+// writeInvalidParams responds with RFC 7807 problem details listing invalid
+// params, each one a noun such as "query parameter"
+func writeInvalidParams(c *gin.Context, params []InvalidParam, noun string) {
+	detail := "1 " + noun + " is invalid"
+	if len(params) != 1 {
+		detail = fmt.Sprintf("%d %ss are invalid", len(params), noun)
+	}
+
+	// Set first, c.JSON keeps an existing Content-Type
+	c.Header("Content-Type", ProblemJSONContentType)
+	c.JSON(http.StatusBadRequest, gin.H{
+		"type":           "about:blank",
+		"title":          http.StatusText(http.StatusBadRequest),
+		"status":         http.StatusBadRequest,
+		"detail":         detail,
+		"instance":       c.Request.URL.RequestURI(),
+		"invalid_params": params,
+	})
+}
+
+// queryParser reads typed query parameters, collecting every invalid one
+// instead of stopping at the first
+type queryParser struct {
+	query   url.Values
+	now     time.Time      // Relative times are resolved against this
+	loc     *time.Location // Read from tz on first use
+	export  bool           // Exports have neither a default nor a maximum limit
+	invalid []InvalidParam
+}
+
+func newQueryParser(query url.Values) *queryParser {
+	return &queryParser{query: query, now: time.Now()}
+}
+
+func (p *queryParser) reject(name, format string, args ...any) {
+	p.invalid = append(p.invalid, InvalidParam{Name: name, Reason: fmt.Sprintf(format, args...)})
+}
+
+// err returns a *ParamError when any parameter was rejected
+func (p *queryParser) err() error {
+	if len(p.invalid) == 0 {
+		return nil
+	}
+	return &ParamError{Params: p.invalid}
+}
+
+// int64 parses an integer in [min, max] into dst
+func (p *queryParser) int64(name string, dst *int64, min, max int64) {
+	value := p.query.Get(name)
+	if value == "" {
+		return
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		p.reject(name, "must be an integer, got %q", value)
+		return
+	}
+	if n < min || n > max {
+		p.reject(name, "must be between %d and %d, got %d", min, max, n)
+		return
+	}
+	*dst = n
+}
+
+// int parses an integer in [min, max] into dst
+func (p *queryParser) int(name string, dst *int, min, max int) {
+	n := int64(*dst)
+	p.int64(name, &n, int64(min), int64(max))
+	*dst = int(n)
+}
+
+// bool parses a boolean into dst
+func (p *queryParser) bool(name string, dst *bool) {
+	value := p.query.Get(name)
+	if value == "" {
+		return
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.reject(name, "must be true or false, got %q", value)
+		return
+	}
+	*dst = b
+}
+
+// optionalBool parses a boolean into dst, leaving it nil when absent
+func (p *queryParser) optionalBool(name string, dst **bool) {
+	var b bool
+	if p.query.Get(name) == "" {
+		return
+	}
+	before := len(p.invalid)
+	p.bool(name, &b)
+	if len(p.invalid) == before {
+		*dst = &b
+	}
+}
+
+// location reads the tz parameter, UTC when absent
+func (p *queryParser) location() *time.Location {
+	if p.loc != nil {
+		return p.loc
+	}
+	p.loc = time.UTC
+	if name := p.query.Get("tz"); name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			p.reject("tz", "must be an IANA time zone such as Europe/Zagreb, got %q", name)
+		} else {
+			p.loc = loc
+		}
+	}
+	return p.loc
+}
+
+// time parses a time filter (see timeparse.Parse) into dst, reading zone-less values in loc
+func (p *queryParser) time(name string, dst *time.Time, loc *time.Location) {
+	value := p.query.Get(name)
+	if value == "" {
+		return
+	}
+	t, err := timeparse.Parse(value, p.now, loc)
+	if err != nil {
+		p.reject(name, "%s, got %q", err, value)
+		return
+	}
+	*dst = t
+}
+
+// oneOf reads a value that must be one of allowed into dst
+func (p *queryParser) oneOf(name string, dst *string, allowed ...string) {
+	value := p.query.Get(name)
+	if value == "" {
+		return
+	}
+	if !slices.Contains(allowed, value) {
+		p.reject(name, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+		return
+	}
+	*dst = value
+}
+
+// list splits a comma separated parameter into included and excluded
+// ("!" prefixed) items, rejecting empty items
+func (p *queryParser) list(name string) (include, exclude []string, ok bool) {
+	value := p.query.Get(name)
+	if value == "" {
+		return nil, nil, true
+	}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		negated := strings.HasPrefix(item, "!")
+		item = strings.TrimSpace(strings.TrimPrefix(item, "!"))
+		if item == "" {
+			p.reject(name, "must be a comma separated list without empty items, got %q", value)
+			return nil, nil, false
+		}
+		if negated {
+			exclude = append(exclude, item)
+		} else {
+			include = append(include, item)
+		}
+	}
+	return include, exclude, true
+}
+
+// methods reads a list of HTTP methods, case-insensitively
+func (p *queryParser) methods(dst *repository.ValueFilter) {
+	include, exclude, ok := p.list("method")
+	if !ok {
+		return
+	}
+	var filter repository.ValueFilter
+	for _, items := range []struct {
+		values []string
+		dst    *[]string
+	}{{include, &filter.Include}, {exclude, &filter.Exclude}} {
+		for _, method := range items.values {
+			method = strings.ToUpper(method)
+			if !slices.Contains(knownMethods, method) {
+				p.reject("method", "must be one of %s, got %q", strings.Join(knownMethods, ", "), method)
+				return
+			}
+			*items.dst = append(*items.dst, method)
+		}
+	}
+	*dst = filter
+}
+
+// statuses reads a list of statuses (404), classes (5xx) and ranges (500-504)
+func (p *queryParser) statuses(name string, dst *repository.StatusFilter) {
+	include, exclude, ok := p.list(name)
+	if !ok {
+		return
+	}
+	var filter repository.StatusFilter
+	for _, items := range []struct {
+		values []string
+		dst    *[]repository.StatusRange
+	}{{include, &filter.Include}, {exclude, &filter.Exclude}} {
+		for _, item := range items.values {
+			r, err := parseStatusRange(item)
+			if err != nil {
+				p.reject(name, "%s, got %q", err, item)
+				return
+			}
+			*items.dst = append(*items.dst, r)
+		}
+	}
+	*dst = filter
+}
+
+var errStatusFormat = errors.New("must be statuses between 100 and 599 (404), classes (5xx) or ranges (500-504), optionally negated with !")
+
+// parseStatusRange reads a status, a status class or a status range
+func parseStatusRange(item string) (repository.StatusRange, error) {
+	status := func(s string) (int, bool) {
+		n, err := strconv.Atoi(s)
+		return n, err == nil && n >= 100 && n <= 599
+	}
+
+	if class, ok := strings.CutSuffix(strings.ToLower(item), "xx"); ok {
+		if len(class) == 1 && class[0] >= '1' && class[0] <= '5' {
+			base := int(class[0]-'0') * 100
+			return repository.StatusRange{Min: base, Max: base + 99}, nil
+		}
+		return repository.StatusRange{}, errStatusFormat
+	}
+	if from, to, ok := strings.Cut(item, "-"); ok {
+		lo, okLo := status(from)
+		hi, okHi := status(to)
+		if !okLo || !okHi || lo > hi {
+			return repository.StatusRange{}, errStatusFormat
+		}
+		return repository.StatusRange{Min: lo, Max: hi}, nil
+	}
+	n, ok := status(item)
+	if !ok {
+		return repository.StatusRange{}, errStatusFormat
+	}
+	return repository.StatusRange{Min: n, Max: n}, nil
+}
+
+// identifiers reads a list of snake_case identifiers such as problem types
+func (p *queryParser) identifiers(name string, dst *repository.ValueFilter) {
+	include, exclude, ok := p.list(name)
+	if !ok {
+		return
+	}
+	for _, item := range append(slices.Clone(include), exclude...) {
+		if !identifierPattern.MatchString(item) {
+			p.reject(name, "must be a list of snake_case identifiers, got %q", item)
+			return
+		}
+	}
+	*dst = repository.ValueFilter{Include: include, Exclude: exclude}
+}
+
+var identifierPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ruleVersions reads a list of detection rule versions, "none" standing for
+// problems recorded before rules were versioned
+func (p *queryParser) ruleVersions(name string, dst *repository.ValueFilter) {
+	include, exclude, ok := p.list(name)
+	if !ok {
+		return
+	}
+	for _, items := range [][]string{include, exclude} {
+		for i, item := range items {
+			if !ruleVersionPattern.MatchString(item) {
+				p.reject(name, "must be a list of rule versions or none, got %q", item)
+				return
+			}
+			if item == "none" {
+				items[i] = ""
+			}
+		}
+	}
+	*dst = repository.ValueFilter{Include: include, Exclude: exclude}
+}
+
+var ruleVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// regex reads a regular expression, rejecting one that doesn't compile
+func (p *queryParser) regex(name string, dst *string) {
+	value := p.query.Get(name)
+	if value == "" {
+		return
+	}
+	if _, err := regexp.Compile(value); err != nil {
+		p.reject(name, "must be a valid regular expression: %s", err)
+		return
+	}
+	*dst = value
+}
+
+// expression compiles the q= filter expression against fields into dst,
+// reading zone-less and relative times like the time filters
+func (p *queryParser) expression(dst **query.Condition, fields query.Fields, loc *time.Location) {
+	value := p.query.Get("q")
+	if value == "" {
+		return
+	}
+	cond, err := query.ParseAndCompile(value, fields, query.Options{
+		ParseTime: func(s string) (time.Time, error) { return timeparse.Parse(s, p.now, loc) },
+	})
+	if err != nil {
+		p.reject("q", "%s", err)
+		return
+	}
+	*dst = cond
+}
+
+// sort validates a sort spec with validate into dst
+func (p *queryParser) sort(dst *string, validate func(string) error) {
+	value := p.query.Get("sort")
+	if err := validate(value); err != nil {
+		p.reject("sort", "%s", err)
+		return
+	}
+	*dst = value
+}
+
+// ensure rejects name with reason unless ok
+func (p *queryParser) ensure(ok bool, name, reason string) {
+	if !ok {
+		p.reject(name, "%s", reason)
+	}
+}
+
+// listQuery holds the query parameters shared by every list endpoint
+type listQuery struct {
+	Methods       repository.ValueFilter
+	Statuses      repository.StatusFilter
+	PathRegex     string
+	PathGlob      string
+	MinTime       int64
+	MaxTime       int64
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Search        string
+	Expression    *query.Condition
+	SortBy        string
+	Limit         int
+	Offset        int
+	Cursor        string
+	WithTotal     bool
+}
+
+// listQuery parses the shared list parameters, validating sort with
+// validateSort and compiling q against fields
+func (p *queryParser) listQuery(validateSort func(string) error, fields query.Fields) listQuery {
+	q := listQuery{
+		Search: p.query.Get("search"),
+		Cursor: p.query.Get("cursor"),
+		Limit:  DefaultLimit,
+	}
+
+	p.methods(&q.Methods)
+	p.statuses("response", &q.Statuses)
+	p.regex("path_regex", &q.PathRegex)
+	q.PathGlob = p.query.Get("path_glob")
+	p.int64("min_time", &q.MinTime, 0, 1<<62)
+	p.int64("max_time", &q.MaxTime, 0, 1<<62)
+	p.ensure(q.MaxTime == 0 || q.MinTime <= q.MaxTime, "max_time", "must not be less than min_time")
+	loc := p.location()
+	p.time("created_after", &q.CreatedAfter, loc)
+	p.time("created_before", &q.CreatedBefore, loc)
+	p.ensure(q.CreatedBefore.IsZero() || q.CreatedAfter.Before(q.CreatedBefore), "created_before", "must be after created_after")
+	p.expression(&q.Expression, fields, loc)
+	p.sort(&q.SortBy, validateSort)
+	if p.export {
+		q.Limit = 0
+		p.int("limit", &q.Limit, 1, 1<<31-1)
+	} else {
+		p.int("limit", &q.Limit, 1, MaxLimit)
+	}
+	p.int("offset", &q.Offset, 0, 1<<31-1)
+	p.bool("total", &q.WithTotal)
+
+	return q
+}
+
+// parseRequestFilters reads the filters of the request endpoints, including
+// those of an applied view, returning a *ParamError listing every invalid parameter
+func parseRequestFilters(c *gin.Context) (repository.RequestFilters, error) {
+	return parseRequestQuery(listParams(c))
+}
+
+// parseRequestTable reads the filters and the format of the request table and CSV views
+func parseRequestTable(c *gin.Context) (repository.RequestFilters, tableFormat[models.APIRequest], error) {
+	p := newQueryParser(listParams(c))
+	filters := p.requestFilters()
+	format := tableFormatOf(p, requestColumns)
+	return filters, format, p.err()
+}
+
+// parseRequestExport reads the filters, the columns and the file format of a
+// request export, which returns every matching request unless limit is given. The
+// format parameter picks the file format unless file names one.
+func parseRequestExport(c *gin.Context, file string) (repository.RequestFilters, tableFormat[models.APIRequest], exportFormat, error) {
+	p := newQueryParser(listParams(c))
+	p.export = true
+	filters := p.requestFilters()
+	format := tableFormatOf(p, requestColumns)
+	if file == "" {
+		file = exportFormatOf(p)
+	}
+	return filters, format, exportFormats[file], p.err()
+}
+
+// parseRequestHAR reads the filters of a HAR export, which lists every
+// matching request oldest first unless limit or sort is given
+func parseRequestHAR(c *gin.Context) (repository.RequestFilters, error) {
+	p := newQueryParser(listParams(c))
+	p.export = true
+	filters := p.requestFilters()
+	if p.query.Get("sort") == "" {
+		filters.SortBy = "created_at"
+	}
+	return filters, p.err()
+}
+
+// parseRequestQuery reads request filters from query parameters
+func parseRequestQuery(query url.Values) (repository.RequestFilters, error) {
+	p := newQueryParser(query)
+	filters := p.requestFilters()
+	return filters, p.err()
+}
+
+// requestFilters reads the filters of the request endpoints
+func (p *queryParser) requestFilters() repository.RequestFilters {
+	q := p.listQuery(repository.ValidateRequestSort, repository.RequestQueryFields)
+
+	filters := repository.RequestFilters{
+		Methods:        q.Methods,
+		Statuses:       q.Statuses,
+		PathRegex:      q.PathRegex,
+		PathGlob:       q.PathGlob,
+		MinTime:        q.MinTime,
+		MaxTime:        q.MaxTime,
+		CreatedAfter:   q.CreatedAfter,
+		CreatedBefore:  q.CreatedBefore,
+		ContentType:    p.query.Get("content_type"),
+		UpstreamCallID: p.query.Get("upstream_call_id"),
+		Search:         q.Search,
+		Expression:     q.Expression,
+		SortBy:         q.SortBy,
+		Limit:          q.Limit,
+		Offset:         q.Offset,
+		Cursor:         q.Cursor,
+		WithTotal:      q.WithTotal,
+	}
+
+	p.int64("min_bytes", &filters.MinBytes, 0, 1<<62)
+	p.int64("max_bytes", &filters.MaxBytes, 0, 1<<62)
+	p.ensure(filters.MaxBytes == 0 || filters.MinBytes <= filters.MaxBytes, "max_bytes", "must not be less than min_bytes")
+	p.oneOf("throttle", &filters.Throttle, "none", models.ThrottleQueued, models.ThrottleRejected)
+	p.oneOf("outcome", &filters.Outcome, "none", models.OutcomeCircuitOpen, models.OutcomeStreaming,
+		models.OutcomeClientCancelled, models.OutcomeDeadlineExceeded)
+	p.oneOf("cache_status", &filters.CacheStatus, "none", models.CacheHit, models.CacheMiss, models.CacheStale, models.CacheRevalidated, models.CacheRefresh)
+	p.optionalBool("coalesced", &filters.Coalesced)
+	p.int("parent_id", &filters.ParentID, 1, 1<<31-1)
+	p.bool("exclude_attempts", &filters.ExcludeAttempts)
+
+	for _, header := range p.query["header"] {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			p.reject("header", "must be formatted as Name:value, got %q", header)
+			continue
+		}
+		if filters.Headers == nil {
+			filters.Headers = map[string]string{}
+		}
+		filters.Headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+
+	return filters
+}
+
+// parseProblemFilters reads the filters of the problem endpoints, including
+// those of an applied view, returning a *ParamError listing every invalid parameter
+func parseProblemFilters(c *gin.Context) (repository.ProblemFilters, error) {
+	return parseProblemQuery(listParams(c))
+}
+
+// parseProblemTable reads the filters and the format of the problem table
+// and CSV views, whose rows are of set
+func parseProblemTable[T any](c *gin.Context, set columnSet[T]) (repository.ProblemFilters, tableFormat[T], error) {
+	p := newQueryParser(listParams(c))
+	filters := p.problemFilters()
+	format := tableFormatOf(p, set)
+	return filters, format, p.err()
+}
+
+// parseProblemExport reads the filters, the columns and the file format of a
+// problem export, which returns every matching problem unless limit is given. The
+// format parameter picks the file format unless file names one.
+func parseProblemExport[T any](c *gin.Context, set columnSet[T], file string) (repository.ProblemFilters, tableFormat[T], exportFormat, error) {
+	p := newQueryParser(listParams(c))
+	p.export = true
+	filters := p.problemFilters()
+	format := tableFormatOf(p, set)
+	if file == "" {
+		file = exportFormatOf(p)
+	}
+	return filters, format, exportFormats[file], p.err()
+}
+
+// groupedByRequest reports whether the problem view of c groups problems by request
+func groupedByRequest(c *gin.Context) bool {
+	return listParams(c).Get("group_by") == repository.GroupByRequest
+}
+
+// parseProblemQuery reads problem filters from query parameters
+func parseProblemQuery(query url.Values) (repository.ProblemFilters, error) {
+	p := newQueryParser(query)
+	filters := p.problemFilters()
+	return filters, p.err()
+}
+
+// problemFilters reads the filters of the problem endpoints, sorted by the
+// fields of requests when grouped by them
+func (p *queryParser) problemFilters() repository.ProblemFilters {
+	var groupBy string
+	p.oneOf("group_by", &groupBy, repository.GroupByRequest)
+	validateSort := repository.ValidateProblemSort
+	if groupBy == repository.GroupByRequest {
+		validateSort = repository.ValidateProblemGroupSort
+	}
+	q := p.listQuery(validateSort, repository.ProblemQueryFields)
+
+	filters := repository.ProblemFilters{
+		Methods:       q.Methods,
+		Statuses:      q.Statuses,
+		PathRegex:     q.PathRegex,
+		PathGlob:      q.PathGlob,
+		MinTime:       q.MinTime,
+		MaxTime:       q.MaxTime,
+		CreatedAfter:  q.CreatedAfter,
+		CreatedBefore: q.CreatedBefore,
+		Search:        q.Search,
+		Expression:    q.Expression,
+		SortBy:        q.SortBy,
+		Limit:         q.Limit,
+		Offset:        q.Offset,
+		Cursor:        q.Cursor,
+		WithTotal:     q.WithTotal,
+		GroupBy:       groupBy,
+	}
+
+	p.identifiers("problem_type", &filters.ProblemTypes)
+	p.ruleVersions("rule_version", &filters.RuleVersions)
+
+	return filters
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"treblle_project/internal/repository"
+	"treblle_project/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Test 1: Valid parameters are parsed into request filters
+func TestParseRequestFilters_Valid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/requests?method=get&response=404&min_time=10&max_time=20"+
+		"&created_after=2025-01-01&created_before=2025-01-31&coalesced=false&throttle=queued&cache_status=HIT"+
+		"&header=Cache-Control:%20no-cache&sort=-path&limit=1000&offset=5&total=true", nil)
+
+	filters, err := parseRequestFilters(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !slices.Equal(filters.Methods.Include, []string{"GET"}) || filters.MinTime != 10 || filters.MaxTime != 20 {
+		t.Errorf("Unexpected filters %+v", filters)
+	}
+	if len(filters.Statuses.Include) != 1 || filters.Statuses.Include[0] != (repository.StatusRange{Min: 404, Max: 404}) {
+		t.Errorf("Unexpected statuses %+v", filters.Statuses)
+	}
+	if filters.CreatedAfter.Day() != 1 || filters.CreatedBefore.Day() != 31 {
+		t.Errorf("Unexpected dates %v %v", filters.CreatedAfter, filters.CreatedBefore)
+	}
+	if filters.Coalesced == nil || *filters.Coalesced || filters.Throttle != "queued" || filters.CacheStatus != "HIT" {
+		t.Errorf("Unexpected filters %+v", filters)
+	}
+	if filters.Headers["Cache-Control"] != "no-cache" || filters.SortBy != "-path" {
+		t.Errorf("Unexpected filters %+v", filters)
+	}
+	if filters.Limit != MaxLimit || filters.Offset != 5 || !filters.WithTotal {
+		t.Errorf("Unexpected paging %+v", filters)
+	}
+}
+
+// Test 2: Every invalid parameter is reported
+func TestParseRequestFilters_Invalid(t *testing.T) {
+	tests := []struct {
+		query   string
+		invalid []string
+	}{
+		{"response=abc", []string{"response"}},
+		{"response=42", []string{"response"}},
+		{"created_after=yesterday&created_before=2025-13-01", []string{"created_after", "created_before"}},
+		{"created_after=2025-02-01&created_before=2025-01-01", []string{"created_before"}},
+		{"min_time=500&max_time=100", []string{"max_time"}},
+		{"limit=0&offset=-1", []string{"limit", "offset"}},
+		{"limit=1001", []string{"limit"}},
+		{"method=FETCH&throttle=maybe&outcome=ok&cache_status=hit", []string{"method", "throttle", "outcome", "cache_status"}},
+		{"coalesced=perhaps&exclude_attempts=2&total=yes", []string{"total", "coalesced", "exclude_attempts"}},
+		{"header=Cache-Control&parent_id=0", []string{"parent_id", "header"}},
+		{"min_bytes=x&max_bytes=-1", []string{"min_bytes", "max_bytes"}},
+		{"sort=latency", []string{"sort"}},
+		{"response=6xx", []string{"response"}},
+		{"response=500,,502", []string{"response"}},
+		{"response=504-500", []string{"response"}},
+		{"method=GET,!TRACE", []string{"method"}},
+		{"path_regex=(unclosed", []string{"path_regex"}},
+		{"q=status%20%3E", []string{"q"}},
+		{"q=latency%20%3E%20800&sort=latency", []string{"q", "sort"}},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/api/requests?"+tt.query, nil)
+
+			_, err := parseRequestFilters(c)
+			paramErr, ok := err.(*ParamError)
+			if !ok {
+				t.Fatalf("Expected *ParamError, got %v", err)
+			}
+
+			var names []string
+			for _, p := range paramErr.Params {
+				names = append(names, p.Name)
+			}
+			if len(names) != len(tt.invalid) {
+				t.Fatalf("Expected %v to be invalid, got %v", tt.invalid, paramErr.Params)
+			}
+			for i := range names {
+				if names[i] != tt.invalid[i] {
+					t.Fatalf("Expected %v to be invalid, got %v", tt.invalid, paramErr.Params)
+				}
+			}
+		})
+	}
+}
+
+// Test 3: Lists, classes, ranges and negation
+func TestParseProblemFilters_MultiValue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/problems?response=5xx,404,!503,429-431&method=get,!post"+
+		"&problem_type=slow_response,!not_found&path_regex=%5E/anime/%5Cd%2B$&path_glob=/top/*", nil)
+
+	filters, err := parseProblemFilters(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := repository.StatusFilter{
+		Include: []repository.StatusRange{{Min: 500, Max: 599}, {Min: 404, Max: 404}, {Min: 429, Max: 431}},
+		Exclude: []repository.StatusRange{{Min: 503, Max: 503}},
+	}
+	if !slices.Equal(filters.Statuses.Include, expected.Include) || !slices.Equal(filters.Statuses.Exclude, expected.Exclude) {
+		t.Errorf("Expected statuses %+v, got %+v", expected, filters.Statuses)
+	}
+	if !slices.Equal(filters.Methods.Include, []string{"GET"}) || !slices.Equal(filters.Methods.Exclude, []string{"POST"}) {
+		t.Errorf("Unexpected methods %+v", filters.Methods)
+	}
+	if !slices.Equal(filters.ProblemTypes.Include, []string{"slow_response"}) || !slices.Equal(filters.ProblemTypes.Exclude, []string{"not_found"}) {
+		t.Errorf("Unexpected problem types %+v", filters.ProblemTypes)
+	}
+	if filters.PathRegex != `^/anime/\d+$` || filters.PathGlob != "/top/*" {
+		t.Errorf("Unexpected path patterns %q %q", filters.PathRegex, filters.PathGlob)
+	}
+}
+
+// Test 4: Invalid parameters get a problem+json response
+func TestListProblems_InvalidParamsProblemDetails(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	handler := NewProblemHandler(repository.NewProblemRepository(db))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/problems/csv", handler.CSVExport)
+
+	req := httptest.NewRequest("GET", "/api/problems/csv?response=abc&created_after=yesterday", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ProblemJSONContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ProblemJSONContentType, ct)
+	}
+
+	var problem struct {
+		Type          string         `json:"type"`
+		Title         string         `json:"title"`
+		Status        int            `json:"status"`
+		Detail        string         `json:"detail"`
+		Instance      string         `json:"instance"`
+		InvalidParams []InvalidParam `json:"invalid_params"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to decode problem details: %v", err)
+	}
+
+	if problem.Status != 400 || problem.Title != "Bad Request" || problem.Type != "about:blank" {
+		t.Errorf("Unexpected problem details %+v", problem)
+	}
+	if problem.Instance != "/api/problems/csv?response=abc&created_after=yesterday" {
+		t.Errorf("Unexpected instance %q", problem.Instance)
+	}
+	if len(problem.InvalidParams) != 2 || problem.InvalidParams[0].Name != "response" || problem.InvalidParams[1].Name != "created_after" {
+		t.Errorf("Unexpected invalid params %+v", problem.InvalidParams)
+	}
+
+	// Bad cursors are reported the same way
+	router.GET("/api/problems", handler.ListProblems)
+	req = httptest.NewRequest("GET", "/api/problems?cursor=bogus", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != ProblemJSONContentType {
+		t.Errorf("Expected a 400 problem+json for an invalid cursor, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
+	"treblle_project/internal/detector"
 	"treblle_project/internal/jikan"
 	"treblle_project/internal/models"
 	"treblle_project/internal/repository"
@@ -11,12 +17,24 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const SlowResponseThresholdMs = 400
+// MonitorRequestIDHeader carries the id of the logged request on proxied responses
+const MonitorRequestIDHeader = "X-Monitor-Request-Id"
+
+// RequestTimeoutHeader lets clients set a deadline for their request, as a
+// duration ("1500ms", "2s") or a number of milliseconds
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// MaxRequestTimeout caps deadlines set with RequestTimeoutHeader
+const MaxRequestTimeout = time.Minute
+
+// StatusClientClosedRequest is logged for requests whose client went away (nginx convention)
+const StatusClientClosedRequest = 499
 
 type JikanHandler struct {
 	jikanClient jikan.JikanClient
 	requestRepo *repository.RequestRepository
 	problemRepo *repository.ProblemRepository
+	detector    detector.Detector
 }
 
 func NewJikanHandler(
@@ -24,22 +42,46 @@ func NewJikanHandler(
 	requestRepo *repository.RequestRepository,
 	problemRepo *repository.ProblemRepository,
 ) *JikanHandler {
-	return &JikanHandler{
+	h := &JikanHandler{
 		jikanClient: jikanClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+	if reporter, ok := jikanClient.(jikan.OrphanedCallReporter); ok {
+		reporter.OnOrphanedCall(h.logDetachedCall)
+	}
+	if reporter, ok := jikanClient.(jikan.BackgroundCallReporter); ok {
+		reporter.OnBackgroundCall(h.logDetachedCall)
+	}
+	return h
+}
+
+// logDetachedCall logs an upstream call no client request is left to log, a
+// coalesced call whose leader gave up waiting or a background cache refresh,
+// so its measurement, attempts and breaker events aren't lost
+func (h *JikanHandler) logDetachedCall(metrics *jikan.RequestMetrics, err error) {
+	if _, dbErr := h.logRequest(metrics, err, 0); dbErr != nil {
+		log.Printf("Failed to log upstream call %s for %s: %v", metrics.UpstreamCallID, metrics.Path, dbErr)
 	}
 }
 
 // ProxyRequest godoc
 // @Summary      Proxy request to Jikan API
-// @Description  Forwards requests to the Jikan API, logs metrics, and detects problems (404, 403, 400, slow responses, etc.). Returns the proxied response with the same status code from Jikan.
+// @Description  Forwards requests to the Jikan API, logs metrics (including response size, content type and selected upstream headers), and detects problems (404, 403, 400, slow or large responses, etc.). Returns the proxied response with the same status code from Jikan and the upstream headers allowed by the header policy (hop-by-hop headers are always stripped).
 // @Tags         jikan, external
 // @Accept       json
 // @Produce      json
 // @Param        path  path  string  true  "Jikan API path (e.g., /anime/1, /manga/2)"
 // @Success      200  {object}  map[string]interface{}  "Successfully proxied response from Jikan API (returns whatever status Jikan returns: 200, 404, etc.)"
+// @Header       200  {string}  X-Cache  "HIT, MISS or STALE when the response cache is enabled"
+// @Header       200  {string}  X-Monitor-Request-Id  "Id of the logged request"
+// @Failure      429  {object}  map[string]interface{}  "Client-side rate limit for Jikan exceeded, see Retry-After (request is logged with throttle_status rejected)"
 // @Failure      500  {object}  map[string]interface{}  "Failed to log request to database (request not recorded)"
+// @Failure      503  {object}  map[string]interface{}  "Circuit breaker for Jikan is open, see Retry-After (request is logged with outcome circuit_open)"
+// @Param        X-Request-Timeout  header  string  false  "Deadline for the request, as a duration (2s, 1500ms) or milliseconds, capped at 1m"
+// @Failure      400  {object}  map[string]interface{}  "Invalid X-Request-Timeout header"
+// @Failure      504  {object}  map[string]interface{}  "The upstream did not respond within the X-Request-Timeout deadline (request is logged with outcome deadline_exceeded)"
 // @Failure      502  {object}  map[string]interface{}  "Failed to fetch from Jikan API due to network error (request is still logged with status 0)"
 // @Router       /jikan/{path} [get]
 func (h *JikanHandler) ProxyRequest(c *gin.Context) {
@@ -48,24 +90,85 @@ func (h *JikanHandler) ProxyRequest(c *gin.Context) {
 		path = "/"
 	}
 
+	// The upstream call is cancelled when the client disconnects or its deadline passes
+	ctx := c.Request.Context()
+	if value := c.GetHeader(RequestTimeoutHeader); value != "" {
+		timeout, err := parseRequestTimeout(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid " + RequestTimeoutHeader + " header",
+				"details": err.Error(),
+			})
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if streamer, ok := h.jikanClient.(jikan.Streamer); ok && streamer.StreamingEnabled() {
+		h.streamRequest(c, ctx, streamer, path)
+		return
+	}
+
 	// Make request to Jikan API and measure
-	metrics, err := h.jikanClient.ProxyRequest(path)
-
-	// Log the request regardless of success/failure
-	apiRequest := &models.APIRequest{
-		Method:         metrics.Method,
-		ResponseStatus: metrics.ResponseStatus,
-		Path:           metrics.Path,
-		ResponseTimeMs: metrics.ResponseTimeMs,
-		CreatedAt:      time.Now(),
+	metrics, err := h.jikanClient.ProxyRequest(ctx, path, c.Request.Header)
+	h.respond(c, metrics, err, 0)
+}
+
+// parseRequestTimeout reads a RequestTimeoutHeader value, capped at MaxRequestTimeout
+func parseRequestTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		ms, msErr := strconv.ParseInt(value, 10, 64)
+		if msErr != nil {
+			return 0, fmt.Errorf("expected a duration or milliseconds, got %q", value)
+		}
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive, got %q", value)
 	}
+	return min(timeout, MaxRequestTimeout), nil
+}
 
-	// If request failed completely (no response), set status to 0
-	if err != nil && metrics.ResponseStatus == 0 {
-		apiRequest.ResponseStatus = 0
+// streamRequest pipes the upstream response to the client and logs it once complete
+func (h *JikanHandler) streamRequest(c *gin.Context, ctx context.Context, streamer jikan.Streamer, path string) {
+	// Headers go out before the body is complete, so the row is created up
+	// front to have an id to send and filled in afterwards
+	placeholderID, err := h.requestRepo.Create(&models.APIRequest{
+		Method:    "GET",
+		Path:      path,
+		Outcome:   models.OutcomeStreaming,
+		Attempt:   1,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to log request",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.Header(MonitorRequestIDHeader, strconv.FormatInt(placeholderID, 10))
+
+	metrics, err := streamer.StreamRequest(ctx, path, c.Request.Header, c.Writer)
+	if !metrics.Streamed {
+		// Nothing was sent yet, answer like a buffered request
+		h.respond(c, metrics, err, int(placeholderID))
+		return
+	}
+
+	// The response is already out, it can only be logged
+	if _, dbErr := h.logRequest(metrics, err, int(placeholderID)); dbErr != nil {
+		log.Printf("Failed to log streamed request %s: %v", path, dbErr)
 	}
+}
 
-	requestID, dbErr := h.requestRepo.Create(apiRequest)
+// respond logs a buffered request and writes the proxied response or the error.
+// A non-zero requestID is the row to fill in instead of creating one.
+func (h *JikanHandler) respond(c *gin.Context, metrics *jikan.RequestMetrics, err error, requestID int) {
+	apiRequest, dbErr := h.logRequest(metrics, err, requestID)
 	if dbErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to log request",
@@ -73,56 +176,53 @@ func (h *JikanHandler) ProxyRequest(c *gin.Context) {
 		})
 		return
 	}
+	requestID = apiRequest.ID
+	c.Header(MonitorRequestIDHeader, strconv.Itoa(requestID))
 
-	// Check if this is a slow response and create a problem
-	var problem *models.Problem
-	switch metrics.ResponseStatus {
-	case 400:
-		problem = &models.Problem{
-			RequestID:   int(requestID),
-			ProblemType: "bad_request",
-			Description: "The server cannot or will not process the request.",
-			ThresholdMs: 0,
-			CreatedAt:   time.Now(),
-		}
-	case 403:
-		problem = &models.Problem{
-			RequestID:   int(requestID),
-			ProblemType: "forbidden",
-			Description: "The request was valid, but the server is refusing action.",
-			ThresholdMs: 0,
-			CreatedAt:   time.Now(),
-		}
-	case 404:
-		problem = &models.Problem{
-			RequestID:   int(requestID),
-			ProblemType: "not_found",
-			Description: "The requested resource could not be found.",
-			ThresholdMs: 0,
-			CreatedAt:   time.Now(),
-		}
-	case 418:
-		problem = &models.Problem{
-			RequestID:   int(requestID),
-			ProblemType: "im_a_teapot",
-			Description: "The server is literally a teapot",
-			ThresholdMs: 0,
-			CreatedAt:   time.Now(),
-		}
-
+	switch apiRequest.Outcome {
+	case models.OutcomeClientCancelled:
+		c.AbortWithStatus(StatusClientClosedRequest) // Nobody is left to read a body
+		return
+	case models.OutcomeDeadlineExceeded:
+		c.JSON(http.StatusGatewayTimeout, gin.H{
+			"error":   "Jikan API did not respond within the request deadline",
+			"details": err.Error(),
+			"metrics": gin.H{
+				"response_time_ms": metrics.ResponseTimeMs,
+				"request_id":       requestID,
+			},
+		})
+		return
 	}
 
-	if problem == nil && metrics.ResponseTimeMs >= SlowResponseThresholdMs {
-		problem = &models.Problem{
-			RequestID:   int(requestID),
-			ProblemType: "slow_response",
-			Description: fmt.Sprintf("Response time (%dms) exceeded threshold (%dms)", metrics.ResponseTimeMs, SlowResponseThresholdMs),
-			ThresholdMs: SlowResponseThresholdMs,
-			CreatedAt:   time.Now(),
-		}
+	var throttleErr *jikan.ThrottleError
+	if errors.As(err, &throttleErr) {
+		retryAfter := int(math.Ceil(throttleErr.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":   "Jikan API rate limit exceeded",
+			"details": err.Error(),
+			"metrics": gin.H{
+				"retry_after_seconds": retryAfter,
+				"request_id":          requestID,
+			},
+		})
+		return
 	}
-	if problem != nil {
-		_, _ = h.problemRepo.Create(problem) // Don't fail the request if problem logging fails
+
+	var circuitErr *jikan.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		retryAfter := int(math.Ceil(circuitErr.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Jikan API is unavailable, circuit breaker is open",
+			"details": err.Error(),
+			"metrics": gin.H{
+				"retry_after_seconds": retryAfter,
+				"request_id":          requestID,
+			},
+		})
+		return
 	}
 
 	// If the Jikan API request failed, return error
@@ -138,6 +238,144 @@ func (h *JikanHandler) ProxyRequest(c *gin.Context) {
 		return
 	}
 
-	// Return the proxied response
-	c.Data(metrics.ResponseStatus, "application/json", metrics.ResponseBody)
+	// Return the proxied response with the upstream headers allowed through
+	for name, values := range metrics.ResponseHeader {
+		c.Writer.Header()[name] = values
+	}
+	if metrics.CacheStatus != models.CacheNone {
+		c.Header("X-Cache", xCacheHeader(metrics.CacheStatus))
+	}
+
+	contentType := metrics.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	c.Data(metrics.ResponseStatus, contentType, metrics.ResponseBody)
+}
+
+// logRequest stores a proxied request, its retry attempts and the problems they show.
+// A non-zero requestID is an existing row to fill in.
+func (h *JikanHandler) logRequest(metrics *jikan.RequestMetrics, err error, requestID int) (*models.APIRequest, error) {
+	apiRequest := newAPIRequest(metrics)
+	apiRequest.Attempt = max(len(metrics.Attempts), 1)
+
+	// If request failed completely (no response), set status to 0
+	if err != nil && metrics.ResponseStatus == 0 {
+		apiRequest.ResponseStatus = 0
+	}
+
+	// Rejected by the client-side rate limiter: the upstream was never called
+	var throttleErr *jikan.ThrottleError
+	if errors.As(err, &throttleErr) {
+		apiRequest.ResponseStatus = http.StatusTooManyRequests
+	}
+
+	// Short-circuited by an open breaker: the upstream was never called
+	var circuitErr *jikan.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		apiRequest.ResponseStatus = http.StatusServiceUnavailable
+		apiRequest.Outcome = models.OutcomeCircuitOpen
+	}
+
+	// Given up by the caller, tell deadlines from disconnects
+	if metrics.Cancelled {
+		if errors.Is(err, context.DeadlineExceeded) {
+			apiRequest.ResponseStatus = http.StatusGatewayTimeout
+			apiRequest.Outcome = models.OutcomeDeadlineExceeded
+		} else {
+			apiRequest.ResponseStatus = StatusClientClosedRequest
+			apiRequest.Outcome = models.OutcomeClientCancelled
+		}
+	}
+
+	if requestID > 0 {
+		apiRequest.ID = requestID
+		if err := h.requestRepo.Update(apiRequest); err != nil {
+			return nil, err
+		}
+	} else {
+		id, err := h.requestRepo.Create(apiRequest)
+		if err != nil {
+			return nil, err
+		}
+		apiRequest.ID = int(id)
+	}
+	h.recordProblems(apiRequest)
+	h.recordBreakerEvents(apiRequest, metrics.BreakerEvents)
+
+	// Log every retry attempt as a child of the request, so transient
+	// failures show up next to the eventual outcome
+	for _, attemptMetrics := range metrics.Attempts {
+		attempt := newAPIRequest(attemptMetrics)
+		attempt.ParentID = &apiRequest.ID
+		attempt.Attempt = attemptMetrics.Attempt
+		attempt.UpstreamCallID = apiRequest.UpstreamCallID
+
+		attemptID, err := h.requestRepo.Create(attempt)
+		if err != nil {
+			continue // The parent row is already logged, don't fail the request
+		}
+		attempt.ID = int(attemptID)
+		h.recordProblems(attempt)
+	}
+
+	return apiRequest, nil
+}
+
+// newAPIRequest converts client metrics into a request log row
+func newAPIRequest(metrics *jikan.RequestMetrics) *models.APIRequest {
+	return &models.APIRequest{
+		Method:          metrics.Method,
+		ResponseStatus:  metrics.ResponseStatus,
+		Path:            metrics.Path,
+		ResponseTimeMs:  metrics.ResponseTimeMs,
+		ResponseBytes:   metrics.ResponseBytes,
+		ContentType:     metrics.ContentType,
+		UpstreamHeaders: metrics.Headers,
+		Timing: models.RequestTiming{
+			DNSMs:      metrics.Timing.DNSMs,
+			ConnectMs:  metrics.Timing.ConnectMs,
+			TLSMs:      metrics.Timing.TLSMs,
+			TTFBMs:     metrics.Timing.TTFBMs,
+			TransferMs: metrics.Timing.TransferMs,
+			ConnReused: metrics.Timing.ConnReused,
+		},
+		ThrottleStatus: metrics.Throttle,
+		QueueWaitMs:    metrics.QueueWaitMs,
+		CacheStatus:    metrics.CacheStatus,
+		Coalesced:      metrics.Coalesced,
+		UpstreamCallID: metrics.UpstreamCallID,
+		Attempt:        1,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// xCacheHeader maps a cache status to the X-Cache response header, where
+// a revalidated entry counts as a hit because the cached body was served
+func xCacheHeader(status string) string {
+	if status == models.CacheRevalidated {
+		return models.CacheHit
+	}
+	return status
+}
+
+// recordProblems stores the problems detected for a logged request, if any
+func (h *JikanHandler) recordProblems(req *models.APIRequest) {
+	for _, problem := range h.detector.Detect(req) {
+		_, _ = h.problemRepo.Create(problem) // Don't fail the request if problem logging fails
+	}
+}
+
+// recordBreakerEvents stores circuit breaker state changes caused by a request as problems
+func (h *JikanHandler) recordBreakerEvents(req *models.APIRequest, events []jikan.BreakerEvent) {
+	for _, event := range events {
+		problem := &models.Problem{
+			RequestID:   req.ID,
+			ProblemType: "circuit_breaker_" + string(event.To),
+			Description: fmt.Sprintf("Circuit breaker for %s changed from %s to %s: %s", event.Key, event.From, event.To, event.Reason),
+			ThresholdMs: 0,
+			CreatedAt:   event.At,
+		}
+		_, _ = h.problemRepo.Create(problem) // Don't fail the request if problem logging fails
+	}
 }
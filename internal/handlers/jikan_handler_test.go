@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
+	"treblle_project/internal/detector"
 	"treblle_project/internal/jikan"
+	"treblle_project/internal/models"
 	"treblle_project/internal/repository"
 	"treblle_project/internal/testutil"
 
@@ -18,7 +25,7 @@ type mockJikanClient struct {
 	err      error
 }
 
-func (m *mockJikanClient) ProxyRequest(path string) (*jikan.RequestMetrics, error) {
+func (m *mockJikanClient) ProxyRequest(ctx context.Context, path string, header http.Header) (*jikan.RequestMetrics, error) {
 	// Update path in response to match request
 	if m.response != nil {
 		m.response.Path = path
@@ -50,6 +57,7 @@ func TestJikanHandler_SuccessfulProxy(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	// Setup Gin
@@ -113,6 +121,7 @@ func TestJikanHandler_NotFoundDetection(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -166,6 +175,7 @@ func TestJikanHandler_ForbiddenDetection(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -213,6 +223,7 @@ func TestJikanHandler_BadRequestDetection(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -260,6 +271,7 @@ func TestJikanHandler_TeapotDetection(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -307,6 +319,7 @@ func TestJikanHandler_SlowResponseDetection(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -331,8 +344,8 @@ func TestJikanHandler_SlowResponseDetection(t *testing.T) {
 		t.Errorf("Expected problem type 'slow_response', got %s", problems[0].ProblemType)
 	}
 
-	if problems[0].ThresholdMs != SlowResponseThresholdMs {
-		t.Errorf("Expected threshold %d, got %d", SlowResponseThresholdMs, problems[0].ThresholdMs)
+	if problems[0].ThresholdMs != detector.SlowResponseThresholdMs {
+		t.Errorf("Expected threshold %d, got %d", detector.SlowResponseThresholdMs, problems[0].ThresholdMs)
 	}
 }
 
@@ -359,6 +372,7 @@ func TestJikanHandler_FastResponseNoProblem(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -402,6 +416,7 @@ func TestJikanHandler_NetworkFailure(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -434,7 +449,7 @@ func TestJikanHandler_NetworkFailure(t *testing.T) {
 	}
 }
 
-// Test 9: 404 AND slow (status code problem takes precedence)
+// Test 9: 404 AND slow (both problems are recorded)
 func TestJikanHandler_SlowAnd404(t *testing.T) {
 	db := testutil.SetupTestDB(t)
 	defer db.Close()
@@ -457,6 +472,7 @@ func TestJikanHandler_SlowAnd404(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -467,14 +483,13 @@ func TestJikanHandler_SlowAnd404(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	// Only one problem should be created (404 takes precedence)
-	problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
-	if len(problems) != 1 {
-		t.Fatalf("Expected 1 problem (404 takes precedence), got %d", len(problems))
+	problems, _ := problemRepo.List(repository.ProblemFilters{SortBy: "problem_type", Limit: 10})
+	if len(problems) != 2 {
+		t.Fatalf("Expected 2 problems (not_found and slow_response), got %d", len(problems))
 	}
 
-	if problems[0].ProblemType != "not_found" {
-		t.Errorf("Expected 'not_found' problem to take precedence, got %s", problems[0].ProblemType)
+	if problems[0].ProblemType != "not_found" || problems[1].ProblemType != "slow_response" {
+		t.Errorf("Expected not_found and slow_response, got %s and %s", problems[0].ProblemType, problems[1].ProblemType)
 	}
 }
 
@@ -501,6 +516,7 @@ func TestJikanHandler_ProblemLoggingFailureDoesNotAffectResponse(t *testing.T) {
 		jikanClient: mockClient,
 		requestRepo: requestRepo,
 		problemRepo: problemRepo,
+		detector:    detector.Default,
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -516,3 +532,671 @@ func TestJikanHandler_ProblemLoggingFailureDoesNotAffectResponse(t *testing.T) {
 		t.Errorf("Expected status 200 even if problem logging fails, got %d", w.Code)
 	}
 }
+
+// Test 11: Large response detection and response metadata logging
+func TestJikanHandler_LargeResponseDetection(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	mockClient := &mockJikanClient{
+		response: &jikan.RequestMetrics{
+			Method:         "GET",
+			Path:           "/top/anime",
+			ResponseStatus: 200,
+			ResponseTimeMs: 100,
+			ResponseBytes:  detector.LargeResponseThresholdBytes + 1,
+			ContentType:    "application/json",
+			Headers:        map[string]string{"X-Request-Fingerprint": "f00d"},
+			ResponseBody:   []byte(`{"data":[]}`),
+		},
+		err: nil,
+	}
+
+	handler := &JikanHandler{
+		jikanClient: mockClient,
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/top/anime", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	requests, _ := requestRepo.List(repository.RequestFilters{Limit: 10})
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 logged request, got %d", len(requests))
+	}
+	if requests[0].ResponseBytes != detector.LargeResponseThresholdBytes+1 {
+		t.Errorf("Expected response size to be logged, got %d", requests[0].ResponseBytes)
+	}
+	if requests[0].UpstreamHeaders["X-Request-Fingerprint"] != "f00d" {
+		t.Errorf("Expected fingerprint header to be logged, got %v", requests[0].UpstreamHeaders)
+	}
+
+	problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
+	if len(problems) != 1 {
+		t.Fatalf("Expected 1 problem for large response, got %d", len(problems))
+	}
+	if problems[0].ProblemType != "large_response" {
+		t.Errorf("Expected problem type 'large_response', got %s", problems[0].ProblemType)
+	}
+}
+
+// Test 12: Client-side rate limiting returns 429 with Retry-After and logs the rejection as throttled
+func TestJikanHandler_RateLimited(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	throttleErr := &jikan.ThrottleError{RetryAfter: 1500 * time.Millisecond}
+	mockClient := &mockJikanClient{
+		response: &jikan.RequestMetrics{
+			Method:   "GET",
+			Path:     "/anime/1",
+			Throttle: models.ThrottleRejected,
+			Error:    throttleErr,
+		},
+		err: throttleErr,
+	}
+
+	handler := &JikanHandler{
+		jikanClient: mockClient,
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/anime/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 429 {
+		t.Errorf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", w.Header().Get("Retry-After"))
+	}
+
+	requests, _ := requestRepo.List(repository.RequestFilters{Throttle: models.ThrottleRejected, Limit: 10})
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 rejected request logged, got %d", len(requests))
+	}
+	if requests[0].ResponseStatus != 429 {
+		t.Errorf("Expected rejected request logged with status 429, got %d", requests[0].ResponseStatus)
+	}
+
+	// Our own throttling is not the upstream rate limiting the request
+	problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
+	if len(problems) != 1 || problems[0].ProblemType != "throttled" {
+		t.Errorf("Expected a single throttled problem, got %+v", problems)
+	}
+}
+
+// Test 13: Retry attempts are logged as child rows with their own problems
+func TestJikanHandler_RetryAttemptsLogged(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	attempts := []*jikan.RequestMetrics{
+		{Method: "GET", Path: "/anime/1", ResponseStatus: 503, ResponseTimeMs: 30, Attempt: 1},
+		{Method: "GET", Path: "/anime/1", ResponseStatus: 200, ResponseTimeMs: 40, Attempt: 2, ResponseBody: []byte(`{"data":{}}`)},
+	}
+	mockClient := &mockJikanClient{
+		response: &jikan.RequestMetrics{
+			Method:         "GET",
+			Path:           "/anime/1",
+			ResponseStatus: 200,
+			ResponseTimeMs: 150,
+			Attempt:        2,
+			Attempts:       attempts,
+			ResponseBody:   []byte(`{"data":{}}`),
+		},
+		err: nil,
+	}
+
+	handler := &JikanHandler{
+		jikanClient: mockClient,
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/anime/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	parents, _ := requestRepo.List(repository.RequestFilters{ExcludeAttempts: true, Limit: 10})
+	if len(parents) != 1 {
+		t.Fatalf("Expected 1 parent request, got %d", len(parents))
+	}
+	if parents[0].Attempt != 2 {
+		t.Errorf("Expected parent to record 2 attempts, got %d", parents[0].Attempt)
+	}
+
+	children, _ := requestRepo.List(repository.RequestFilters{ParentID: parents[0].ID, Limit: 10})
+	if len(children) != 2 {
+		t.Fatalf("Expected 2 attempt rows, got %d", len(children))
+	}
+
+	problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
+	if len(problems) != 1 {
+		t.Fatalf("Expected 1 problem for the failed attempt, got %d", len(problems))
+	}
+	if problems[0].ProblemType != "server_error" || problems[0].ResponseStatus != 503 {
+		t.Errorf("Expected server_error on the 503 attempt, got %s (%d)", problems[0].ProblemType, problems[0].ResponseStatus)
+	}
+}
+
+// Test 14: Short-circuited requests return 503 and breaker state changes are logged as problems
+func TestJikanHandler_CircuitOpen(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	circuitErr := &jikan.CircuitOpenError{Key: "api.jikan.moe", RetryAfter: 20 * time.Second}
+	mockClient := &mockJikanClient{
+		response: &jikan.RequestMetrics{
+			Method:      "GET",
+			Path:        "/anime/1",
+			CircuitOpen: true,
+			Error:       circuitErr,
+		},
+		err: circuitErr,
+	}
+
+	handler := &JikanHandler{
+		jikanClient: mockClient,
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/anime/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 503 {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "20" {
+		t.Errorf("Expected Retry-After 20, got %q", w.Header().Get("Retry-After"))
+	}
+
+	requests, _ := requestRepo.List(repository.RequestFilters{Outcome: "circuit_open", Limit: 10})
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 short-circuited request logged, got %d", len(requests))
+	}
+
+	problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
+	if len(problems) != 1 || problems[0].ProblemType != "circuit_open" {
+		t.Errorf("Expected a circuit_open problem, got %v", problems)
+	}
+}
+
+// Test 15: Breaker transitions caused by a request are recorded as problems
+func TestJikanHandler_BreakerEventsRecorded(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	mockClient := &mockJikanClient{
+		response: &jikan.RequestMetrics{
+			Method:         "GET",
+			Path:           "/anime/1",
+			ResponseStatus: 200,
+			ResponseTimeMs: 100,
+			ResponseBody:   []byte(`{"data":{}}`),
+			BreakerEvents: []jikan.BreakerEvent{
+				{Key: "api.jikan.moe", From: jikan.BreakerHalfOpen, To: jikan.BreakerClosed, Reason: "probe call succeeded", At: time.Now()},
+			},
+		},
+	}
+
+	handler := &JikanHandler{
+		jikanClient: mockClient,
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/anime/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
+	if len(problems) != 1 {
+		t.Fatalf("Expected 1 breaker problem, got %d", len(problems))
+	}
+	if problems[0].ProblemType != "circuit_breaker_closed" {
+		t.Errorf("Expected problem type 'circuit_breaker_closed', got %s", problems[0].ProblemType)
+	}
+}
+
+// Test 16: Cache hits set X-Cache and are not flagged as slow
+func TestJikanHandler_CacheHit(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	mockClient := &mockJikanClient{
+		response: &jikan.RequestMetrics{
+			Method:         "GET",
+			Path:           "/top/anime",
+			ResponseStatus: 200,
+			ResponseTimeMs: 500, // e.g. a slow second tier lookup
+			ResponseBody:   []byte(`{"data":[]}`),
+			CacheStatus:    models.CacheHit,
+		},
+	}
+
+	handler := &JikanHandler{
+		jikanClient: mockClient,
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/top/anime", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected X-Cache HIT, got %q", w.Header().Get("X-Cache"))
+	}
+
+	requests, _ := requestRepo.List(repository.RequestFilters{CacheStatus: "HIT", Limit: 10})
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 cache hit logged, got %d", len(requests))
+	}
+
+	problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems for a cache hit, got %v", problems)
+	}
+}
+
+// Test 17: Coalesced requests are logged, linked to the upstream call and not flagged as slow
+func TestJikanHandler_CoalescedRequest(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	mockClient := &mockJikanClient{
+		response: &jikan.RequestMetrics{
+			Method:         "GET",
+			Path:           "/top/anime",
+			ResponseStatus: 200,
+			ResponseTimeMs: 600, // Waited on a slow leader
+			ResponseBody:   []byte(`{"data":[]}`),
+			UpstreamCallID: "9f86d081884c7d65",
+			Coalesced:      true,
+		},
+	}
+
+	handler := &JikanHandler{
+		jikanClient: mockClient,
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/top/anime", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	coalesced := true
+	requests, _ := requestRepo.List(repository.RequestFilters{Coalesced: &coalesced, UpstreamCallID: "9f86d081884c7d65", Limit: 10})
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 coalesced request logged, got %d", len(requests))
+	}
+
+	problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
+	if len(problems) != 0 {
+		t.Errorf("Expected no slow_response problem for a coalesced request, got %v", problems)
+	}
+}
+
+// mockStreamingClient streams a fixed body to the response writer
+type mockStreamingClient struct {
+	mockJikanClient
+	body []byte
+}
+
+func (m *mockStreamingClient) StreamingEnabled() bool { return true }
+
+func (m *mockStreamingClient) StreamRequest(ctx context.Context, path string, header http.Header, w http.ResponseWriter) (*jikan.RequestMetrics, error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(m.body)
+	return &jikan.RequestMetrics{
+		Method:         "GET",
+		Path:           path,
+		ResponseStatus: http.StatusOK,
+		ResponseTimeMs: 120,
+		ResponseBytes:  int64(len(m.body)),
+		Streamed:       true,
+	}, nil
+}
+
+// Test 18: Streamed responses are forwarded and logged once complete
+func TestJikanHandler_StreamedResponse(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	handler := &JikanHandler{
+		jikanClient: &mockStreamingClient{body: []byte(`{"data":[1,2,3]}`)},
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/anime", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 200 || w.Body.String() != `{"data":[1,2,3]}` {
+		t.Errorf("Expected the streamed body, got %d %q", w.Code, w.Body.String())
+	}
+
+	requests, _ := requestRepo.List(repository.RequestFilters{Limit: 10})
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request logged, got %d", len(requests))
+	}
+	if requests[0].ResponseBytes != 16 || requests[0].Path != "/anime" || requests[0].Outcome != "" {
+		t.Errorf("Unexpected logged request %+v", requests[0])
+	}
+	if w.Header().Get(MonitorRequestIDHeader) != strconv.Itoa(requests[0].ID) {
+		t.Errorf("Expected the placeholder row id in %s, got %q", MonitorRequestIDHeader, w.Header().Get(MonitorRequestIDHeader))
+	}
+}
+
+// Test 19: Upstream headers and status pass through, with the logged request id
+func TestJikanHandler_PassesThroughHeaders(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	mockClient := &mockJikanClient{
+		response: &jikan.RequestMetrics{
+			Method:         "GET",
+			Path:           "/anime/1",
+			ResponseStatus: 429,
+			ResponseTimeMs: 50,
+			ContentType:    "application/problem+json",
+			ResponseHeader: http.Header{
+				"Content-Type": {"application/problem+json"},
+				"Retry-After":  {"3"},
+			},
+			ResponseBody: []byte(`{"status":429}`),
+		},
+	}
+
+	handler := &JikanHandler{
+		jikanClient: mockClient,
+		requestRepo: requestRepo,
+		problemRepo: problemRepo,
+		detector:    detector.Default,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jikan/*path", handler.ProxyRequest)
+
+	req := httptest.NewRequest("GET", "/jikan/anime/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 429 {
+		t.Errorf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "3" || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("Expected upstream headers to pass through, got %v", w.Header())
+	}
+
+	requests, _ := requestRepo.List(repository.RequestFilters{Limit: 10})
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request logged, got %d", len(requests))
+	}
+	if w.Header().Get(MonitorRequestIDHeader) != strconv.Itoa(requests[0].ID) {
+		t.Errorf("Expected %s %d, got %q", MonitorRequestIDHeader, requests[0].ID, w.Header().Get(MonitorRequestIDHeader))
+	}
+}
+
+// Test 20: Cancelled requests are logged with their outcome and deadlines answer 504
+func TestJikanHandler_Cancellation(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		expectedStatus  int
+		expectedOutcome string
+		expectedProblem string
+	}{
+		{"client disconnected", fmt.Errorf("request cancelled: %w", context.Canceled), 499, "client_cancelled", ""},
+		{"deadline passed", fmt.Errorf("request cancelled: %w", context.DeadlineExceeded), 504, "deadline_exceeded", "deadline_exceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.SetupTestDB(t)
+			defer db.Close()
+
+			requestRepo := repository.NewRequestRepository(db)
+			problemRepo := repository.NewProblemRepository(db)
+
+			handler := &JikanHandler{
+				jikanClient: &mockJikanClient{
+					response: &jikan.RequestMetrics{
+						Method:         "GET",
+						Path:           "/anime/1",
+						ResponseTimeMs: 2000,
+						Cancelled:      true,
+						Error:          tt.err,
+					},
+					err: tt.err,
+				},
+				requestRepo: requestRepo,
+				problemRepo: problemRepo,
+				detector:    detector.Default,
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/jikan/*path", handler.ProxyRequest)
+
+			req := httptest.NewRequest("GET", "/jikan/anime/1", nil)
+			req.Header.Set(RequestTimeoutHeader, "2s")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			requests, _ := requestRepo.List(repository.RequestFilters{Outcome: tt.expectedOutcome, Limit: 10})
+			if len(requests) != 1 || requests[0].ResponseStatus != tt.expectedStatus {
+				t.Fatalf("Expected 1 %s request logged with status %d, got %v", tt.expectedOutcome, tt.expectedStatus, requests)
+			}
+
+			problems, _ := problemRepo.List(repository.ProblemFilters{Limit: 10})
+			if tt.expectedProblem == "" && len(problems) != 0 {
+				t.Errorf("Expected no problems, got %v", problems)
+			}
+			if tt.expectedProblem != "" && (len(problems) != 1 || problems[0].ProblemType != tt.expectedProblem) {
+				t.Errorf("Expected a %s problem, got %v", tt.expectedProblem, problems)
+			}
+		})
+	}
+}
+
+// Test 21: X-Request-Timeout accepts durations and milliseconds
+func TestParseRequestTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"2s", 2 * time.Second, true},
+		{"1500", 1500 * time.Millisecond, true},
+		{"10m", MaxRequestTimeout, true},
+		{"0", 0, false},
+		{"-1s", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRequestTimeout(tt.value)
+		if (err == nil) != tt.valid || got != tt.expected {
+			t.Errorf("parseRequestTimeout(%q) = %v, %v; expected %v (valid=%v)", tt.value, got, err, tt.expected, tt.valid)
+		}
+	}
+}
+
+// orphanReportingClient captures the function orphaned coalesced calls are reported to
+type orphanReportingClient struct {
+	mockJikanClient
+	report func(metrics *jikan.RequestMetrics, err error)
+}
+
+func (m *orphanReportingClient) OnOrphanedCall(fn func(metrics *jikan.RequestMetrics, err error)) {
+	m.report = fn
+}
+
+// Test 22: Coalesced upstream calls whose leader gave up are logged once they complete
+func TestJikanHandler_LogsOrphanedCall(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	client := &orphanReportingClient{}
+	NewJikanHandler(client, requestRepo, problemRepo)
+	if client.report == nil {
+		t.Fatal("Expected the handler to register for orphaned calls")
+	}
+
+	client.report(&jikan.RequestMetrics{
+		Method:         "GET",
+		Path:           "/top/anime",
+		ResponseStatus: 404,
+		ResponseTimeMs: 850,
+		UpstreamCallID: "5d41402abc4b2a76",
+	}, nil)
+
+	requests, err := requestRepo.List(repository.RequestFilters{UpstreamCallID: "5d41402abc4b2a76", Limit: 10})
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("Expected the upstream call to be logged once, got %d (%v)", len(requests), err)
+	}
+	if requests[0].Coalesced || requests[0].ResponseTimeMs != 850 || requests[0].Outcome != "" {
+		t.Errorf("Expected the upstream measurement, got %+v", requests[0])
+	}
+	problems, _ := problemRepo.List(repository.ProblemFilters{RequestIDs: []int{requests[0].ID}})
+	if len(problems) == 0 {
+		t.Error("Expected the upstream call to be checked for problems")
+	}
+}
+
+// refreshReportingClient captures the function background cache refreshes are reported to
+type refreshReportingClient struct {
+	mockJikanClient
+	report func(metrics *jikan.RequestMetrics, err error)
+}
+
+func (m *refreshReportingClient) OnBackgroundCall(fn func(metrics *jikan.RequestMetrics, err error)) {
+	m.report = fn
+}
+
+// Test 23: Background cache refreshes are logged with their own cache status
+func TestJikanHandler_LogsBackgroundCall(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+
+	client := &refreshReportingClient{}
+	NewJikanHandler(client, requestRepo, problemRepo)
+	if client.report == nil {
+		t.Fatal("Expected the handler to register for background calls")
+	}
+
+	client.report(&jikan.RequestMetrics{
+		Method:         "GET",
+		Path:           "/top/anime",
+		ResponseStatus: 200,
+		ResponseTimeMs: 950,
+		CacheStatus:    models.CacheRefresh,
+	}, nil)
+
+	requests, err := requestRepo.List(repository.RequestFilters{CacheStatus: models.CacheRefresh, Limit: 10})
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("Expected the refresh to be logged once, got %d (%v)", len(requests), err)
+	}
+	problems, _ := problemRepo.List(repository.ProblemFilters{RequestIDs: []int{requests[0].ID}})
+	if len(problems) != 1 || problems[0].ProblemType != "slow_response" {
+		t.Errorf("Expected the refresh to be checked for problems, got %+v", problems)
+	}
+}
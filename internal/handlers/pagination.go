@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"treblle_project/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TotalCountHeader carries the total row count on CSV exports, which have no meta block
+const TotalCountHeader = "X-Total-Count"
+
+// listError writes the response for a failed list query: problem details
+// for invalid parameters, cursors and sorts, and a 500 otherwise
+func listError(c *gin.Context, err error) {
+	var paramErr *ParamError
+	switch {
+	case errors.As(err, &paramErr):
+		writeParamError(c, paramErr)
+	case errors.Is(err, repository.ErrInvalidCursor):
+		writeParamError(c, &ParamError{Params: []InvalidParam{{Name: "cursor", Reason: err.Error()}}})
+	case errors.Is(err, repository.ErrInvalidSort):
+		writeParamError(c, &ParamError{Params: []InvalidParam{{Name: "sort", Reason: err.Error()}}})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// pageURL is the current request URL pointing at another cursor
+func pageURL(c *gin.Context, cursor string) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set("cursor", cursor)
+	query.Del("offset") // The cursor replaces the offset
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+// setPageHeaders sets the Link header pointing at the neighbouring pages,
+// and the total count header when asked to
+func setPageHeaders[T any](c *gin.Context, page *repository.Page[T], withTotalHeader bool) {
+	var links []string
+	if page.NextCursor != "" {
+		links = append(links, `<`+pageURL(c, page.NextCursor)+`>; rel="next"`)
+	}
+	if page.PrevCursor != "" {
+		links = append(links, `<`+pageURL(c, page.PrevCursor)+`>; rel="prev"`)
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+
+	if withTotalHeader && page.Total != nil {
+		c.Header(TotalCountHeader, strconv.Itoa(*page.Total))
+	}
+}
+
+// pageMeta builds the meta block of a list response and sets the page headers
+func pageMeta[T any](c *gin.Context, page *repository.Page[T], limit, offset int) gin.H {
+	setPageHeaders(c, page, false)
+
+	meta := gin.H{
+		"count":       len(page.Items),
+		"limit":       limit,
+		"offset":      offset,
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
+	}
+	if page.Total != nil {
+		meta["total"] = *page.Total
+	}
+	return meta
+}
@@ -1,16 +1,48 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/csv"
 	"net/http"
-	"strconv"
-	"time"
+	"strings"
+	"treblle_project/internal/models"
 	"treblle_project/internal/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
+// problemColumns are the columns of the problem table and CSV views
+var problemColumns = columnSet[models.Problem]{
+	columns: []column[models.Problem]{
+		{"id", func(p *models.Problem) any { return p.ID }},
+		{"request_id", func(p *models.Problem) any { return p.RequestID }},
+		{"problem_type", func(p *models.Problem) any { return p.ProblemType }},
+		{"description", func(p *models.Problem) any { return p.Description }},
+		{"method", func(p *models.Problem) any { return p.Method }},
+		{"response", func(p *models.Problem) any { return p.ResponseStatus }},
+		{"path", func(p *models.Problem) any { return p.Path }},
+		{"response_time", func(p *models.Problem) any { return p.ResponseTimeMs }},
+		{"threshold_ms", func(p *models.Problem) any { return p.ThresholdMs }},
+		{"created_at", func(p *models.Problem) any { return p.CreatedAt }},
+		{"rule_version", func(p *models.Problem) any { return p.RuleVersion }},
+	},
+	defaults: []string{"problem_type", "description", "method", "response", "path", "response_time", "threshold_ms", "created_at"},
+}
+
+// problemGroupColumns are the columns of the problem table and CSV views
+// grouped by request
+var problemGroupColumns = columnSet[models.RequestProblems]{
+	columns: []column[models.RequestProblems]{
+		{"request_id", func(g *models.RequestProblems) any { return g.RequestID }},
+		{"method", func(g *models.RequestProblems) any { return g.Method }},
+		{"response", func(g *models.RequestProblems) any { return g.ResponseStatus }},
+		{"path", func(g *models.RequestProblems) any { return g.Path }},
+		{"response_time", func(g *models.RequestProblems) any { return g.ResponseTimeMs }},
+		{"problem_count", func(g *models.RequestProblems) any { return g.ProblemCount }},
+		{"problem_types", func(g *models.RequestProblems) any { return strings.Join(g.ProblemTypes, ",") }},
+		{"created_at", func(g *models.RequestProblems) any { return g.CreatedAt }},
+	},
+	defaults: []string{"request_id", "method", "response", "path", "response_time", "problem_types", "created_at"},
+}
+
 type ProblemHandler struct {
 	repo *repository.ProblemRepository
 }
@@ -25,34 +57,59 @@ func NewProblemHandler(repo *repository.ProblemRepository) *ProblemHandler {
 // @Tags         problems, list, order, search, filter
 // @Accept       json
 // @Produce      json
-// @Param        method         query    string  false  "HTTP method filter (GET, POST, etc.)"
-// @Param        response       query    int     false  "Response status code filter"
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
 // @Param        min_time       query    int     false  "Minimum response time in milliseconds"
 // @Param        max_time       query    int     false  "Maximum response time in milliseconds"
-// @Param        created_after  query    string  false  "Filter problems created after date (format: 2006-01-02)"
-// @Param        created_before query    string  false  "Filter problems created before date (format: 2006-01-02)"
+// @Param        created_after  query    string  false  "Only problems created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only problems created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC)"
 // @Param        search         query    string  false  "Search in request path"
-// @Param        sort           query    string  false  "Sort by field (response_time, created_at)"
-// @Param        limit          query    int     false  "Maximum number of results (default: 100)"
-// @Param        offset         query    int     false  "Number of results to skip (default: 0)"
+// @Param        problem_type   query    string  false  "Problem types, comma separated, ! to exclude (e.g. not_found,server_error)"
+// @Param        rule_version   query    string  false  "Versions of the detection rules that recorded the problems, comma separated, none for unversioned, ! to exclude (e.g. !1)"
+// @Param        group_by       query    string  false  "request to list each request with its matching problems once, sorted by created_at, response_time, method, path, response or problem_count"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)"
+// @Param        limit          query    int     false  "Maximum number of results (default: 100, max: 1000)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor or prev_cursor of a previous page"
+// @Param        total          query    bool    false  "Also count every matching row (meta.total, X-Total-Count on CSV)"
 // @Success      200  {object}  map[string]interface{}  "List of problems with metadata"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
 // @Failure      500  {object}  map[string]string       "Internal server error"
 // @Router       /problems [get]
 func (h *ProblemHandler) ListProblems(c *gin.Context) {
-	filters := parseProblemFilters(c)
-	problems, err := h.repo.List(filters)
+	filters, err := parseProblemFilters(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		listError(c, err)
+		return
+	}
+	if filters.GroupBy == repository.GroupByRequest {
+		page, err := h.repo.ListGroupsPage(filters)
+		if err != nil {
+			listError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data": page.Items,
+			"meta": pageMeta(c, page, filters.Limit, filters.Offset),
+		})
 		return
 	}
 
+	page, err := h.repo.ListPage(filters)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	problems := page.Items
+
 	c.JSON(http.StatusOK, gin.H{
 		"data": problems,
-		"meta": gin.H{
-			"count":  len(problems),
-			"limit":  filters.Limit,
-			"offset": filters.Offset,
-		},
+		"meta": pageMeta(c, page, filters.Limit, filters.Offset),
 	})
 }
 
@@ -62,50 +119,64 @@ func (h *ProblemHandler) ListProblems(c *gin.Context) {
 // @Tags         problems, order, filter, search, table
 // @Accept       json
 // @Produce      json
-// @Param        method         query    string  false  "HTTP method filter (GET, POST, etc.)"
-// @Param        response       query    int     false  "Response status code filter"
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
 // @Param        min_time       query    int     false  "Minimum response time in milliseconds"
 // @Param        max_time       query    int     false  "Maximum response time in milliseconds"
-// @Param        created_after  query    string  false  "Filter problems created after date (format: 2006-01-02)"
-// @Param        created_before query    string  false  "Filter problems created before date (format: 2006-01-02)"
+// @Param        created_after  query    string  false  "Only problems created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only problems created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps"
 // @Param        search         query    string  false  "Search in request path"
-// @Param        sort           query    string  false  "Sort by field (response_time, created_at)"
-// @Param        limit          query    int     false  "Maximum number of results (default: 100)"
-// @Param        offset         query    int     false  "Number of results to skip (default: 0)"
+// @Param        problem_type   query    string  false  "Problem types, comma separated, ! to exclude (e.g. not_found,server_error)"
+// @Param        rule_version   query    string  false  "Versions of the detection rules that recorded the problems, comma separated, none for unversioned, ! to exclude (e.g. !1)"
+// @Param        group_by       query    string  false  "request to list each request with its matching problems once, sorted by created_at, response_time, method, path, response or problem_count"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        fields         query    string  false  "Comma separated columns in order (id, request_id, problem_type, description, method, response, path, response_time, threshold_ms, created_at, rule_version; grouped by request: request_id, method, response, path, response_time, problem_count, problem_types, created_at)"
+// @Param        time_format    query    string  false  "Format of timestamps: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)"
+// @Param        limit          query    int     false  "Maximum number of results (default: 100, max: 1000)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor or prev_cursor of a previous page"
+// @Param        total          query    bool    false  "Also count every matching row (meta.total, X-Total-Count on CSV)"
 // @Success      200  {object}  map[string]interface{}  "Table data with columns and rows"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
 // @Failure      500  {object}  map[string]string       "Internal server error"
 // @Router       /problems/table [get]
 func (h *ProblemHandler) TableView(c *gin.Context) {
-	filters := parseProblemFilters(c)
-	problems, err := h.repo.List(filters)
+	if groupedByRequest(c) {
+		problemTable(c, problemGroupColumns, h.repo.ListGroupsPage)
+		return
+	}
+	problemTable(c, problemColumns, h.repo.ListPage)
+}
+
+// problemTable responds with a page of the rows list returns as a table
+// with the columns of set
+func problemTable[T any](c *gin.Context, set columnSet[T], list func(repository.ProblemFilters) (*repository.Page[T], error)) {
+	filters, format, err := parseProblemTable(c, set)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	page, err := list(filters)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		listError(c, err)
 		return
 	}
 
 	// Format as table structure
-	tableData := make([][]any, 0, len(problems))
-	for _, p := range problems {
-		tableData = append(tableData, []any{
-			p.ProblemType,
-			p.Description,
-			p.Method,
-			p.ResponseStatus,
-			p.Path,
-			p.ResponseTimeMs,
-			p.ThresholdMs,
-			p.CreatedAt.Format(time.RFC3339),
-		})
+	tableData := make([][]any, 0, len(page.Items))
+	for i := range page.Items {
+		tableData = append(tableData, format.row(&page.Items[i]))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"columns": []string{"problem_type", "description", "method", "response", "path", "response_time", "threshold_ms", "created_at"},
+		"columns": format.header(),
 		"rows":    tableData,
-		"meta": gin.H{
-			"count":  len(tableData),
-			"limit":  filters.Limit,
-			"offset": filters.Offset,
-		},
+		"meta":    pageMeta(c, page, filters.Limit, filters.Offset),
 	})
 }
 
@@ -115,110 +186,104 @@ func (h *ProblemHandler) TableView(c *gin.Context) {
 // @Tags         problems, csv, download, search, filter, order
 // @Accept       json
 // @Produce      text/csv
-// @Param        method         query    string  false  "HTTP method filter (GET, POST, etc.)"
-// @Param        response       query    int     false  "Response status code filter"
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
 // @Param        min_time       query    int     false  "Minimum response time in milliseconds"
 // @Param        max_time       query    int     false  "Maximum response time in milliseconds"
-// @Param        created_after  query    string  false  "Filter problems created after date (format: 2006-01-02)"
-// @Param        created_before query    string  false  "Filter problems created before date (format: 2006-01-02)"
+// @Param        created_after  query    string  false  "Only problems created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only problems created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps"
 // @Param        search         query    string  false  "Search in request path"
-// @Param        sort           query    string  false  "Sort by field (response_time, created_at)"
-// @Param        limit          query    int     false  "Maximum number of results (default: 100)"
-// @Param        offset         query    int     false  "Number of results to skip (default: 0)"
-// @Success      200  {string}  string  "CSV file download"
+// @Param        problem_type   query    string  false  "Problem types, comma separated, ! to exclude (e.g. not_found,server_error)"
+// @Param        rule_version   query    string  false  "Versions of the detection rules that recorded the problems, comma separated, none for unversioned, ! to exclude (e.g. !1)"
+// @Param        group_by       query    string  false  "request to list each request with its matching problems once, sorted by created_at, response_time, method, path, response or problem_count"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        fields         query    string  false  "Comma separated columns in order (id, request_id, problem_type, description, method, response, path, response_time, threshold_ms, created_at, rule_version; grouped by request: request_id, method, response, path, response_time, problem_count, problem_types, created_at)"
+// @Param        time_format    query    string  false  "Format of timestamps: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)"
+// @Param        limit          query    int     false  "Maximum number of rows (default: every matching row)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor of a previous page to start from"
+// @Param        total          query    bool    false  "Also count every matching row in X-Total-Count"
+// @Success      200  {string}  string  "CSV file download, streamed and gzip compressed when accepted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
 // @Failure      500  {object}  map[string]string  "Internal server error"
 // @Router       /problems/csv [get]
 func (h *ProblemHandler) CSVExport(c *gin.Context) {
-	filters := parseProblemFilters(c)
-	problems, err := h.repo.List(filters)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Create CSV writer
-	var buf bytes.Buffer
-	writer := csv.NewWriter(&buf)
-
-	// Write header
-	writer.Write([]string{"problem_type", "description", "method", "response", "path", "response_time", "threshold_ms", "created_at"})
-
-	// Write rows
-	for _, p := range problems {
-		writer.Write([]string{
-			p.ProblemType,
-			p.Description,
-			p.Method,
-			strconv.Itoa(p.ResponseStatus),
-			p.Path,
-			strconv.FormatInt(p.ResponseTimeMs, 10),
-			strconv.FormatInt(p.ThresholdMs, 10),
-			p.CreatedAt.Format(time.RFC3339),
-		})
-	}
-
-	// Flush to ensure all data is written
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CSV"})
-		return
-	}
-
-	c.Header("Content-Type", "text/csv")
-	c.Header("Content-Disposition", "attachment; filename=problems.csv")
-	c.String(http.StatusOK, buf.String())
+	h.export(c, ExportFormatCSV)
 }
 
-func parseProblemFilters(c *gin.Context) repository.ProblemFilters {
-	filters := repository.ProblemFilters{
-		Method: c.Query("method"),
-		Search: c.Query("search"),
-		SortBy: c.Query("sort"),
-		Limit:  100,
-		Offset: 0,
-	}
-
-	if response := c.Query("response"); response != "" {
-		if val, err := strconv.Atoi(response); err == nil {
-			filters.Response = val
-		}
-	}
-
-	if minTime := c.Query("min_time"); minTime != "" {
-		if val, err := strconv.ParseInt(minTime, 10, 64); err == nil {
-			filters.MinTime = val
-		}
-	}
-
-	if maxTime := c.Query("max_time"); maxTime != "" {
-		if val, err := strconv.ParseInt(maxTime, 10, 64); err == nil {
-			filters.MaxTime = val
-		}
-	}
-
-	if createdAfter := c.Query("created_after"); createdAfter != "" {
-		if t, err := time.Parse("2006-01-02", createdAfter); err == nil {
-			filters.CreatedAfter = t
-		}
-	}
-
-	if createdBefore := c.Query("created_before"); createdBefore != "" {
-		if t, err := time.Parse("2006-01-02", createdBefore); err == nil {
-			filters.CreatedBefore = t
-		}
-	}
+// Export godoc
+// @Summary      Export problems as NDJSON, Excel, Parquet or CSV
+// @Description  Stream every problem matching the filters as a file for notebooks and spreadsheets, with the columns and sort of the table and CSV views
+// @Tags         problems, download, search, filter, order
+// @Accept       json
+// @Produce      application/x-ndjson
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce      application/vnd.apache.parquet
+// @Produce      text/csv
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
+// @Param        min_time       query    int     false  "Minimum response time in milliseconds"
+// @Param        max_time       query    int     false  "Maximum response time in milliseconds"
+// @Param        created_after  query    string  false  "Only problems created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only problems created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps"
+// @Param        search         query    string  false  "Search in request path"
+// @Param        problem_type   query    string  false  "Problem types, comma separated, ! to exclude (e.g. not_found,server_error)"
+// @Param        rule_version   query    string  false  "Versions of the detection rules that recorded the problems, comma separated, none for unversioned, ! to exclude (e.g. !1)"
+// @Param        group_by       query    string  false  "request to list each request with its matching problems once, sorted by created_at, response_time, method, path, response or problem_count"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        format         query    string  false  "File format: csv (default), ndjson, xlsx or parquet"
+// @Param        fields         query    string  false  "Comma separated columns in order (id, request_id, problem_type, description, method, response, path, response_time, threshold_ms, created_at, rule_version; grouped by request: request_id, method, response, path, response_time, problem_count, problem_types, created_at)"
+// @Param        time_format    query    string  false  "Format of timestamps in csv and ndjson: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)"
+// @Param        limit          query    int     false  "Maximum number of rows (default: every matching row)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor of a previous page to start from"
+// @Param        total          query    bool    false  "Also count every matching row in X-Total-Count"
+// @Success      200  {string}  string  "File download, streamed; csv and ndjson gzip compressed when accepted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /problems/export [get]
+func (h *ProblemHandler) Export(c *gin.Context) {
+	h.export(c, "")
+}
 
-	if limit := c.Query("limit"); limit != "" {
-		if val, err := strconv.Atoi(limit); err == nil && val > 0 {
-			filters.Limit = val
+// export streams the problems matching the query in file format, or in the
+// format of the format parameter when file is empty
+func (h *ProblemHandler) export(c *gin.Context, file string) {
+	if groupedByRequest(c) {
+		filters, format, fileFormat, err := parseProblemExport(c, problemGroupColumns, file)
+		if err != nil {
+			listError(c, err)
+			return
 		}
+		writeExport(c, "problems-by-request", fileFormat, format, exportSource[models.RequestProblems]{
+			each:      func(fn func(*models.RequestProblems) error) error { return h.repo.EachGroup(filters, fn) },
+			count:     func() (int, error) { return h.repo.CountGroups(filters) },
+			limit:     filters.Limit,
+			withTotal: filters.WithTotal,
+		})
+		return
 	}
 
-	if offset := c.Query("offset"); offset != "" {
-		if val, err := strconv.Atoi(offset); err == nil && val >= 0 {
-			filters.Offset = val
-		}
+	filters, format, fileFormat, err := parseProblemExport(c, problemColumns, file)
+	if err != nil {
+		listError(c, err)
+		return
 	}
 
-	return filters
+	writeExport(c, "problems", fileFormat, format, exportSource[models.Problem]{
+		each:      func(fn func(*models.Problem) error) error { return h.repo.Each(filters, fn) },
+		count:     func() (int, error) { return h.repo.Count(filters) },
+		limit:     filters.Limit,
+		withTotal: filters.WithTotal,
+	})
 }
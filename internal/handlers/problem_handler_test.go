@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -291,3 +292,151 @@ func TestListProblems_FilterByResponseStatus(t *testing.T) {
 		t.Errorf("Expected response status 404, got %v", problem["response"])
 	}
 }
+
+// Test 7: Problems page with cursors
+func TestListProblems_CursorPagination(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+	handler := NewProblemHandler(problemRepo)
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		testutil.CreateTestProblem(t, problemRepo,
+			int(testutil.CreateTestRequest(t, requestRepo, "GET", path, 404, 100)),
+			"not_found", "Not found", 0)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/problems", handler.ListProblems)
+
+	seen := map[string]bool{}
+	url := "/api/problems?limit=2&total=true"
+	for url != "" {
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+		for _, item := range response["data"].([]any) {
+			seen[item.(map[string]any)["path"].(string)] = true
+		}
+		if total := response["meta"].(map[string]any)["total"]; total.(float64) != 3 {
+			t.Errorf("Expected total 3, got %v", total)
+		}
+
+		url = ""
+		if next := response["meta"].(map[string]any)["next_cursor"].(string); next != "" {
+			url = "/api/problems?limit=2&total=true&cursor=" + next
+		}
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("Expected every problem once across pages, got %v", seen)
+	}
+}
+
+// Test 8: Problem tables can show the problem and request ids
+func TestProblemTable_Fields(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+	handler := NewProblemHandler(problemRepo)
+
+	requestID := testutil.CreateTestRequest(t, requestRepo, "GET", "/anime/999", 404, 150)
+	problemID := testutil.CreateTestProblem(t, problemRepo, int(requestID), "not_found", "Not found", 0)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/problems/csv", handler.CSVExport)
+
+	req := httptest.NewRequest("GET", "/api/problems/csv?fields=id,request_id,problem_type", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expected := fmt.Sprintf("id,request_id,problem_type\n%d,%d,not_found\n", problemID, requestID)
+	if w.Body.String() != expected {
+		t.Errorf("Expected CSV %q, got %q", expected, w.Body.String())
+	}
+}
+
+// Test 9: group_by=request lists each request once with its problems in the list, table and CSV views
+func TestProblems_GroupByRequest(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+	handler := NewProblemHandler(problemRepo)
+
+	slowID := int(testutil.CreateTestRequest(t, requestRepo, "GET", "/anime/999", 404, 3000))
+	testutil.CreateTestProblem(t, problemRepo, slowID, "not_found", "Not found", 0)
+	testutil.CreateTestProblem(t, problemRepo, slowID, "slow_response", "Slow", 400)
+	otherID := int(testutil.CreateTestRequest(t, requestRepo, "GET", "/anime/998", 404, 100))
+	testutil.CreateTestProblem(t, problemRepo, otherID, "not_found", "Not found", 0)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/problems", handler.ListProblems)
+	router.GET("/api/problems/table", handler.TableView)
+	router.GET("/api/problems/csv", handler.CSVExport)
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	w := get("/api/problems?group_by=request&sort=-problem_count&total=true")
+	var list struct {
+		Data []struct {
+			RequestID    int      `json:"request_id"`
+			ProblemTypes []string `json:"problem_types"`
+			Problems     []any    `json:"problems"`
+		} `json:"data"`
+		Meta struct {
+			Total int `json:"total"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != 200 {
+		t.Fatalf("Unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if len(list.Data) != 2 || list.Meta.Total != 2 || list.Data[0].RequestID != slowID || len(list.Data[0].Problems) != 2 ||
+		strings.Join(list.Data[0].ProblemTypes, ",") != "not_found,slow_response" {
+		t.Errorf("Expected the slow 404 first with both problems, got %s", w.Body.String())
+	}
+
+	w = get("/api/problems/table?group_by=request&fields=request_id,problem_count&sort=request_id")
+	if w.Code != 400 {
+		t.Errorf("Expected sorting groups by an unknown field to be rejected, got %d", w.Code)
+	}
+	w = get("/api/problems/table?group_by=request&fields=request_id,problem_count&sort=-problem_count")
+	var table struct {
+		Columns []string `json:"columns"`
+		Rows    [][]any  `json:"rows"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil || len(table.Rows) != 2 || table.Rows[0][1] != float64(2) {
+		t.Errorf("Expected a table of 2 requests, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = get("/api/problems/csv?group_by=request&fields=path,problem_count,problem_types&sort=-problem_count")
+	expected := "path,problem_count,problem_types\n/anime/999,2,\"not_found,slow_response\"\n/anime/998,1,not_found\n"
+	if w.Body.String() != expected {
+		t.Errorf("Expected CSV %q, got %q", expected, w.Body.String())
+	}
+
+	if w := get("/api/problems/csv?group_by=request&fields=problem_type"); w.Code != 400 {
+		t.Errorf("Expected problem columns to be rejected when grouped, got %d", w.Code)
+	}
+	if w := get("/api/problems?group_by=path"); w.Code != 400 {
+		t.Errorf("Expected an unknown grouping to be rejected, got %d", w.Code)
+	}
+}
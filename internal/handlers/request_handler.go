@@ -1,22 +1,71 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/csv"
+	"errors"
+	"io"
 	"net/http"
-	"strconv"
-	"time"
+	"treblle_project/internal/detector"
+	"treblle_project/internal/importer"
+	"treblle_project/internal/jikan"
+	"treblle_project/internal/models"
 	"treblle_project/internal/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
+// requestColumns are the columns of the request table and CSV views
+var requestColumns = columnSet[models.APIRequest]{
+	columns: []column[models.APIRequest]{
+		{"id", func(r *models.APIRequest) any { return r.ID }},
+		{"method", func(r *models.APIRequest) any { return r.Method }},
+		{"response", func(r *models.APIRequest) any { return r.ResponseStatus }},
+		{"path", func(r *models.APIRequest) any { return r.Path }},
+		{"response_time", func(r *models.APIRequest) any { return r.ResponseTimeMs }},
+		{"response_bytes", func(r *models.APIRequest) any { return r.ResponseBytes }},
+		{"content_type", func(r *models.APIRequest) any { return r.ContentType }},
+		{"upstream_headers", func(r *models.APIRequest) any { return r.UpstreamHeaders }},
+		{"dns_ms", func(r *models.APIRequest) any { return r.Timing.DNSMs }},
+		{"connect_ms", func(r *models.APIRequest) any { return r.Timing.ConnectMs }},
+		{"tls_ms", func(r *models.APIRequest) any { return r.Timing.TLSMs }},
+		{"ttfb_ms", func(r *models.APIRequest) any { return r.Timing.TTFBMs }},
+		{"transfer_ms", func(r *models.APIRequest) any { return r.Timing.TransferMs }},
+		{"conn_reused", func(r *models.APIRequest) any { return r.Timing.ConnReused }},
+		{"throttle_status", func(r *models.APIRequest) any { return r.ThrottleStatus }},
+		{"queue_wait_ms", func(r *models.APIRequest) any { return r.QueueWaitMs }},
+		{"outcome", func(r *models.APIRequest) any { return r.Outcome }},
+		{"cache_status", func(r *models.APIRequest) any { return r.CacheStatus }},
+		{"coalesced", func(r *models.APIRequest) any { return r.Coalesced }},
+		{"upstream_call_id", func(r *models.APIRequest) any { return r.UpstreamCallID }},
+		{"parent_id", func(r *models.APIRequest) any { return r.ParentID }},
+		{"attempt", func(r *models.APIRequest) any { return r.Attempt }},
+		{"created_at", func(r *models.APIRequest) any { return r.CreatedAt }},
+	},
+	defaults: []string{"method", "response", "path", "response_time", "response_bytes", "content_type", "upstream_headers", "created_at"},
+}
+
 type RequestHandler struct {
-	repo *repository.RequestRepository
+	repo     *repository.RequestRepository
+	importer *importer.Importer
+
+	// UpstreamURL is the base URL logged paths are relative to, used for
+	// the URLs of HAR exports
+	UpstreamURL string
+
+	// MaxImportBytes caps the size of import bodies, larger ones are
+	// rejected with 413
+	MaxImportBytes int64
 }
 
+// DefaultMaxImportBytes is the default RequestHandler.MaxImportBytes
+const DefaultMaxImportBytes = 256 << 20
+
 func NewRequestHandler(repo *repository.RequestRepository) *RequestHandler {
-	return &RequestHandler{repo: repo}
+	return &RequestHandler{
+		repo:           repo,
+		importer:       importer.NewImporter(repo),
+		UpstreamURL:    jikan.BaseURL,
+		MaxImportBytes: DefaultMaxImportBytes,
+	}
 }
 
 // ListRequests godoc
@@ -25,34 +74,54 @@ func NewRequestHandler(repo *repository.RequestRepository) *RequestHandler {
 // @Tags         requests, filter, order, search, list
 // @Accept       json
 // @Produce      json
-// @Param        method         query    string  false  "HTTP method filter (GET, POST, etc.)"
-// @Param        response       query    int     false  "Response status code filter"
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
 // @Param        min_time       query    int     false  "Minimum response time in milliseconds"
 // @Param        max_time       query    int     false  "Maximum response time in milliseconds"
-// @Param        created_after  query    string  false  "Filter requests created after date (format: 2006-01-02)"
-// @Param        created_before query    string  false  "Filter requests created before date (format: 2006-01-02)"
+// @Param        created_after  query    string  false  "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only requests created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC)"
+// @Param        min_bytes      query    int     false  "Minimum response size in bytes"
+// @Param        max_bytes      query    int     false  "Maximum response size in bytes"
+// @Param        content_type   query    string  false  "Response Content-Type prefix (e.g. application/json)"
+// @Param        header         query    []string false "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)" collectionFormat(multi)
+// @Param        throttle       query    string  false  "Rate limiting outcome (none, queued, rejected)"
+// @Param        outcome        query    string  false  "Request outcome (none, circuit_open)"
+// @Param        cache_status   query    string  false  "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)"
+// @Param        coalesced      query    bool    false  "Only requests served (true) or not served (false) by another request's upstream call"
+// @Param        upstream_call_id query  string  false  "Only requests produced by this upstream call"
+// @Param        parent_id      query    int     false  "Only the retry attempts of this request"
+// @Param        exclude_attempts query  bool    false  "Leave out retry attempt rows"
 // @Param        search         query    string  false  "Search in request path"
-// @Param        sort           query    string  false  "Sort by field (response_time, created_at)"
-// @Param        limit          query    int     false  "Maximum number of results (default: 100)"
-// @Param        offset         query    int     false  "Number of results to skip (default: 0)"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)"
+// @Param        limit          query    int     false  "Maximum number of results (default: 100, max: 1000)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor or prev_cursor of a previous page"
+// @Param        total          query    bool    false  "Also count every matching row (meta.total, X-Total-Count on CSV)"
 // @Success      200  {object}  map[string]interface{}  "List of requests with metadata"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
 // @Failure      500  {object}  map[string]string       "Internal server error"
 // @Router       /requests [get]
 func (h *RequestHandler) ListRequests(c *gin.Context) {
-	filters := parseRequestFilters(c)
-	requests, err := h.repo.List(filters)
+	filters, err := parseRequestFilters(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		listError(c, err)
 		return
 	}
+	page, err := h.repo.ListPage(filters)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	requests := page.Items
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": requests,
-		"meta": gin.H{
-			"count":  len(requests),
-			"limit":  filters.Limit,
-			"offset": filters.Offset,
-		},
+		"meta": pageMeta(c, page, filters.Limit, filters.Offset),
 	})
 }
 
@@ -62,154 +131,348 @@ func (h *RequestHandler) ListRequests(c *gin.Context) {
 // @Tags         requests, table, search, filter, order
 // @Accept       json
 // @Produce      json
-// @Param        method         query    string  false  "HTTP method filter (GET, POST, etc.)"
-// @Param        response       query    int     false  "Response status code filter"
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
 // @Param        min_time       query    int     false  "Minimum response time in milliseconds"
 // @Param        max_time       query    int     false  "Maximum response time in milliseconds"
-// @Param        created_after  query    string  false  "Filter requests created after date (format: 2006-01-02)"
-// @Param        created_before query    string  false  "Filter requests created before date (format: 2006-01-02)"
+// @Param        created_after  query    string  false  "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only requests created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps"
+// @Param        min_bytes      query    int     false  "Minimum response size in bytes"
+// @Param        max_bytes      query    int     false  "Maximum response size in bytes"
+// @Param        content_type   query    string  false  "Response Content-Type prefix (e.g. application/json)"
+// @Param        header         query    []string false "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)" collectionFormat(multi)
+// @Param        throttle       query    string  false  "Rate limiting outcome (none, queued, rejected)"
+// @Param        outcome        query    string  false  "Request outcome (none, circuit_open)"
+// @Param        cache_status   query    string  false  "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)"
+// @Param        coalesced      query    bool    false  "Only requests served (true) or not served (false) by another request's upstream call"
+// @Param        upstream_call_id query  string  false  "Only requests produced by this upstream call"
+// @Param        parent_id      query    int     false  "Only the retry attempts of this request"
+// @Param        exclude_attempts query  bool    false  "Leave out retry attempt rows"
 // @Param        search         query    string  false  "Search in request path"
-// @Param        sort           query    string  false  "Sort by field (response_time, created_at)"
-// @Param        limit          query    int     false  "Maximum number of results (default: 100)"
-// @Param        offset         query    int     false  "Number of results to skip (default: 0)"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        fields         query    string  false  "Comma separated columns in order (id, method, response, path, response_time, response_bytes, content_type, upstream_headers, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, conn_reused, throttle_status, queue_wait_ms, outcome, cache_status, coalesced, upstream_call_id, parent_id, attempt, created_at)"
+// @Param        time_format    query    string  false  "Format of timestamps: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)"
+// @Param        limit          query    int     false  "Maximum number of results (default: 100, max: 1000)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor or prev_cursor of a previous page"
+// @Param        total          query    bool    false  "Also count every matching row (meta.total, X-Total-Count on CSV)"
 // @Success      200  {object}  map[string]interface{}  "Table data with columns and rows"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
 // @Failure      500  {object}  map[string]string       "Internal server error"
 // @Router       /requests/table [get]
 func (h *RequestHandler) TableView(c *gin.Context) {
-	filters := parseRequestFilters(c)
-	requests, err := h.repo.List(filters)
+	filters, format, err := parseRequestTable(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		listError(c, err)
+		return
+	}
+	page, err := h.repo.ListPage(filters)
+	if err != nil {
+		listError(c, err)
 		return
 	}
+	requests := page.Items
 
 	// Format as table structure
 	tableData := make([][]any, 0, len(requests))
-	for _, req := range requests {
-		tableData = append(tableData, []any{
-			req.Method,
-			req.ResponseStatus,
-			req.Path,
-			req.ResponseTimeMs,
-			req.CreatedAt.Format(time.RFC3339),
-		})
+	for i := range requests {
+		tableData = append(tableData, format.row(&requests[i]))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"columns": []string{"method", "response", "path", "response_time", "created_at"},
+		"columns": format.header(),
 		"rows":    tableData,
-		"meta": gin.H{
-			"count":  len(tableData),
-			"limit":  filters.Limit,
-			"offset": filters.Offset,
-		},
+		"meta":    pageMeta(c, page, filters.Limit, filters.Offset),
 	})
 }
 
+// Stats godoc
+// @Summary      Aggregated latency statistics for API requests
+// @Description  Get request count, response time range and the average latency breakdown (DNS, connect, TLS, time to first byte, transfer) for the matching requests, to tell upstream processing time apart from network time
+// @Tags         requests, stats, filter, search
+// @Accept       json
+// @Produce      json
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
+// @Param        min_time       query    int     false  "Minimum response time in milliseconds"
+// @Param        max_time       query    int     false  "Maximum response time in milliseconds"
+// @Param        created_after  query    string  false  "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only requests created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC)"
+// @Param        min_bytes      query    int     false  "Minimum response size in bytes"
+// @Param        max_bytes      query    int     false  "Maximum response size in bytes"
+// @Param        content_type   query    string  false  "Response Content-Type prefix (e.g. application/json)"
+// @Param        header         query    []string false "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)" collectionFormat(multi)
+// @Param        throttle       query    string  false  "Rate limiting outcome (none, queued, rejected)"
+// @Param        outcome        query    string  false  "Request outcome (none, circuit_open)"
+// @Param        cache_status   query    string  false  "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)"
+// @Param        coalesced      query    bool    false  "Only requests served (true) or not served (false) by another request's upstream call"
+// @Param        upstream_call_id query  string  false  "Only requests produced by this upstream call"
+// @Param        parent_id      query    int     false  "Only the retry attempts of this request"
+// @Param        exclude_attempts query  bool    false  "Leave out retry attempt rows"
+// @Param        search         query    string  false  "Search in request path"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Success      200  {object}  models.RequestStats  "Aggregated statistics"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
+// @Failure      500  {object}  map[string]string   "Internal server error"
+// @Router       /requests/stats [get]
+func (h *RequestHandler) Stats(c *gin.Context) {
+	filters, err := parseRequestFilters(c)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	stats, err := h.repo.Stats(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
 // CSVExport godoc
 // @Summary      Export successfully completed API requests as CSV
 // @Description  Download successfully completed API requests as a CSV file, supports filtering, ordering and searching, ready for use
 // @Tags         requests, download, csv, filter, order, search
 // @Accept       json
 // @Produce      text/csv
-// @Param        method         query    string  false  "HTTP method filter (GET, POST, etc.)"
-// @Param        response       query    int     false  "Response status code filter"
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
 // @Param        min_time       query    int     false  "Minimum response time in milliseconds"
 // @Param        max_time       query    int     false  "Maximum response time in milliseconds"
-// @Param        created_after  query    string  false  "Filter requests created after date (format: 2006-01-02)"
-// @Param        created_before query    string  false  "Filter requests created before date (format: 2006-01-02)"
+// @Param        created_after  query    string  false  "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only requests created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps"
+// @Param        min_bytes      query    int     false  "Minimum response size in bytes"
+// @Param        max_bytes      query    int     false  "Maximum response size in bytes"
+// @Param        content_type   query    string  false  "Response Content-Type prefix (e.g. application/json)"
+// @Param        header         query    []string false "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)" collectionFormat(multi)
+// @Param        throttle       query    string  false  "Rate limiting outcome (none, queued, rejected)"
+// @Param        outcome        query    string  false  "Request outcome (none, circuit_open)"
+// @Param        cache_status   query    string  false  "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)"
+// @Param        coalesced      query    bool    false  "Only requests served (true) or not served (false) by another request's upstream call"
+// @Param        upstream_call_id query  string  false  "Only requests produced by this upstream call"
+// @Param        parent_id      query    int     false  "Only the retry attempts of this request"
+// @Param        exclude_attempts query  bool    false  "Leave out retry attempt rows"
 // @Param        search         query    string  false  "Search in request path"
-// @Param        sort           query    string  false  "Sort by field (response_time, created_at)"
-// @Param        limit          query    int     false  "Maximum number of results (default: 100)"
-// @Param        offset         query    int     false  "Number of results to skip (default: 0)"
-// @Success      200  {string}  string  "CSV file download"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        fields         query    string  false  "Comma separated columns in order (id, method, response, path, response_time, response_bytes, content_type, upstream_headers, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, conn_reused, throttle_status, queue_wait_ms, outcome, cache_status, coalesced, upstream_call_id, parent_id, attempt, created_at)"
+// @Param        time_format    query    string  false  "Format of timestamps: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)"
+// @Param        limit          query    int     false  "Maximum number of rows (default: every matching row)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor of a previous page to start from"
+// @Param        total          query    bool    false  "Also count every matching row in X-Total-Count"
+// @Success      200  {string}  string  "CSV file download, streamed and gzip compressed when accepted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
 // @Failure      500  {object}  map[string]string  "Internal server error"
 // @Router       /requests/csv [get]
 func (h *RequestHandler) CSVExport(c *gin.Context) {
-	filters := parseRequestFilters(c)
-	requests, err := h.repo.List(filters)
+	h.export(c, ExportFormatCSV)
+}
+
+// Export godoc
+// @Summary      Export requests as NDJSON, Excel, Parquet or CSV
+// @Description  Stream every request matching the filters as a file for notebooks and spreadsheets, with the columns and sort of the table and CSV views
+// @Tags         requests, download, search, filter, order
+// @Accept       json
+// @Produce      application/x-ndjson
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce      application/vnd.apache.parquet
+// @Produce      text/csv
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
+// @Param        min_time       query    int     false  "Minimum response time in milliseconds"
+// @Param        max_time       query    int     false  "Maximum response time in milliseconds"
+// @Param        created_after  query    string  false  "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only requests created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC) and of output timestamps"
+// @Param        min_bytes      query    int     false  "Minimum response size in bytes"
+// @Param        max_bytes      query    int     false  "Maximum response size in bytes"
+// @Param        content_type   query    string  false  "Response Content-Type prefix (e.g. application/json)"
+// @Param        header         query    []string false "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)" collectionFormat(multi)
+// @Param        throttle       query    string  false  "Rate limiting outcome (none, queued, rejected)"
+// @Param        outcome        query    string  false  "Request outcome (none, circuit_open)"
+// @Param        cache_status   query    string  false  "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)"
+// @Param        coalesced      query    bool    false  "Only requests served (true) or not served (false) by another request's upstream call"
+// @Param        upstream_call_id query  string  false  "Only requests produced by this upstream call"
+// @Param        parent_id      query    int     false  "Only the retry attempts of this request"
+// @Param        exclude_attempts query  bool    false  "Leave out retry attempt rows"
+// @Param        search         query    string  false  "Search in request path"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        format         query    string  false  "File format: csv (default), ndjson, xlsx or parquet"
+// @Param        fields         query    string  false  "Comma separated columns in order (id, method, response, path, response_time, response_bytes, content_type, upstream_headers, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, conn_reused, throttle_status, queue_wait_ms, outcome, cache_status, coalesced, upstream_call_id, parent_id, attempt, created_at)"
+// @Param        time_format    query    string  false  "Format of timestamps in csv and ndjson: rfc3339 (default), rfc3339nano, datetime, unix, unix_ms"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (e.g. -response_time,path)"
+// @Param        limit          query    int     false  "Maximum number of rows (default: every matching row)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor of a previous page to start from"
+// @Param        total          query    bool    false  "Also count every matching row in X-Total-Count"
+// @Success      200  {string}  string  "File download, streamed; csv and ndjson gzip compressed when accepted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /requests/export [get]
+func (h *RequestHandler) Export(c *gin.Context) {
+	h.export(c, "")
+}
+
+// export streams the requests matching the query in file format, or in the
+// format of the format parameter when file is empty
+func (h *RequestHandler) export(c *gin.Context, file string) {
+	filters, format, fileFormat, err := parseRequestExport(c, file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		listError(c, err)
 		return
 	}
 
-	//Create CSV writer
-	var buf bytes.Buffer
-	writer := csv.NewWriter(&buf)
-
-	//Build CSV
-	writer.Write([]string{"method", "response", "path", "response_time", "created_at"})
-	for _, req := range requests {
-		writer.Write([]string{
-			req.Method,
-			strconv.Itoa(req.ResponseStatus),
-			req.Path,
-			strconv.FormatInt(req.ResponseTimeMs, 10),
-			req.CreatedAt.Format(time.RFC3339),
-		})
-	}
+	writeExport(c, "requests", fileFormat, format, exportSource[models.APIRequest]{
+		each:      func(fn func(*models.APIRequest) error) error { return h.repo.Each(filters, fn) },
+		count:     func() (int, error) { return h.repo.Count(filters) },
+		limit:     filters.Limit,
+		withTotal: filters.WithTotal,
+	})
+}
 
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CSV"})
+// HAR godoc
+// @Summary      Export requests as a HAR file
+// @Description  Stream the matching requests as an HTTP Archive (HAR 1.2) with timings and the recorded upstream response headers, oldest first unless sorted, to open in browser devtools or other HAR viewers
+// @Tags         requests, download, search, filter, order
+// @Accept       json
+// @Produce      json
+// @Param        method         query    string  false  "HTTP methods, comma separated, ! to exclude (e.g. GET,!POST)"
+// @Param        response       query    string  false  "Response statuses, classes or ranges, comma separated, ! to exclude (e.g. 5xx,404,!503)"
+// @Param        min_time       query    int     false  "Minimum response time in milliseconds"
+// @Param        max_time       query    int     false  "Maximum response time in milliseconds"
+// @Param        created_after  query    string  false  "Only requests created at or after this time: RFC3339, YYYY-MM-DD, Unix epoch or relative (-15m, now-1d)"
+// @Param        created_before query    string  false  "Only requests created before this time (exclusive), same formats as created_after"
+// @Param        tz             query    string  false  "IANA time zone of dates and times without an offset (default: UTC)"
+// @Param        min_bytes      query    int     false  "Minimum response size in bytes"
+// @Param        max_bytes      query    int     false  "Maximum response size in bytes"
+// @Param        content_type   query    string  false  "Response Content-Type prefix (e.g. application/json)"
+// @Param        header         query    []string false "Upstream header filter as Name:value, repeatable (e.g. Cache-Control:max-age=86400)" collectionFormat(multi)
+// @Param        throttle       query    string  false  "Rate limiting outcome (none, queued, rejected)"
+// @Param        outcome        query    string  false  "Request outcome (none, circuit_open)"
+// @Param        cache_status   query    string  false  "Response cache outcome (none, HIT, MISS, STALE, REVALIDATED, REFRESH)"
+// @Param        coalesced      query    bool    false  "Only requests served (true) or not served (false) by another request's upstream call"
+// @Param        upstream_call_id query  string  false  "Only requests produced by this upstream call"
+// @Param        parent_id      query    int     false  "Only the retry attempts of this request"
+// @Param        exclude_attempts query  bool    false  "Leave out retry attempt rows"
+// @Param        search         query    string  false  "Search in request path"
+// @Param        path_regex     query    string  false  "Regular expression the path must match"
+// @Param        path_glob      query    string  false  "GLOB pattern the path must match (e.g. /anime/*)"
+// @Param        q              query    string  false  "Filter expression (e.g. status >= 500 OR (response_time > 800 AND path ~ '/anime/'))"
+// @Param        view           query    string  false  "Saved view to apply, other parameters override its settings"
+// @Param        sort           query    string  false  "Comma separated sort fields, prefix with - for descending (default: created_at, oldest first)"
+// @Param        limit          query    int     false  "Maximum number of rows (default: every matching row)"
+// @Param        offset         query    int     false  "Number of results to skip (default: 0), ignored with cursor"
+// @Param        cursor         query    string  false  "Opaque cursor from next_cursor of a previous page to start from"
+// @Param        total          query    bool    false  "Also count every matching row in X-Total-Count"
+// @Success      200  {object}  map[string]interface{}  "HAR document download, streamed and gzip compressed when accepted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /requests/har [get]
+func (h *RequestHandler) HAR(c *gin.Context) {
+	filters, err := parseRequestHAR(c)
+	if err != nil {
+		listError(c, err)
 		return
 	}
 
-	c.Header("Content-Type", "text/csv")
-	c.Header("Content-Disposition", "attachment; filename=requests.csv")
-	c.String(http.StatusOK, buf.String())
+	writeHAR(c, h.UpstreamURL, exportSource[models.APIRequest]{
+		each:      func(fn func(*models.APIRequest) error) error { return h.repo.Each(filters, fn) },
+		count:     func() (int, error) { return h.repo.Count(filters) },
+		withTotal: filters.WithTotal,
+	})
 }
 
-func parseRequestFilters(c *gin.Context) repository.RequestFilters {
-	filters := repository.RequestFilters{
-		Method: c.Query("method"),
-		Search: c.Query("search"),
-		SortBy: c.Query("sort"),
-		Limit:  100,
-		Offset: 0,
-	}
-
-	if response := c.Query("response"); response != "" {
-		if val, err := strconv.Atoi(response); err == nil {
-			filters.Response = val
-		}
+// Import godoc
+// @Summary      Import request logs
+// @Description  Import logged requests from a JSONL file, with objects as the NDJSON export writes them or as the API lists them, or from a CSV file with a header line of export column names. Every line is validated, valid rows are inserted in batches of one transaction each and invalid lines are reported with their line number. Ids only link retry attempts to their parent within the file, imported rows get new ids. Bodies are limited to 256 MiB.
+// @Tags         requests, import
+// @Accept       plain
+// @Accept       mpfd
+// @Produce      json
+// @Security     AdminToken
+// @Param        format     query     string  false  "File format (jsonl, csv), guessed from the file name or Content-Type when absent"
+// @Param        detect     query     bool    false  "Run problem detection on the imported rows (default: false)"
+// @Param        batch_size query     int     false  "Rows inserted per transaction (default: 500, max: 10000)"
+// @Param        tz         query     string  false  "IANA time zone of created_at values without an offset (default: the server's local time)"
+// @Param        file       formData  file    false  "File to import, when uploading a multipart form instead of sending the file as the body"
+// @Success      200  {object}  importer.Result  "Import summary with the rejected lines"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json) or a file that can't be imported"
+// @Failure      401  {object}  map[string]interface{}  "Missing or invalid admin token"
+// @Failure      413  {object}  map[string]interface{}  "Body over the size limit, batches inserted before it are kept"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error, batches inserted before it are kept"
+// @Failure      503  {object}  map[string]interface{}  "Imports are disabled because ADMIN_TOKEN is not set"
+// @Router       /requests/import [post]
+func (h *RequestHandler) Import(c *gin.Context) {
+	p := newQueryParser(c.Request.URL.Query())
+	opts := importer.Options{BatchSize: importer.DefaultBatchSize}
+	p.oneOf("format", &opts.Format, importer.FormatJSONL, importer.FormatCSV)
+	var detect bool
+	p.bool("detect", &detect)
+	if detect {
+		opts.Detector = detector.Default
 	}
-
-	if minTime := c.Query("min_time"); minTime != "" {
-		if val, err := strconv.ParseInt(minTime, 10, 64); err == nil {
-			filters.MinTime = val
-		}
+	p.int("batch_size", &opts.BatchSize, 1, 10000)
+	if p.query.Get("tz") != "" {
+		opts.Location = p.location()
 	}
 
-	if maxTime := c.Query("max_time"); maxTime != "" {
-		if val, err := strconv.ParseInt(maxTime, 10, 64); err == nil {
-			filters.MaxTime = val
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxImportBytes)
+	var body io.Reader = c.Request.Body
+	name, contentType := "", c.ContentType()
+	if contentType == "multipart/form-data" {
+		file, header, err := c.Request.FormFile("file")
+		if tooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
 		}
-	}
-
-	if createdAfter := c.Query("created_after"); createdAfter != "" {
-		if t, err := time.Parse("2006-01-02", createdAfter); err == nil {
-			filters.CreatedAfter = t
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload: " + err.Error()})
+			return
 		}
+		defer file.Close()
+		body, name, contentType = file, header.Filename, header.Header.Get("Content-Type")
 	}
-
-	if createdBefore := c.Query("created_before"); createdBefore != "" {
-		if t, err := time.Parse("2006-01-02", createdBefore); err == nil {
-			filters.CreatedBefore = t
-		}
+	if opts.Format == "" {
+		opts.Format = importer.FormatOf(name, contentType)
+		p.ensure(opts.Format != "", "format", "must be jsonl or csv when the file name or Content-Type doesn't tell")
 	}
-
-	if limit := c.Query("limit"); limit != "" {
-		if val, err := strconv.Atoi(limit); err == nil && val > 0 {
-			filters.Limit = val
-		}
+	if err := p.err(); err != nil {
+		listError(c, err)
+		return
 	}
 
-	if offset := c.Query("offset"); offset != "" {
-		if val, err := strconv.Atoi(offset); err == nil && val >= 0 {
-			filters.Offset = val
-		}
+	result, err := h.importer.Import(body, opts)
+	switch {
+	case tooLarge(err):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error(), "imported": result.Imported})
+	case errors.Is(err, importer.ErrInvalidFile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "imported": result.Imported})
+	default:
+		c.JSON(http.StatusOK, result)
 	}
+}
 
-	return filters
+// tooLarge reports whether err comes from reading past a MaxBytesReader limit
+func tooLarge(err error) bool {
+	var maxBytes *http.MaxBytesError
+	return errors.As(err, &maxBytes)
 }
@@ -1,14 +1,26 @@
 package handlers
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+	"treblle_project/internal/models"
 	"treblle_project/internal/repository"
 	"treblle_project/internal/testutil"
 
 	"github.com/gin-gonic/gin"
+	"github.com/parquet-go/parquet-go"
 )
 
 // Test 1: ListRequests returns JSON with data
@@ -178,7 +190,7 @@ func TestTableView_ReturnsStructuredData(t *testing.T) {
 		t.Fatalf("Expected 'columns' field in response")
 	}
 
-	expectedColumns := []string{"method", "response", "path", "response_time", "created_at"}
+	expectedColumns := []string{"method", "response", "path", "response_time", "response_bytes", "content_type", "upstream_headers", "created_at"}
 	if len(columns) != len(expectedColumns) {
 		t.Errorf("Expected %d columns, got %d", len(expectedColumns), len(columns))
 	}
@@ -195,8 +207,8 @@ func TestTableView_ReturnsStructuredData(t *testing.T) {
 
 	// Verify row structure (should be an array)
 	row := rows[0].([]interface{})
-	if len(row) != 5 {
-		t.Errorf("Expected 5 values in row, got %d", len(row))
+	if len(row) != len(expectedColumns) {
+		t.Errorf("Expected %d values in row, got %d", len(expectedColumns), len(row))
 	}
 
 	// Verify first value is the method
@@ -267,7 +279,7 @@ func TestListRequests_SortByResponseTime(t *testing.T) {
 	router.GET("/api/requests", handler.ListRequests)
 
 	// Make request with sort parameter
-	req := httptest.NewRequest("GET", "/api/requests?sort=response_time", nil)
+	req := httptest.NewRequest("GET", "/api/requests?sort=-response_time", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -294,3 +306,756 @@ func TestListRequests_SortByResponseTime(t *testing.T) {
 		t.Errorf("Expected last result to have response_time 100, got %v", last["response_time"])
 	}
 }
+
+// Test 7: Cursor pagination reports cursors, total and Link headers
+func TestListRequests_CursorPagination(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewRequestRepository(db)
+	handler := NewRequestHandler(repo)
+
+	testutil.CreateTestRequest(t, repo, "GET", "/slow", 200, 300)
+	testutil.CreateTestRequest(t, repo, "GET", "/fast", 200, 100)
+	testutil.CreateTestRequest(t, repo, "GET", "/medium", 200, 200)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests", handler.ListRequests)
+	router.GET("/api/requests/csv", handler.CSVExport)
+
+	req := httptest.NewRequest("GET", "/api/requests?sort=-response_time&limit=2&total=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response map[string]any
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	meta := response["meta"].(map[string]any)
+	if meta["total"].(float64) != 3 || meta["count"].(float64) != 2 {
+		t.Errorf("Expected total 3 and count 2, got %v", meta)
+	}
+	next := meta["next_cursor"].(string)
+	if next == "" || meta["prev_cursor"] != "" {
+		t.Fatalf("Expected only a next cursor on the first page, got %v", meta)
+	}
+
+	link := w.Header().Get("Link")
+	if !strings.Contains(link, `rel="next"`) || !strings.Contains(link, "cursor="+next) || strings.Contains(link, `rel="prev"`) {
+		t.Errorf("Unexpected Link header %q", link)
+	}
+
+	// Follow the next link
+	req = httptest.NewRequest("GET", "/api/requests?sort=-response_time&limit=2&cursor="+next, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	response = nil
+	json.Unmarshal(w.Body.Bytes(), &response)
+	data := response["data"].([]any)
+	if len(data) != 1 || data[0].(map[string]any)["response_time"].(float64) != 100 {
+		t.Errorf("Expected the fastest request on the second page, got %v", data)
+	}
+	meta = response["meta"].(map[string]any)
+	if meta["next_cursor"] != "" || meta["prev_cursor"] == "" {
+		t.Errorf("Expected only a prev cursor on the last page, got %v", meta)
+	}
+	if _, ok := meta["total"]; ok {
+		t.Error("Expected no total without total=true")
+	}
+
+	// CSV exports carry the count in a header
+	req = httptest.NewRequest("GET", "/api/requests/csv?limit=2&total=true", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get(TotalCountHeader) != "3" || w.Header().Get("Link") != "" {
+		t.Errorf("Expected a total and no Link header on the CSV export, got %v", w.Header())
+	}
+
+	// Invalid cursors are client errors
+	req = httptest.NewRequest("GET", "/api/requests?cursor=bogus", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid cursor, got %d", w.Code)
+	}
+}
+
+// Test 8: Ascending and multi-key sorts, unknown fields are rejected
+func TestListRequests_SortSyntax(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewRequestRepository(db)
+	handler := NewRequestHandler(repo)
+
+	testutil.CreateTestRequest(t, repo, "POST", "/b", 200, 300)
+	testutil.CreateTestRequest(t, repo, "GET", "/c", 404, 100)
+	testutil.CreateTestRequest(t, repo, "GET", "/a", 200, 100)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests", handler.ListRequests)
+
+	tests := []struct {
+		sort     string
+		expected string
+	}{
+		{"response_time,path", "/a,/c,/b"},
+		{"method,-status", "/c,/a,/b"},
+		{"-path", "/c,/b,/a"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/requests?sort="+tt.sort, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("sort=%s: expected status 200, got %d", tt.sort, w.Code)
+		}
+
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		var paths []string
+		for _, item := range response["data"].([]any) {
+			paths = append(paths, item.(map[string]any)["path"].(string))
+		}
+		if got := strings.Join(paths, ","); got != tt.expected {
+			t.Errorf("sort=%s: expected %s, got %s", tt.sort, tt.expected, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/requests?sort=-latency", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "latency") {
+		t.Errorf("Expected 400 naming the unknown field, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// Test 9: Relative time filters
+func TestListRequests_RelativeTimeFilter(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewRequestRepository(db)
+	handler := NewRequestHandler(repo)
+
+	testutil.CreateTestRequest(t, repo, "GET", "/recent", 200, 100)
+	if _, err := repo.Create(&models.APIRequest{
+		Method:         "GET",
+		Path:           "/old",
+		ResponseStatus: 200,
+		CreatedAt:      time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests", handler.ListRequests)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"created_after=-15m", "/recent"},
+		{"created_before=now-15m", "/old"},
+		{"created_after=-2h&created_before=-30m", "/old"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/requests?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.query, w.Code)
+		}
+
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		data := response["data"].([]any)
+		if len(data) != 1 || data[0].(map[string]any)["path"] != tt.expected {
+			t.Errorf("%s: expected only %s, got %v", tt.query, tt.expected, data)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/requests?created_after=2025-01-01&tz=Mars/Olympus", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"tz"`) {
+		t.Errorf("Expected 400 for an unknown time zone, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// Test 10: q= filter expressions, with syntax errors reported as problem details
+func TestListRequests_Expression(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewRequestRepository(db)
+	handler := NewRequestHandler(repo)
+
+	testutil.CreateTestRequest(t, repo, "GET", "/anime/1", 200, 900)
+	testutil.CreateTestRequest(t, repo, "GET", "/anime/2", 200, 100)
+	testutil.CreateTestRequest(t, repo, "GET", "/manga/1", 503, 50)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests", handler.ListRequests)
+
+	tests := []struct {
+		q        string
+		expected int
+	}{
+		{"status >= 500 OR (response_time > 800 AND path ~ '/anime/')", 2},
+		{"created_at >= -15m AND NOT method IN (POST, PUT)", 3},
+		{`path = '/anime/1\' OR \'1\'=\'1'`, 0},
+		{`path = '\'; DROP TABLE api_requests; --'`, 0},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/requests?q="+url.QueryEscape(tt.q), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d %s", tt.q, w.Code, w.Body.String())
+		}
+
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		if data, _ := response["data"].([]any); len(data) != tt.expected {
+			t.Errorf("%s: expected %d requests, got %v", tt.q, tt.expected, data)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/requests?q="+url.QueryEscape("status >= 500 OR"), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "position 17") {
+		t.Errorf("Expected 400 pointing at position 17, got %d %s", w.Code, w.Body.String())
+	}
+
+	all, err := repo.List(repository.RequestFilters{})
+	if err != nil || len(all) != 3 {
+		t.Errorf("Expected the table to still hold 3 requests, got %d (%v)", len(all), err)
+	}
+}
+
+// Test 11: fields picks and orders table and CSV columns, time_format and tz shape timestamps
+func TestRequestTable_Fields(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewRequestRepository(db)
+	handler := NewRequestHandler(repo)
+
+	createdAt := time.Date(2025, 10, 24, 10, 30, 0, 0, time.UTC)
+	id, err := repo.Create(&models.APIRequest{
+		Method:         "GET",
+		Path:           "/anime/1",
+		ResponseStatus: 200,
+		ResponseTimeMs: 150,
+		Timing:         models.RequestTiming{TTFBMs: 120, ConnReused: true},
+		CreatedAt:      createdAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests/table", handler.TableView)
+	router.GET("/api/requests/csv", handler.CSVExport)
+
+	req := httptest.NewRequest("GET", "/api/requests/table?fields=created_at,id,ttfb_ms,conn_reused,parent_id&time_format=unix", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var table struct {
+		Columns []string `json:"columns"`
+		Rows    [][]any  `json:"rows"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &table)
+	if strings.Join(table.Columns, ",") != "created_at,id,ttfb_ms,conn_reused,parent_id" {
+		t.Errorf("Unexpected columns %v", table.Columns)
+	}
+	expected := []any{float64(createdAt.Unix()), float64(id), float64(120), true, nil}
+	if len(table.Rows) != 1 || fmt.Sprint(table.Rows[0]) != fmt.Sprint(expected) {
+		t.Errorf("Expected row %v, got %v", expected, table.Rows)
+	}
+
+	req = httptest.NewRequest("GET", "/api/requests/csv?fields=path,created_at&time_format=datetime&tz=Asia/Tokyo", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if body := w.Body.String(); body != "path,created_at\n/anime/1,2025-10-24 19:30:00\n" {
+		t.Errorf("Unexpected CSV %q", body)
+	}
+
+	for _, query := range []string{"fields=path,latency", "fields=path,path", "time_format=iso"} {
+		req := httptest.NewRequest("GET", "/api/requests/csv?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+// Test 12: CSV exports stream every matching row, gzip compressed when accepted, under a filename naming the filters
+func TestCSVExport_Streaming(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewRequestRepository(db)
+	handler := NewRequestHandler(repo)
+
+	for i := range 1200 {
+		status := 200
+		if i%2 == 0 {
+			status = 503
+		}
+		testutil.CreateTestRequest(t, repo, "GET", fmt.Sprintf("/anime/%d", i), status, int64(i))
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests", handler.ListRequests)
+	router.GET("/api/requests/csv", handler.CSVExport)
+
+	req := httptest.NewRequest("GET", "/api/requests/csv?method=GET&response=5xx&total=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); w.Code != http.StatusOK || len(lines) != 601 {
+		t.Fatalf("Expected a header and 600 rows, got %d with %d lines", w.Code, len(lines))
+	}
+	if w.Header().Get(TotalCountHeader) != "600" {
+		t.Errorf("Expected X-Total-Count 600, got %q", w.Header().Get(TotalCountHeader))
+	}
+	filename := regexp.MustCompile(`^attachment; filename="requests-get-5xx-\d{8}T\d{6}Z\.csv"$`)
+	if disposition := w.Header().Get("Content-Disposition"); !filename.MatchString(disposition) {
+		t.Errorf("Unexpected Content-Disposition %q", disposition)
+	}
+
+	req = httptest.NewRequest("GET", "/api/requests/csv?limit=1100&sort=-response_time", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Expected a gzip response, got %v", w.Header())
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 1101 || !strings.Contains(lines[1], "/anime/1199") {
+		t.Errorf("Expected 1100 rows slowest first, got %d lines starting %q", len(lines), lines[1])
+	}
+
+	// Exports continue from next cursors, previous page cursors are rejected
+	req = httptest.NewRequest("GET", "/api/requests?limit=1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var page struct {
+		Meta map[string]any `json:"meta"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	next := url.QueryEscape(page.Meta["next_cursor"].(string))
+
+	req = httptest.NewRequest("GET", "/api/requests/csv?cursor="+next, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 1200 {
+		t.Errorf("Expected the 1199 rows after the cursor, got %d lines", len(lines))
+	}
+
+	req = httptest.NewRequest("GET", "/api/requests?limit=1&cursor="+next, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &page)
+
+	req = httptest.NewRequest("GET", "/api/requests/csv?cursor="+url.QueryEscape(page.Meta["prev_cursor"].(string)), nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Encoding") != "" || !strings.Contains(w.Body.String(), `"cursor"`) {
+		t.Errorf("Expected a 400 for a prev cursor, got %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+// Test 13: Exports write NDJSON, Excel and Parquet files with the chosen columns
+func TestExport_Formats(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewRequestRepository(db)
+	handler := NewRequestHandler(repo)
+
+	createdAt := time.Date(2025, 10, 24, 10, 30, 0, 0, time.UTC)
+	parentID := int(testutil.CreateTestRequest(t, repo, "GET", "/anime/1", 503, 150))
+	if _, err := repo.Create(&models.APIRequest{
+		Method:          "GET",
+		Path:            `/anime/"2"&<3>`,
+		ResponseStatus:  200,
+		ResponseTimeMs:  250,
+		UpstreamHeaders: map[string]string{"Age": "10"},
+		ParentID:        &parentID,
+		Attempt:         2,
+		CreatedAt:       createdAt,
+	}); err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests/export", handler.Export)
+
+	export := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/requests/export?"+query, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	const fields = "fields=path,parent_id,upstream_headers,created_at&sort=response_time&tz=Europe/Zagreb"
+
+	// NDJSON keeps the column order, rendering times like the table view
+	w := export("format=ndjson&" + fields)
+	if w.Header().Get("Content-Type") != "application/x-ndjson" || w.Header().Get("Content-Encoding") != "gzip" ||
+		!strings.HasSuffix(w.Header().Get("Content-Disposition"), `.ndjson"`) {
+		t.Fatalf("Unexpected NDJSON headers %v", w.Header())
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	expected := fmt.Sprintf(`{"path":"/anime/\"2\"&<3>","parent_id":%d,"upstream_headers":{"Age":"10"},"created_at":"2025-10-24T12:30:00+02:00"}`, parentID)
+	if len(lines) != 2 || !strings.Contains(lines[0], `"parent_id":null`) || lines[1] != expected {
+		t.Errorf("Unexpected NDJSON %q", body)
+	}
+
+	// Excel files hold one sheet with typed cells, and aren't compressed again
+	w = export("format=xlsx&" + fields)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("Unexpected Excel response %d %v", w.Code, w.Header())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open xlsx: %v", err)
+	}
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			content, _ := io.ReadAll(r)
+			sheet = string(content)
+		}
+	}
+	for _, cell := range []string{
+		`<t xml:space="preserve">parent_id</t>`,
+		`<t xml:space="preserve">/anime/&#34;2&#34;&amp;&lt;3&gt;</t>`,
+		fmt.Sprintf(`<c><v>%d</v></c>`, parentID),
+		`<c s="1"><v>45954.520833333336</v></c>`, // 2025-10-24 12:30 in Zagreb
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("Expected %s in the sheet, got %s", cell, sheet)
+		}
+	}
+
+	// Parquet columns are typed, with nulls for missing parents
+	w = export("format=parquet&" + fields)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("Unexpected Parquet response %d %v", w.Code, w.Header())
+	}
+	type parquetRequest struct {
+		Path      string    `parquet:"path"`
+		ParentID  *int64    `parquet:"parent_id,optional"`
+		CreatedAt time.Time `parquet:"created_at,timestamp(microsecond)"`
+	}
+	rows, err := parquet.Read[parquetRequest](bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read parquet: %v", err)
+	}
+	if len(rows) != 2 || rows[0].ParentID != nil || rows[1].ParentID == nil || *rows[1].ParentID != int64(parentID) ||
+		!rows[1].CreatedAt.Equal(createdAt) || rows[1].Path != `/anime/"2"&<3>` {
+		t.Errorf("Unexpected Parquet rows %+v", rows)
+	}
+
+	if w := export("format=xml"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"format"`) {
+		t.Errorf("Expected 400 for an unknown format, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// Test 14: HAR exports list requests oldest first with URLs, timings and recorded headers
+func TestRequestsHAR(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewRequestRepository(db)
+	handler := NewRequestHandler(repo)
+	handler.UpstreamURL = "https://api.jikan.moe/v4/"
+
+	createdAt := time.Date(2025, 10, 24, 10, 30, 0, 0, time.UTC)
+	for _, r := range []*models.APIRequest{
+		{
+			Method: "GET", Path: "/anime?q=naruto&page=2", ResponseStatus: 200, ResponseTimeMs: 260, ResponseBytes: 2048,
+			ContentType: "application/json", UpstreamHeaders: map[string]string{"Cache-Control": "max-age=60", "Age": "3"},
+			Timing:      models.RequestTiming{DNSMs: 10, ConnectMs: 20, TLSMs: 30, TTFBMs: 200, TransferMs: 50},
+			QueueWaitMs: 40, Attempt: 1, CreatedAt: createdAt.Add(time.Minute),
+		},
+		{
+			Method: "GET", Path: "/anime/1", ResponseStatus: 0, ResponseTimeMs: 5, Outcome: models.OutcomeCircuitOpen,
+			Attempt: 1, CreatedAt: createdAt,
+		},
+	} {
+		if _, err := repo.Create(r); err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests/har", handler.HAR)
+
+	req := httptest.NewRequest("GET", "/api/requests/har", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.HasSuffix(w.Header().Get("Content-Disposition"), `.har"`) {
+		t.Fatalf("Unexpected response %d %v", w.Code, w.Header())
+	}
+	var har struct {
+		Log struct {
+			Version string     `json:"version"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &har); err != nil {
+		t.Fatalf("Invalid HAR JSON: %v\n%s", err, w.Body.String())
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("Expected a HAR 1.2 log with 2 entries, got %s", w.Body.String())
+	}
+
+	open, search := har.Log.Entries[0], har.Log.Entries[1]
+	if open.Request.URL != "https://api.jikan.moe/v4/anime/1" || open.Outcome != models.OutcomeCircuitOpen ||
+		open.Timings.Wait != 5 || open.Timings.DNS != -1 || open.Time != 5 {
+		t.Errorf("Unexpected circuit open entry %+v", open)
+	}
+
+	if search.Request.URL != "https://api.jikan.moe/v4/anime?q=naruto&page=2" ||
+		fmt.Sprint(search.Request.QueryString) != "[{page 2} {q naruto}]" {
+		t.Errorf("Unexpected request %+v", search.Request)
+	}
+	expectedTimings := harTimings{Blocked: 40, DNS: 10, Connect: 50, SSL: 30, Wait: 140, Receive: 50}
+	if search.Timings != expectedTimings || search.Time != 290 {
+		t.Errorf("Expected timings %+v over 290ms, got %+v over %dms", expectedTimings, search.Timings, search.Time)
+	}
+	if started, _ := time.Parse(time.RFC3339, search.StartedDateTime); !started.Equal(createdAt.Add(time.Minute - 290*time.Millisecond)) {
+		t.Errorf("Expected the entry to start 290ms before it was logged, got %s", search.StartedDateTime)
+	}
+	if fmt.Sprint(search.Response.Headers) != "[{Content-Type application/json} {Age 3} {Cache-Control max-age=60}]" ||
+		search.Response.Content.Size != 2048 || search.Response.StatusText != "OK" {
+		t.Errorf("Unexpected response %+v", search.Response)
+	}
+
+	// Filters and sort apply as on the other exports
+	req = httptest.NewRequest("GET", "/api/requests/har?outcome=none&sort=-created_at", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	har.Log.Entries = nil
+	if err := json.Unmarshal(w.Body.Bytes(), &har); err != nil || len(har.Log.Entries) != 1 || har.Log.Entries[0].Outcome != "" {
+		t.Errorf("Expected only the traced request, got %s", w.Body.String())
+	}
+}
+
+// Test 15: Exported CSV and NDJSON files import back with their retry links, as a body or an upload, up to the size limit
+func TestImportRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	source := testutil.SetupTestDB(t)
+	defer source.Close()
+	sourceRepo := repository.NewRequestRepository(source)
+
+	createdAt := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	parent := &models.APIRequest{Method: "GET", Path: "/anime/1", ResponseStatus: 404, ResponseTimeMs: 3000, Attempt: 2, CreatedAt: createdAt}
+	if _, err := sourceRepo.Create(parent); err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	parentID := 1
+	for attempt := 1; attempt <= 2; attempt++ {
+		retry := &models.APIRequest{Method: "GET", Path: "/anime/1", ResponseStatus: 503, ResponseTimeMs: 100,
+			ParentID: &parentID, Attempt: attempt, CreatedAt: createdAt.Add(time.Duration(attempt) * time.Second)}
+		if _, err := sourceRepo.Create(retry); err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+	}
+
+	exportRouter := gin.New()
+	exportRouter.GET("/api/requests/export", NewRequestHandler(sourceRepo).Export)
+	export := func(format string) []byte {
+		query := url.Values{"format": {format}, "fields": {strings.Join(requestColumns.names(), ",")}, "sort": {"-created_at"}}
+		w := httptest.NewRecorder()
+		exportRouter.ServeHTTP(w, httptest.NewRequest("GET", "/api/requests/export?"+query.Encode(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Failed to export %s: %d %s", format, w.Code, w.Body.String())
+		}
+		return w.Body.Bytes()
+	}
+
+	maxBytes := int64(DefaultMaxImportBytes)
+	importInto := func(req *http.Request) (*repository.RequestRepository, *repository.ProblemRepository, *httptest.ResponseRecorder) {
+		db := testutil.SetupTestDB(t)
+		t.Cleanup(func() { db.Close() })
+		repo := repository.NewRequestRepository(db)
+		handler := NewRequestHandler(repo)
+		handler.MaxImportBytes = maxBytes
+		router := gin.New()
+		router.POST("/api/requests/import", handler.Import)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return repo, repository.NewProblemRepository(db), w
+	}
+
+	csvBody := export(ExportFormatCSV)
+	var upload bytes.Buffer
+	form := multipart.NewWriter(&upload)
+	part, _ := form.CreateFormFile("file", "requests.csv")
+	part.Write(csvBody)
+	form.Close()
+
+	requests := map[string]*http.Request{
+		"ndjson body": httptest.NewRequest("POST", "/api/requests/import?detect=true", bytes.NewReader(export(ExportFormatNDJSON))),
+		"csv upload":  httptest.NewRequest("POST", "/api/requests/import?detect=true&batch_size=1", &upload),
+	}
+	requests["ndjson body"].Header.Set("Content-Type", "application/x-ndjson")
+	requests["csv upload"].Header.Set("Content-Type", form.FormDataContentType())
+
+	for name, req := range requests {
+		repo, problemRepo, w := importInto(req)
+		var result struct {
+			Imported int `json:"imported"`
+			Failed   int `json:"failed"`
+			Problems int `json:"problems"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("%s: unexpected response %d %s", name, w.Code, w.Body.String())
+		}
+		// The slow 404 is both not_found and slow_response
+		if result.Imported != 3 || result.Failed != 0 || result.Problems != 4 {
+			t.Errorf("%s: expected 3 imported with 4 problems, got %+v", name, result)
+		}
+
+		imported, err := repo.List(repository.RequestFilters{SortBy: "created_at"})
+		if err != nil || len(imported) != 3 {
+			t.Fatalf("%s: expected 3 requests, got %d (%v)", name, len(imported), err)
+		}
+		for _, retry := range imported[1:] {
+			if retry.ParentID == nil || *retry.ParentID != imported[0].ID || !retry.CreatedAt.After(imported[0].CreatedAt) {
+				t.Errorf("%s: expected attempt %d to belong to request %d, got %+v", name, retry.Attempt, imported[0].ID, retry)
+			}
+		}
+		problems, err := problemRepo.List(repository.ProblemFilters{})
+		if err != nil || len(problems) != 4 {
+			t.Errorf("%s: expected 4 problems, got %+v (%v)", name, problems, err)
+		}
+	}
+
+	// Without a format, name or Content-Type that tells, nothing is read
+	_, _, w := importInto(httptest.NewRequest("POST", "/api/requests/import", bytes.NewReader(csvBody)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"format"`) {
+		t.Errorf("Expected a missing format to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	_, _, w = importInto(httptest.NewRequest("POST", "/api/requests/import?format=csv", strings.NewReader("a,b\n1,2\n")))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unknown column") {
+		t.Errorf("Expected an unknown column to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Bodies over the limit are cut off, whether sent directly or uploaded
+	maxBytes = int64(len(csvBody)) - 1
+	for _, contentType := range []string{"text/csv", "multipart/form-data"} {
+		var body bytes.Buffer
+		req := httptest.NewRequest("POST", "/api/requests/import", &body)
+		req.Header.Set("Content-Type", contentType)
+		if contentType == "multipart/form-data" {
+			form := multipart.NewWriter(&body)
+			part, _ := form.CreateFormFile("file", "requests.csv")
+			part.Write(csvBody)
+			form.Close()
+			req.Header.Set("Content-Type", form.FormDataContentType())
+		} else {
+			body.Write(csvBody)
+		}
+		req.ContentLength = int64(body.Len())
+		if _, _, w := importInto(req); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected a body over the limit to be rejected with 413, got %d %s", contentType, w.Code, w.Body.String())
+		}
+	}
+}
+
+// Test 16: Streamed requests are dated when they complete, so HAR entries start when their call did
+func TestRequestsHARStreamed(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	repo := repository.NewRequestRepository(db)
+
+	startedAt := time.Date(2025, 10, 24, 10, 30, 0, 0, time.UTC)
+	placeholder := &models.APIRequest{Method: "GET", Path: "/anime/1", Outcome: models.OutcomeStreaming, Attempt: 1, CreatedAt: startedAt}
+	id, err := repo.Create(placeholder)
+	if err != nil {
+		t.Fatalf("Failed to create placeholder: %v", err)
+	}
+	completed := &models.APIRequest{
+		ID: int(id), Method: "GET", Path: "/anime/1", ResponseStatus: 200, ResponseTimeMs: 3000,
+		Timing:  models.RequestTiming{TTFBMs: 200, TransferMs: 2800},
+		Attempt: 1, CreatedAt: startedAt.Add(3 * time.Second),
+	}
+	if err := repo.Update(completed); err != nil {
+		t.Fatalf("Failed to update placeholder: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/requests/har", NewRequestHandler(repo).HAR)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/requests/har", nil))
+
+	var har struct {
+		Log struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &har); err != nil || len(har.Log.Entries) != 1 {
+		t.Fatalf("Expected a single entry, got %d %s", w.Code, w.Body.String())
+	}
+	entry := har.Log.Entries[0]
+	if started, _ := time.Parse(time.RFC3339, entry.StartedDateTime); !started.Equal(startedAt) || entry.Time != 3000 {
+		t.Errorf("Expected the entry to start at %s and last 3000ms, got %s over %dms", startedAt.Format(time.RFC3339), entry.StartedDateTime, entry.Time)
+	}
+}
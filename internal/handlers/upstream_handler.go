@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"treblle_project/internal/jikan"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpstreamHandler struct {
+	reporter jikan.BreakerReporter
+}
+
+func NewUpstreamHandler(reporter jikan.BreakerReporter) *UpstreamHandler {
+	return &UpstreamHandler{reporter: reporter}
+}
+
+// Status godoc
+// @Summary      Upstream circuit breaker status
+// @Description  Get the state (closed, open, half_open) of every upstream circuit breaker, with its failure counters and the time left until the next probe
+// @Tags         upstreams, status
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "Circuit breaker states"
+// @Router       /upstreams/status [get]
+func (h *UpstreamHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": h.reporter.BreakerStatus(),
+	})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"treblle_project/internal/jikan"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockBreakerReporter returns a fixed breaker status list
+type mockBreakerReporter struct {
+	statuses []jikan.BreakerStatus
+}
+
+func (m *mockBreakerReporter) BreakerStatus() []jikan.BreakerStatus {
+	return m.statuses
+}
+
+// Test 1: Status lists every breaker with its state
+func TestUpstreamStatus_ReturnsBreakers(t *testing.T) {
+	handler := NewUpstreamHandler(&mockBreakerReporter{
+		statuses: []jikan.BreakerStatus{
+			{Key: "api.jikan.moe", State: jikan.BreakerOpen, ConsecutiveFailures: 5, RetryAfterMs: 12000},
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/upstreams/status", handler.Status)
+
+	req := httptest.NewRequest("GET", "/api/upstreams/status", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Data []jikan.BreakerStatus `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].State != jikan.BreakerOpen {
+		t.Errorf("Expected one open breaker, got %+v", response.Data)
+	}
+}
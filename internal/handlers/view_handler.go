@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"treblle_project/internal/models"
+	"treblle_project/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// viewParamsKey is the context key of the parameters merged by ViewHandler.Apply
+const viewParamsKey = "view_params"
+
+// viewNamePattern matches view names, which appear unescaped in ?view= and URLs
+var viewNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// viewResource describes what a view of one resource may contain
+type viewResource struct {
+	params []string           // Query parameters a view can set, besides sort and fields
+	parse  func(*queryParser) // Reads every parameter of the resource's table and CSV views
+}
+
+// viewResources are the resources views can target
+var viewResources = map[string]viewResource{
+	models.ViewResourceRequests: {
+		params: []string{"method", "response", "min_time", "max_time", "created_after", "created_before", "tz",
+			"min_bytes", "max_bytes", "content_type", "header", "throttle", "outcome", "cache_status", "coalesced",
+			"upstream_call_id", "parent_id", "exclude_attempts", "search", "path_regex", "path_glob", "q", "limit", "total",
+			"time_format"},
+		parse: func(p *queryParser) {
+			p.requestFilters()
+			tableFormatOf(p, requestColumns)
+		},
+	},
+	models.ViewResourceProblems: {
+		params: []string{"method", "response", "min_time", "max_time", "created_after", "created_before", "tz",
+			"search", "problem_type", "rule_version", "path_regex", "path_glob", "q", "limit", "total", "time_format",
+			"group_by"},
+		parse: func(p *queryParser) {
+			if p.problemFilters().GroupBy == repository.GroupByRequest {
+				tableFormatOf(p, problemGroupColumns)
+				return
+			}
+			tableFormatOf(p, problemColumns)
+		},
+	},
+}
+
+type ViewHandler struct {
+	repo *repository.ViewRepository
+}
+
+func NewViewHandler(repo *repository.ViewRepository) *ViewHandler {
+	return &ViewHandler{repo: repo}
+}
+
+// ListViews godoc
+// @Summary      List saved views
+// @Description  Get every saved view, optionally only those of one resource
+// @Tags         views, list
+// @Produce      json
+// @Param        resource  query    string  false  "Only views of this resource (requests, problems)"
+// @Success      200  {object}  map[string]interface{}  "List of views"
+// @Failure      400  {object}  map[string]interface{}  "Invalid query parameters (application/problem+json)"
+// @Failure      500  {object}  map[string]string       "Internal server error"
+// @Router       /views [get]
+func (h *ViewHandler) ListViews(c *gin.Context) {
+	resource := c.Query("resource")
+	if _, ok := viewResources[resource]; resource != "" && !ok {
+		writeParamError(c, &ParamError{Params: []InvalidParam{{Name: "resource", Reason: fmt.Sprintf("must be requests or problems, got %q", resource)}}})
+		return
+	}
+
+	views, err := h.repo.List(resource)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": views})
+}
+
+// GetView godoc
+// @Summary      Get a saved view
+// @Description  Get one saved view by name
+// @Tags         views
+// @Produce      json
+// @Param        name  path      string  true  "View name"
+// @Success      200  {object}  models.View        "The view"
+// @Failure      404  {object}  map[string]string  "View not found"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /views/{name} [get]
+func (h *ViewHandler) GetView(c *gin.Context) {
+	view, err := h.repo.GetByName(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if view == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// CreateView godoc
+// @Summary      Save a view
+// @Description  Save a named combination of filters, sort and columns, applied with ?view=name on the list, table and CSV endpoints of its resource
+// @Tags         views
+// @Accept       json
+// @Produce      json
+// @Param        view  body      models.View  true  "View to save (id and timestamps are ignored)"
+// @Success      201  {object}  models.View             "The saved view"
+// @Failure      400  {object}  map[string]interface{}  "Invalid view (application/problem+json)"
+// @Failure      409  {object}  map[string]string       "A view with this name exists"
+// @Failure      500  {object}  map[string]string       "Internal server error"
+// @Router       /views [post]
+func (h *ViewHandler) CreateView(c *gin.Context) {
+	view, ok := bindView(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Create(view); err != nil {
+		viewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": view})
+}
+
+// UpdateView godoc
+// @Summary      Replace a saved view
+// @Description  Replace the filters, sort and columns of a saved view, or rename it
+// @Tags         views
+// @Accept       json
+// @Produce      json
+// @Param        name  path      string       true  "View name"
+// @Param        view  body      models.View  true  "New view (id and timestamps are ignored)"
+// @Success      200  {object}  models.View             "The updated view"
+// @Failure      400  {object}  map[string]interface{}  "Invalid view (application/problem+json)"
+// @Failure      404  {object}  map[string]string       "View not found"
+// @Failure      409  {object}  map[string]string       "A view with the new name exists"
+// @Failure      500  {object}  map[string]string       "Internal server error"
+// @Router       /views/{name} [put]
+func (h *ViewHandler) UpdateView(c *gin.Context) {
+	view, ok := bindView(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Update(c.Param("name"), view); err != nil {
+		viewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// DeleteView godoc
+// @Summary      Delete a saved view
+// @Description  Delete a saved view by name
+// @Tags         views
+// @Param        name  path  string  true  "View name"
+// @Success      204  "View deleted"
+// @Failure      404  {object}  map[string]string  "View not found"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /views/{name} [delete]
+func (h *ViewHandler) DeleteView(c *gin.Context) {
+	if err := h.repo.Delete(c.Param("name")); err != nil {
+		viewError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Apply returns middleware applying the view named by the view query
+// parameter to the list endpoints of resource. Parameters of the request
+// take precedence over those of the view, so ?view=slow-anime&limit=10
+// overrides the limit, ?view=slow-anime&fields=id,path its columns and
+// ?view=slow-anime&q= drops its expression.
+func (h *ViewHandler) Apply(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Query("view")
+		if name == "" {
+			c.Next()
+			return
+		}
+
+		view, err := h.repo.GetByName(name)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if view == nil || view.Resource != resource {
+			reason := fmt.Sprintf("no %s view named %q", resource, name)
+			writeParamError(c, &ParamError{Params: []InvalidParam{{Name: "view", Reason: reason}}})
+			c.Abort()
+			return
+		}
+
+		params := viewParams(view)
+		for key, values := range c.Request.URL.Query() {
+			if key != "view" {
+				params[key] = values
+			}
+		}
+		c.Set(viewParamsKey, params)
+		c.Next()
+	}
+}
+
+// listParams returns the list query parameters of c, merged with those of
+// the view applied by ViewHandler.Apply
+func listParams(c *gin.Context) url.Values {
+	if params, ok := c.Get(viewParamsKey); ok {
+		return params.(url.Values)
+	}
+	return c.Request.URL.Query()
+}
+
+// viewParams returns the query parameters a view stands for
+func viewParams(view *models.View) url.Values {
+	params := url.Values{}
+	for key, value := range view.Filters {
+		params.Set(key, value)
+	}
+	if view.Sort != "" {
+		params.Set("sort", view.Sort)
+	}
+	if len(view.Columns) > 0 {
+		params.Set("fields", strings.Join(view.Columns, ","))
+	}
+	return params
+}
+
+// bindView reads and validates the view in the request body, responding
+// with the problems when it isn't valid
+func bindView(c *gin.Context) (*models.View, bool) {
+	var view models.View
+	if err := c.ShouldBindJSON(&view); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid view: " + err.Error()})
+		return nil, false
+	}
+
+	if invalid := validateView(&view); len(invalid) > 0 {
+		writeInvalidParams(c, invalid, "view field")
+		return nil, false
+	}
+	return &view, true
+}
+
+// validateView checks every field of view, running its filters, sort and
+// columns through the parser of its resource
+func validateView(view *models.View) []InvalidParam {
+	var invalid []InvalidParam
+	if !viewNamePattern.MatchString(view.Name) {
+		invalid = append(invalid, InvalidParam{Name: "name", Reason: fmt.Sprintf("must be 1 to 64 lowercase letters, digits, '-' or '_', got %q", view.Name)})
+	}
+
+	resource, ok := viewResources[view.Resource]
+	if !ok {
+		return append(invalid, InvalidParam{Name: "resource", Reason: fmt.Sprintf("must be requests or problems, got %q", view.Resource)})
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(view.Filters)) {
+		if !slices.Contains(resource.params, key) {
+			invalid = append(invalid, InvalidParam{Name: "filters." + key, Reason: "is not a filter of " + view.Resource})
+		}
+	}
+
+	p := newQueryParser(viewParams(view))
+	resource.parse(p)
+	for _, param := range p.invalid {
+		switch param.Name {
+		case "sort":
+		case "fields":
+			param.Name = "columns"
+		default:
+			param.Name = "filters." + param.Name
+		}
+		invalid = append(invalid, param)
+	}
+	return invalid
+}
+
+// viewError writes the response for a failed view change
+func viewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrViewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+	case errors.Is(err, repository.ErrViewExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A view with this name exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
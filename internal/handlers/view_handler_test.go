@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"treblle_project/internal/database"
+	"treblle_project/internal/models"
+	"treblle_project/internal/repository"
+	"treblle_project/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupViewRouter routes the view endpoints and the problem endpoints with views applied
+func setupViewRouter(db *database.DB) *gin.Engine {
+	viewHandler := NewViewHandler(repository.NewViewRepository(db))
+	problemHandler := NewProblemHandler(repository.NewProblemRepository(db))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/views", viewHandler.ListViews)
+	router.POST("/api/views", viewHandler.CreateView)
+	router.GET("/api/views/:name", viewHandler.GetView)
+	router.PUT("/api/views/:name", viewHandler.UpdateView)
+	router.DELETE("/api/views/:name", viewHandler.DeleteView)
+
+	problemView := viewHandler.Apply(models.ViewResourceProblems)
+	router.GET("/api/problems", problemView, problemHandler.ListProblems)
+	router.GET("/api/problems/table", problemView, problemHandler.TableView)
+	router.GET("/api/problems/csv", problemView, problemHandler.CSVExport)
+	return router
+}
+
+func serve(router *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test 1: Views can be created, read, listed, replaced and deleted
+func TestViews_CRUD(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	router := setupViewRouter(db)
+
+	body := `{"name": "slow-anime", "resource": "problems", "filters": {"problem_type": "slow_response", "path_glob": "/anime/*"},
+		"sort": "-response_time", "columns": ["path", "response_time"]}`
+	if w := serve(router, "POST", "/api/views", body); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, "POST", "/api/views", body); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a duplicate name, got %d", w.Code)
+	}
+
+	w := serve(router, "GET", "/api/views/slow-anime", "")
+	var response struct {
+		Data models.View `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || response.Data.Sort != "-response_time" || response.Data.Filters["problem_type"] != "slow_response" {
+		t.Errorf("Unexpected view %d %s", w.Code, w.Body.String())
+	}
+
+	if w := serve(router, "GET", "/api/views?resource=requests", ""); !strings.Contains(w.Body.String(), `"data":[]`) {
+		t.Errorf("Expected no request views, got %s", w.Body.String())
+	}
+
+	if w := serve(router, "PUT", "/api/views/slow-anime", `{"name": "slow", "resource": "problems"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, "GET", "/api/views/slow-anime", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after renaming, got %d", w.Code)
+	}
+	if w := serve(router, "PUT", "/api/views/missing", `{"name": "missing", "resource": "problems"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	if w := serve(router, "DELETE", "/api/views/slow", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+	if w := serve(router, "DELETE", "/api/views/slow", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+// Test 2: Invalid views are rejected with every invalid field
+func TestViews_Validation(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	router := setupViewRouter(db)
+
+	tests := []struct {
+		body    string
+		invalid []string
+	}{
+		{`{"name": "Slow Anime", "resource": "problems"}`, []string{"name"}},
+		{`{"name": "x", "resource": "users"}`, []string{"resource"}},
+		{`{"name": "x", "resource": "problems", "filters": {"min_bytes": "10", "cursor": "abc"}}`, []string{"filters.cursor", "filters.min_bytes"}},
+		{`{"name": "x", "resource": "problems", "filters": {"response": "6xx", "q": "status >"}, "sort": "latency"}`, []string{"filters.response", "filters.q", "sort"}},
+		{`{"name": "x", "resource": "requests", "columns": ["path", "problem_type", "path"]}`, []string{"columns", "columns"}},
+	}
+
+	for _, tt := range tests {
+		w := serve(router, "POST", "/api/views", tt.body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", tt.body, w.Code)
+			continue
+		}
+
+		var problem struct {
+			InvalidParams []InvalidParam `json:"invalid_params"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &problem)
+		var names []string
+		for _, p := range problem.InvalidParams {
+			names = append(names, p.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.invalid, ",") {
+			t.Errorf("%s: expected %v to be invalid, got %v", tt.body, tt.invalid, problem.InvalidParams)
+		}
+	}
+
+	if w := serve(router, "POST", "/api/views", `{"name": `); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for malformed JSON, got %d", w.Code)
+	}
+}
+
+// Test 3: ?view= applies a view's filters, sort and columns, and query parameters override them
+func TestViews_Apply(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	router := setupViewRouter(db)
+
+	requestRepo := repository.NewRequestRepository(db)
+	problemRepo := repository.NewProblemRepository(db)
+	for _, r := range []struct {
+		path string
+		time int64
+		kind string
+	}{
+		{"/anime/1", 900, "slow_response"},
+		{"/anime/2", 1500, "slow_response"},
+		{"/manga/1", 2000, "slow_response"},
+		{"/anime/3", 100, "not_found"},
+	} {
+		id := testutil.CreateTestRequest(t, requestRepo, "GET", r.path, 200, r.time)
+		testutil.CreateTestProblem(t, problemRepo, int(id), r.kind, "Problem", 400)
+	}
+
+	body := `{"name": "slow-anime", "resource": "problems", "filters": {"problem_type": "slow_response", "path_glob": "/anime/*"},
+		"sort": "-response_time", "columns": ["path", "response_time"]}`
+	if w := serve(router, "POST", "/api/views", body); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d %s", w.Code, w.Body.String())
+	}
+
+	w := serve(router, "GET", "/api/problems/table?view=slow-anime", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d %s", w.Code, w.Body.String())
+	}
+	var table struct {
+		Columns []string `json:"columns"`
+		Rows    [][]any  `json:"rows"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &table)
+	if strings.Join(table.Columns, ",") != "path,response_time" {
+		t.Errorf("Expected the view's columns, got %v", table.Columns)
+	}
+	if len(table.Rows) != 2 || table.Rows[0][0] != "/anime/2" || table.Rows[1][0] != "/anime/1" {
+		t.Errorf("Expected /anime/2 then /anime/1, got %v", table.Rows)
+	}
+
+	// Query parameters override the view, an empty one drops its setting
+	w = serve(router, "GET", "/api/problems/table?view=slow-anime&sort=path&path_glob=", "")
+	json.Unmarshal(w.Body.Bytes(), &table)
+	if len(table.Rows) != 3 || table.Rows[0][0] != "/anime/1" || table.Rows[2][0] != "/manga/1" {
+		t.Errorf("Expected every slow problem by path, got %v", table.Rows)
+	}
+
+	w = serve(router, "GET", "/api/problems/csv?view=slow-anime", "")
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 3 || lines[0] != "path,response_time" || lines[1] != "/anime/2,1500" {
+		t.Errorf("Unexpected CSV %q", w.Body.String())
+	}
+
+	w = serve(router, "GET", "/api/problems?view=slow-anime&limit=1", "")
+	var list struct {
+		Data []models.Problem `json:"data"`
+		Meta map[string]any   `json:"meta"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Data) != 1 || list.Data[0].Path != "/anime/2" || list.Meta["next_cursor"] == "" {
+		t.Fatalf("Unexpected first page %s", w.Body.String())
+	}
+
+	// Cursors of a view's page work with the view applied
+	w = serve(router, "GET", "/api/problems?view=slow-anime&limit=1&cursor="+list.Meta["next_cursor"].(string), "")
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || len(list.Data) != 1 || list.Data[0].Path != "/anime/1" {
+		t.Errorf("Unexpected second page %d %s", w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{"/api/problems?view=missing", "/api/problems/table?view=SLOW"} {
+		w := serve(router, "GET", target, "")
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"view"`) {
+			t.Errorf("%s: expected 400 for an unknown view, got %d %s", target, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Package importer loads logged requests from JSONL and CSV files, such as
+// the ones written by the request exports
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"treblle_project/internal/detector"
+	"treblle_project/internal/models"
+	"treblle_project/internal/repository"
+)
+
+// Formats of an import file
+const (
+	FormatJSONL = "jsonl" // One JSON object per line, as written by the NDJSON export or listed by the API
+	FormatCSV   = "csv"   // A header line of column names, as written by the CSV export
+)
+
+// DefaultBatchSize is how many rows an import inserts per transaction when
+// Options.BatchSize isn't set
+const DefaultBatchSize = 500
+
+// MaxReportedErrors is how many line errors a Result lists, later ones are
+// only counted
+const MaxReportedErrors = 100
+
+// ErrInvalidFile is returned, wrapped, when a file can't be imported at all,
+// such as a CSV header naming an unknown column
+var ErrInvalidFile = errors.New("invalid import file")
+
+// Options configure an import
+type Options struct {
+	Format    string            // FormatJSONL or FormatCSV
+	BatchSize int               // Rows per transaction, DefaultBatchSize when 0
+	Location  *time.Location    // Zone of created_at values without an offset, local time when nil
+	Detector  detector.Detector // Runs problem detection on imported rows when set
+}
+
+// LineError is a line of the file that was not imported
+type LineError struct {
+	Line  int    `json:"line" example:"12"`                       // Line number, from 1, the header line of a CSV file included
+	Error string `json:"error" example:"path: must start with /"` // Why the line was rejected
+}
+
+// Result sums up an import
+type Result struct {
+	Imported        int         `json:"imported" example:"998"`           // Requests inserted
+	Failed          int         `json:"failed" example:"2"`               // Lines rejected
+	Problems        int         `json:"problems" example:"31"`            // Problems detected on the inserted requests
+	Errors          []LineError `json:"errors"`                           // The first MaxReportedErrors rejected lines
+	ErrorsTruncated bool        `json:"errors_truncated" example:"false"` // More lines were rejected than errors lists
+}
+
+// Importer inserts the requests of import files
+type Importer struct {
+	requests *repository.RequestRepository
+}
+
+func NewImporter(requests *repository.RequestRepository) *Importer {
+	return &Importer{requests: requests}
+}
+
+// Import reads the requests of r and inserts the valid ones in batches, one
+// transaction each. Invalid lines are skipped and listed in the result. An
+// error stops the import, the batches inserted before it are kept and
+// counted in the result.
+func (i *Importer) Import(r io.Reader, opts Options) (*Result, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+
+	run := &importRun{
+		requests: i.requests,
+		opts:     opts,
+		result:   &Result{Errors: []LineError{}},
+		ids:      map[int]int{},
+		pending:  map[int]*models.APIRequest{},
+		seen:     map[int]bool{},
+		orphans:  map[int][]record{},
+	}
+
+	var err error
+	switch opts.Format {
+	case FormatJSONL:
+		err = readJSONL(r, opts.Location, run.add)
+	case FormatCSV:
+		err = readCSV(r, opts.Location, run.add)
+	default:
+		err = fmt.Errorf("%w: unknown format %q, expected %s or %s", ErrInvalidFile, opts.Format, FormatJSONL, FormatCSV)
+	}
+	if err == nil {
+		err = run.flush()
+	}
+	if err != nil {
+		return run.result, err
+	}
+
+	// Rows whose parent never showed up
+	for _, orphans := range run.orphans {
+		for _, rec := range orphans {
+			run.reject(rec.line, fmt.Errorf("parent_id: no row with id %d was imported", *rec.parentID))
+		}
+	}
+	slices.SortFunc(run.result.Errors, func(a, b LineError) int { return a.Line - b.Line })
+	return run.result, nil
+}
+
+// record is a request read from a line of an import file
+type record struct {
+	line     int
+	req      *models.APIRequest
+	id       int  // id in the file, 0 when not given
+	parentID *int // parent_id in the file, the id of another row of the file
+}
+
+// importRun is the state of one import. Rows keep their parent: the parent
+// ids of the file are mapped to the ids the parents get on insert, and rows
+// whose parent comes later in the file wait for it.
+type importRun struct {
+	requests *repository.RequestRepository
+	opts     Options
+	result   *Result
+	batch    []repository.BatchItem
+	ids      map[int]int                // id in the file -> id on insert, of inserted rows
+	pending  map[int]*models.APIRequest // id in the file -> row of the current batch
+	seen     map[int]bool               // ids in the file of accepted rows
+	orphans  map[int][]record           // Rows waiting for the row with this id in the file
+}
+
+// add validates and queues a record read from the file, or rejects its
+// line with err when it couldn't be read
+func (run *importRun) add(rec record, err error) error {
+	if err == nil {
+		err = validate(rec.req)
+	}
+	if err == nil && rec.id != 0 && run.seen[rec.id] {
+		err = fmt.Errorf("id: duplicate id %d", rec.id)
+	}
+	if err != nil {
+		run.reject(rec.line, err)
+		return nil
+	}
+	if rec.id != 0 {
+		run.seen[rec.id] = true
+	}
+	return run.queue(rec)
+}
+
+// queue adds rec to the batch once its parent is inserted, then the rows
+// waiting for rec
+func (run *importRun) queue(rec record) error {
+	if rec.parentID != nil {
+		if _, ok := run.pending[*rec.parentID]; ok {
+			if err := run.flush(); err != nil {
+				return err
+			}
+		}
+		parentID, ok := run.ids[*rec.parentID]
+		if !ok {
+			run.orphans[*rec.parentID] = append(run.orphans[*rec.parentID], rec)
+			return nil
+		}
+		rec.req.ParentID = &parentID
+	}
+
+	item := repository.BatchItem{Request: rec.req}
+	if run.opts.Detector != nil {
+		for _, problem := range run.opts.Detector.Detect(rec.req) {
+			problem.CreatedAt = rec.req.CreatedAt // Detected when the request was logged
+			item.Problems = append(item.Problems, problem)
+		}
+	}
+	run.batch = append(run.batch, item)
+	if rec.id != 0 {
+		run.pending[rec.id] = rec.req
+	}
+	if len(run.batch) >= run.opts.BatchSize {
+		if err := run.flush(); err != nil {
+			return err
+		}
+	}
+
+	if rec.id == 0 {
+		return nil
+	}
+	children := run.orphans[rec.id]
+	delete(run.orphans, rec.id)
+	for _, child := range children {
+		if err := run.queue(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush inserts the batch
+func (run *importRun) flush() error {
+	if len(run.batch) == 0 {
+		return nil
+	}
+	if err := run.requests.CreateBatch(run.batch); err != nil {
+		return fmt.Errorf("failed to import batch: %w", err)
+	}
+
+	for _, item := range run.batch {
+		run.result.Problems += len(item.Problems)
+	}
+	run.result.Imported += len(run.batch)
+	for fileID, req := range run.pending {
+		run.ids[fileID] = req.ID
+	}
+	run.batch = run.batch[:0]
+	clear(run.pending)
+	return nil
+}
+
+// reject records that line was not imported
+func (run *importRun) reject(line int, err error) {
+	run.result.Failed++
+	if len(run.result.Errors) == MaxReportedErrors {
+		run.result.ErrorsTruncated = true
+		return
+	}
+	run.result.Errors = append(run.result.Errors, LineError{Line: line, Error: err.Error()})
+}
+
+// FormatOf guesses the format of a file from its name or media type, empty
+// when neither tells
+func FormatOf(name, contentType string) string {
+	switch {
+	case strings.HasSuffix(name, ".csv"), strings.HasPrefix(contentType, "text/csv"):
+		return FormatCSV
+	case strings.HasSuffix(name, ".jsonl"), strings.HasSuffix(name, ".ndjson"),
+		strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+		return FormatJSONL
+	}
+	return ""
+}
@@ -0,0 +1,213 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"treblle_project/internal/detector"
+	"treblle_project/internal/models"
+	"treblle_project/internal/repository"
+	"treblle_project/internal/testutil"
+)
+
+func setupImporter(t *testing.T) (*Importer, *repository.RequestRepository, *repository.ProblemRepository) {
+	db := testutil.SetupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+	requests := repository.NewRequestRepository(db)
+	return NewImporter(requests), requests, repository.NewProblemRepository(db)
+}
+
+func listImported(t *testing.T, repo *repository.RequestRepository) []models.APIRequest {
+	requests, err := repo.List(repository.RequestFilters{SortBy: "created_at", Limit: 1000})
+	if err != nil {
+		t.Fatalf("Failed to list requests: %v", err)
+	}
+	return requests
+}
+
+// Test 1: JSONL lines in the NDJSON export shape and the API shape are imported, invalid lines reported
+func TestImport_JSONL(t *testing.T) {
+	imp, repo, _ := setupImporter(t)
+
+	input := strings.Join([]string{
+		`{"method":"GET","response":200,"path":"/anime/1","response_time":120,"response_bytes":2048,"content_type":"application/json","upstream_headers":{"Age":"5"},"dns_ms":3,"conn_reused":false,"created_at":"2026-10-01T10:00:00Z"}`,
+		`{"id":7,"method":"get","path":"/anime/2","response":404,"response_time":80,"timing":{"ttfb_ms":70,"conn_reused":true},"cache_status":"MISS","attempt":1,"created_at":"2026-10-01T10:01:00Z"}`,
+		``,
+		`not json`,
+		`{"method":"GET","path":"/anime/3","response":200,"response_time":10}`,
+		`{"method":"GET","path":"/anime/4","response":700,"response_time":10,"created_at":"2026-10-01T10:02:00Z"}`,
+		`{"method":"GET","path":"/anime/5","response":200,"response_time":-1,"created_at":"2026-10-01T10:02:00Z"}`,
+		`{"method":"GET","path":"/anime/6","response":200,"response_time":10,"created_at":"2026-10-01T10:02:00Z","body":"x"}`,
+		`{"method":"GET","path":"anime/7","response":200,"response_time":10,"created_at":"2026-10-01T10:02:00Z"}`,
+	}, "\n")
+
+	result, err := imp.Import(strings.NewReader(input), Options{Format: FormatJSONL})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Imported != 2 || result.Failed != 6 {
+		t.Errorf("Expected 2 imported and 6 failed, got %+v", result)
+	}
+
+	expectedErrors := []LineError{
+		{4, "must be a JSON object"},
+		{5, "created_at: is required"},
+		{6, "response: must be 0, for no response, or between 100 and 599"},
+		{7, "response_time: must not be negative"},
+		{8, `unknown field "body"`},
+		{9, "path: must start with /"},
+	}
+	if len(result.Errors) != len(expectedErrors) {
+		t.Fatalf("Expected %d errors, got %+v", len(expectedErrors), result.Errors)
+	}
+	for i, expected := range expectedErrors {
+		got := result.Errors[i]
+		if got.Line != expected.Line || !strings.HasPrefix(got.Error, expected.Error) {
+			t.Errorf("Expected line %d to fail with %q, got %+v", expected.Line, expected.Error, got)
+		}
+	}
+
+	requests := listImported(t, repo)
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	first, second := requests[0], requests[1]
+	if first.ResponseBytes != 2048 || first.UpstreamHeaders["Age"] != "5" || first.Timing.DNSMs != 3 || !first.CreatedAt.Equal(time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected first request %+v", first)
+	}
+	if second.Method != "GET" || second.ResponseStatus != 404 || second.Timing.TTFBMs != 70 || !second.Timing.ConnReused || second.CacheStatus != "MISS" || second.ID == 7 {
+		t.Errorf("Unexpected second request %+v", second)
+	}
+}
+
+// Test 2: CSV files with export headers are imported, in any time format, and bad headers rejected
+func TestImport_CSV(t *testing.T) {
+	imp, repo, _ := setupImporter(t)
+	loc, _ := time.LoadLocation("Europe/Zagreb")
+
+	input := `method,response,path,response_time,response_bytes,content_type,upstream_headers,created_at
+GET,200,/anime/1,120,2048,application/json,"{""Cache-Control"":""max-age=60""}",2026-10-01T10:00:00Z
+GET,500,/anime/2,900,0,,{},2026-10-01 12:01:00
+GET,200,/anime/3,50,0,,,1790848920
+GET,200,/anime/4,50,0,,,1790848920000
+GET,abc,/anime/5,50,0,,,2026-10-01T10:00:00Z
+GET,200,/anime/6
+GET,200,/anime/7,50,0,,,yesterday
+`
+	result, err := imp.Import(strings.NewReader(input), Options{Format: FormatCSV, Location: loc})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Imported != 4 || result.Failed != 3 {
+		t.Errorf("Expected 4 imported and 3 failed, got %+v", result)
+	}
+	if lines := fmt.Sprint(result.Errors[0].Line, result.Errors[1].Line, result.Errors[2].Line); lines != "6 7 8" {
+		t.Errorf("Expected lines 6, 7 and 8 to fail, got %+v", result.Errors)
+	}
+	if !strings.HasPrefix(result.Errors[0].Error, "response: must be an integer") {
+		t.Errorf("Expected the status to be rejected, got %q", result.Errors[0].Error)
+	}
+
+	requests := listImported(t, repo)
+	if len(requests) != 4 {
+		t.Fatalf("Expected 4 requests, got %d", len(requests))
+	}
+	if requests[0].UpstreamHeaders["Cache-Control"] != "max-age=60" {
+		t.Errorf("Expected the upstream headers to be read, got %v", requests[0].UpstreamHeaders)
+	}
+	// 12:01 in Zagreb is 10:01 UTC, the epochs are 10:02 UTC
+	expected := []time.Time{
+		time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 1, 10, 1, 0, 0, time.UTC),
+		time.Date(2026, 10, 1, 10, 2, 0, 0, time.UTC),
+		time.Date(2026, 10, 1, 10, 2, 0, 0, time.UTC),
+	}
+	for i, req := range requests {
+		if !req.CreatedAt.Equal(expected[i]) {
+			t.Errorf("Expected %s to be created at %s, got %s", req.Path, expected[i], req.CreatedAt)
+		}
+	}
+
+	for _, header := range []string{"method,path,response,response_time,created_at,body", "method,path,response,created_at", "method,method,path,response,response_time,created_at"} {
+		_, err := imp.Import(strings.NewReader(header+"\n"), Options{Format: FormatCSV})
+		if !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("Expected %q to be rejected, got %v", header, err)
+		}
+	}
+}
+
+// Test 3: Retry attempts keep their parent, wherever it is in the file, across batches
+func TestImport_Parents(t *testing.T) {
+	imp, repo, _ := setupImporter(t)
+
+	input := `id,parent_id,attempt,method,response,path,response_time,created_at
+12,10,2,GET,200,/anime/1,100,2026-10-01T10:00:03Z
+11,10,1,GET,503,/anime/1,100,2026-10-01T10:00:02Z
+10,,2,GET,200,/anime/1,300,2026-10-01T10:00:01Z
+20,,1,GET,200,/anime/2,100,2026-10-01T10:00:04Z
+21,20,1,GET,200,/anime/2,100,2026-10-01T10:00:05Z
+20,,1,GET,200,/anime/3,100,2026-10-01T10:00:06Z
+31,30,1,GET,200,/anime/4,100,2026-10-01T10:00:07Z
+`
+	result, err := imp.Import(strings.NewReader(input), Options{Format: FormatCSV, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Imported != 5 || result.Failed != 2 {
+		t.Errorf("Expected 5 imported and 2 failed, got %+v", result)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Line != 7 || !strings.Contains(result.Errors[0].Error, "duplicate id 20") ||
+		result.Errors[1].Line != 8 || !strings.Contains(result.Errors[1].Error, "no row with id 30") {
+		t.Errorf("Unexpected errors %+v", result.Errors)
+	}
+
+	byPath := map[string][]models.APIRequest{}
+	for _, req := range listImported(t, repo) {
+		byPath[req.Path] = append(byPath[req.Path], req)
+	}
+	parent := byPath["/anime/1"][0]
+	for _, attempt := range byPath["/anime/1"][1:] {
+		if attempt.ParentID == nil || *attempt.ParentID != parent.ID {
+			t.Errorf("Expected attempt %d to belong to request %d, got %v", attempt.Attempt, parent.ID, attempt.ParentID)
+		}
+	}
+	if child := byPath["/anime/2"][1]; child.ParentID == nil || *child.ParentID != byPath["/anime/2"][0].ID {
+		t.Errorf("Expected the attempt to belong to the request before it, got %v", child.ParentID)
+	}
+}
+
+// Test 4: Detection runs on imported rows, problems dated when their request was logged
+func TestImport_Detect(t *testing.T) {
+	imp, _, problemRepo := setupImporter(t)
+
+	detect := detector.Func(func(req *models.APIRequest) []*models.Problem {
+		if req.ResponseStatus < 500 {
+			return nil
+		}
+		return []*models.Problem{{RequestID: req.ID, ProblemType: "server_error", Description: "Server error", CreatedAt: time.Now()}}
+	})
+	input := `{"method":"GET","path":"/anime/1","response":200,"response_time":10,"created_at":"2026-10-01T10:00:00Z"}
+{"method":"GET","path":"/anime/2","response":502,"response_time":10,"created_at":"2026-10-01T10:01:00Z"}
+`
+	result, err := imp.Import(strings.NewReader(input), Options{Format: FormatJSONL, Detector: detect})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Imported != 2 || result.Problems != 1 {
+		t.Errorf("Expected 2 imported with 1 problem, got %+v", result)
+	}
+
+	problems, err := problemRepo.List(repository.ProblemFilters{})
+	if err != nil || len(problems) != 1 {
+		t.Fatalf("Expected 1 problem, got %+v (%v)", problems, err)
+	}
+	if problems[0].Path != "/anime/2" || !problems[0].CreatedAt.Equal(time.Date(2026, 10, 1, 10, 1, 0, 0, time.UTC)) {
+		t.Errorf("Expected the problem of /anime/2 at its log time, got %+v", problems[0])
+	}
+
+	if _, err := imp.Import(strings.NewReader(input), Options{Format: "xml"}); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("Expected an unknown format to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"treblle_project/internal/models"
+)
+
+// maxLineBytes is the longest line of a JSONL file
+const maxLineBytes = 1 << 20
+
+// field reads one column of an import file into a record
+type field struct {
+	name string
+	set  func(rec *record, value string, loc *time.Location) error
+}
+
+// fields are the columns an import file may hold, named as in the request
+// exports. Values are text, as in CSV files or JSON values turned to text.
+var fields = []field{
+	{"id", func(rec *record, v string, _ *time.Location) error { return setInt(&rec.id, v) }},
+	{"method", func(rec *record, v string, _ *time.Location) error { rec.req.Method = strings.ToUpper(v); return nil }},
+	{"response", func(rec *record, v string, _ *time.Location) error { return setInt(&rec.req.ResponseStatus, v) }},
+	{"path", func(rec *record, v string, _ *time.Location) error { rec.req.Path = v; return nil }},
+	{"response_time", func(rec *record, v string, _ *time.Location) error { return setInt64(&rec.req.ResponseTimeMs, v) }},
+	{"response_bytes", func(rec *record, v string, _ *time.Location) error { return setInt64(&rec.req.ResponseBytes, v) }},
+	{"content_type", func(rec *record, v string, _ *time.Location) error { rec.req.ContentType = v; return nil }},
+	{"upstream_headers", func(rec *record, v string, _ *time.Location) error { return setHeaders(&rec.req.UpstreamHeaders, v) }},
+	{"dns_ms", func(rec *record, v string, _ *time.Location) error { return setInt64(&rec.req.Timing.DNSMs, v) }},
+	{"connect_ms", func(rec *record, v string, _ *time.Location) error { return setInt64(&rec.req.Timing.ConnectMs, v) }},
+	{"tls_ms", func(rec *record, v string, _ *time.Location) error { return setInt64(&rec.req.Timing.TLSMs, v) }},
+	{"ttfb_ms", func(rec *record, v string, _ *time.Location) error { return setInt64(&rec.req.Timing.TTFBMs, v) }},
+	{"transfer_ms", func(rec *record, v string, _ *time.Location) error { return setInt64(&rec.req.Timing.TransferMs, v) }},
+	{"conn_reused", func(rec *record, v string, _ *time.Location) error { return setBool(&rec.req.Timing.ConnReused, v) }},
+	{"throttle_status", func(rec *record, v string, _ *time.Location) error { rec.req.ThrottleStatus = v; return nil }},
+	{"queue_wait_ms", func(rec *record, v string, _ *time.Location) error { return setInt64(&rec.req.QueueWaitMs, v) }},
+	{"outcome", func(rec *record, v string, _ *time.Location) error { rec.req.Outcome = v; return nil }},
+	{"cache_status", func(rec *record, v string, _ *time.Location) error { rec.req.CacheStatus = v; return nil }},
+	{"coalesced", func(rec *record, v string, _ *time.Location) error { return setBool(&rec.req.Coalesced, v) }},
+	{"upstream_call_id", func(rec *record, v string, _ *time.Location) error { rec.req.UpstreamCallID = v; return nil }},
+	{"parent_id", func(rec *record, v string, _ *time.Location) error {
+		if v == "" {
+			return nil
+		}
+		rec.parentID = new(int)
+		return setInt(rec.parentID, v)
+	}},
+	{"attempt", func(rec *record, v string, _ *time.Location) error { return setInt(&rec.req.Attempt, v) }},
+	{"created_at", func(rec *record, v string, loc *time.Location) error { return setTime(&rec.req.CreatedAt, v, loc) }},
+}
+
+// requiredFields are the fields every imported row has
+var requiredFields = []string{"method", "path", "response", "response_time", "created_at"}
+
+// timingFields are the fields nested in the timing object of the API's JSON
+var timingFields = []string{"dns_ms", "connect_ms", "tls_ms", "ttfb_ms", "transfer_ms", "conn_reused"}
+
+func lookupField(name string) (field, bool) {
+	i := slices.IndexFunc(fields, func(f field) bool { return f.name == name })
+	if i < 0 {
+		return field{}, false
+	}
+	return fields[i], true
+}
+
+func fieldNames() string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return strings.Join(names, ", ")
+}
+
+func setInt(dst *int, v string) error {
+	if v == "" {
+		*dst = 0
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return errors.New("must be an integer")
+	}
+	*dst = n
+	return nil
+}
+
+func setInt64(dst *int64, v string) error {
+	if v == "" {
+		*dst = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return errors.New("must be an integer")
+	}
+	*dst = n
+	return nil
+}
+
+func setBool(dst *bool, v string) error {
+	if v == "" {
+		*dst = false
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return errors.New("must be true or false")
+	}
+	*dst = b
+	return nil
+}
+
+func setHeaders(dst *map[string]string, v string) error {
+	*dst = nil
+	if v == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(v), dst); err != nil {
+		return errors.New("must be a JSON object of strings")
+	}
+	return nil
+}
+
+// localTimeLayouts are the layouts of times without a zone, read in the
+// import's location
+var localTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// setTime reads a time in any time_format of the exports: an RFC3339
+// timestamp, a date-time without a zone, or Unix epoch seconds or
+// milliseconds (from 13 digits)
+func setTime(dst *time.Time, v string, loc *time.Location) error {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		*dst = t
+		return nil
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			*dst = t
+			return nil
+		}
+	}
+	if epoch, err := strconv.ParseInt(v, 10, 64); err == nil && epoch > 0 {
+		if len(v) >= 13 {
+			*dst = time.UnixMilli(epoch)
+		} else {
+			*dst = time.Unix(epoch, 0)
+		}
+		return nil
+	}
+	return errors.New("must be an RFC3339 timestamp, a YYYY-MM-DD HH:MM:SS date-time or a Unix epoch")
+}
+
+// Values of the enumerated fields
+var (
+	importMethods   = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+	throttleValues  = []string{models.ThrottleNone, models.ThrottleQueued, models.ThrottleRejected}
+	outcomeValues   = []string{"", models.OutcomeCircuitOpen, models.OutcomeStreaming, models.OutcomeClientCancelled, models.OutcomeDeadlineExceeded}
+	cacheValues     = []string{models.CacheNone, models.CacheHit, models.CacheMiss, models.CacheStale, models.CacheRevalidated, models.CacheRefresh}
+	nonNegativeInts = []struct {
+		name  string
+		value func(*models.APIRequest) int64
+	}{
+		{"response_time", func(r *models.APIRequest) int64 { return r.ResponseTimeMs }},
+		{"response_bytes", func(r *models.APIRequest) int64 { return r.ResponseBytes }},
+		{"dns_ms", func(r *models.APIRequest) int64 { return r.Timing.DNSMs }},
+		{"connect_ms", func(r *models.APIRequest) int64 { return r.Timing.ConnectMs }},
+		{"tls_ms", func(r *models.APIRequest) int64 { return r.Timing.TLSMs }},
+		{"ttfb_ms", func(r *models.APIRequest) int64 { return r.Timing.TTFBMs }},
+		{"transfer_ms", func(r *models.APIRequest) int64 { return r.Timing.TransferMs }},
+		{"queue_wait_ms", func(r *models.APIRequest) int64 { return r.QueueWaitMs }},
+		{"attempt", func(r *models.APIRequest) int64 { return int64(r.Attempt) }},
+	}
+)
+
+// validate checks that req could have been logged by the monitor
+func validate(req *models.APIRequest) error {
+	switch {
+	case !slices.Contains(importMethods, req.Method):
+		return fmt.Errorf("method: must be one of %s", strings.Join(importMethods, ", "))
+	case !strings.HasPrefix(req.Path, "/"):
+		return errors.New("path: must start with /")
+	case req.ResponseStatus != 0 && (req.ResponseStatus < 100 || req.ResponseStatus > 599):
+		return errors.New("response: must be 0, for no response, or between 100 and 599")
+	case !slices.Contains(throttleValues, req.ThrottleStatus):
+		return errors.New("throttle_status: must be empty, queued or rejected")
+	case !slices.Contains(outcomeValues, req.Outcome):
+		return fmt.Errorf("outcome: must be empty or one of %s", strings.Join(outcomeValues[1:], ", "))
+	case !slices.Contains(cacheValues, req.CacheStatus):
+		return fmt.Errorf("cache_status: must be empty or one of %s", strings.Join(cacheValues[1:], ", "))
+	case req.CreatedAt.IsZero():
+		return errors.New("created_at: is required")
+	}
+	for _, f := range nonNegativeInts {
+		if f.value(req) < 0 {
+			return fmt.Errorf("%s: must not be negative", f.name)
+		}
+	}
+	return nil
+}
+
+// readCSV reads CSV records with a header line naming their columns and
+// hands each to add
+func readCSV(r io.Reader, loc *time.Location, add func(record, error) error) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to read the CSV header: %w", ErrInvalidFile, err)
+	}
+
+	columns := make([]field, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		f, ok := lookupField(name)
+		switch {
+		case !ok:
+			return fmt.Errorf("%w: unknown column %q, expected some of %s", ErrInvalidFile, name, fieldNames())
+		case slices.ContainsFunc(columns[:i], func(c field) bool { return c.name == name }):
+			return fmt.Errorf("%w: duplicate column %q", ErrInvalidFile, name)
+		}
+		columns[i] = f
+	}
+	for _, name := range requiredFields {
+		if !slices.ContainsFunc(columns, func(c field) bool { return c.name == name }) {
+			return fmt.Errorf("%w: missing column %q, every file has %s", ErrInvalidFile, name, strings.Join(requiredFields, ", "))
+		}
+	}
+
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		line, _ := reader.FieldPos(0)
+		rec := record{line: line, req: &models.APIRequest{}}
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			rec.line = parseErr.Line
+			err = parseErr.Err
+		case err != nil:
+			return fmt.Errorf("failed to read CSV: %w", err)
+		default:
+			for i, value := range values {
+				if err = columns[i].set(&rec, value, loc); err != nil {
+					err = fmt.Errorf("%s: %w", columns[i].name, err)
+					break
+				}
+			}
+		}
+		if err := add(rec, err); err != nil {
+			return err
+		}
+	}
+}
+
+// readJSONL reads one JSON object per line and hands each to add. Objects
+// are flat with the export column names, as the NDJSON export writes them,
+// or have a timing object, as the API lists requests.
+func readJSONL(r io.Reader, loc *time.Location, add func(record, error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		rec := record{line: line, req: &models.APIRequest{}}
+		if err := add(rec, decodeJSON(&rec, text, loc)); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("%w: a line is longer than %d bytes", ErrInvalidFile, maxLineBytes)
+		}
+		return fmt.Errorf("failed to read JSONL: %w", err)
+	}
+	return nil
+}
+
+// decodeJSON reads the JSON object text into rec
+func decodeJSON(rec *record, text []byte, loc *time.Location) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(text, &object); err != nil {
+		return errors.New("must be a JSON object")
+	}
+	if timing, ok := object["timing"]; ok {
+		delete(object, "timing")
+		var nested map[string]json.RawMessage
+		if err := json.Unmarshal(timing, &nested); err != nil {
+			return errors.New("timing: must be a JSON object")
+		}
+		for name, value := range nested {
+			if !slices.Contains(timingFields, name) {
+				return fmt.Errorf("timing: unknown field %q, expected some of %s", name, strings.Join(timingFields, ", "))
+			}
+			object[name] = value
+		}
+	}
+
+	for _, name := range requiredFields {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s: is required", name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(object)) {
+		f, ok := lookupField(name)
+		if !ok {
+			return fmt.Errorf("unknown field %q, expected some of %s", name, fieldNames())
+		}
+		value, err := jsonText(object[name])
+		if err == nil {
+			err = f.set(rec, value, loc)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// jsonText returns a JSON value as the text a CSV cell holds it in: strings
+// unquoted, null empty, other values as written
+func jsonText(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case string(raw) == "null":
+		return "", nil
+	case len(raw) > 0 && raw[0] == '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	return string(raw), nil
+}
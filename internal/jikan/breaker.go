@@ -0,0 +1,266 @@
+package jikan
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Calls flow normally
+	BreakerOpen     BreakerState = "open"      // Calls are short-circuited until the cool-down ends
+	BreakerHalfOpen BreakerState = "half_open" // A single probe call decides whether to close again
+)
+
+// ErrCircuitOpen is returned (wrapped in a *CircuitOpenError) for calls short-circuited by an open breaker
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// CircuitOpenError tells the caller which breaker rejected the call and when it will probe again
+type CircuitOpenError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s for %s, retry after %s", ErrCircuitOpen, e.Key, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// BreakerConfig configures the circuit breakers of the client. A breaker opens
+// after FailureThreshold consecutive failures, or when at least MinRequests
+// calls in the current Window failed at ErrorRateThreshold or more.
+type BreakerConfig struct {
+	Enabled            bool
+	PerEndpoint        bool // One breaker per first path segment (/anime, /manga, ...) instead of per upstream
+	FailureThreshold   int
+	ErrorRateThreshold float64 // 0-1, 0 disables the error rate check
+	MinRequests        int
+	Window             time.Duration
+	Cooldown           time.Duration // How long the breaker stays open before a probe is let through
+}
+
+// BreakerEvent records a state change of a breaker
+type BreakerEvent struct {
+	Key    string
+	From   BreakerState
+	To     BreakerState
+	Reason string
+	At     time.Time
+}
+
+// BreakerStatus is a snapshot of a breaker for reporting
+type BreakerStatus struct {
+	Key                 string       `json:"key" example:"api.jikan.moe"`
+	State               BreakerState `json:"state" example:"closed"`
+	ConsecutiveFailures int          `json:"consecutive_failures" example:"0"`
+	WindowRequests      int          `json:"window_requests" example:"42"`
+	WindowFailures      int          `json:"window_failures" example:"3"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAfterMs        int64        `json:"retry_after_ms,omitempty" example:"12000"`
+}
+
+// breaker is the state machine of one upstream (or endpoint)
+type breaker struct {
+	mu                  sync.Mutex
+	key                 string
+	config              BreakerConfig
+	state               BreakerState
+	consecutiveFailures int
+	windowStart         time.Time
+	windowRequests      int
+	windowFailures      int
+	openedAt            time.Time
+	probeInFlight       bool
+	probe               probeToken // Token of the latest probe, only its result moves a half-open breaker
+}
+
+// probeToken identifies the probe call of a half-open breaker. Calls that
+// aren't probes get noProbe.
+type probeToken uint64
+
+const noProbe probeToken = 0
+
+func newBreaker(key string, config BreakerConfig, now time.Time) *breaker {
+	return &breaker{key: key, config: config, state: BreakerClosed, windowStart: now}
+}
+
+// allow decides whether a call may proceed. It may move an open breaker to
+// half-open, in which case the transition is returned. The returned token
+// must be handed back to record or release, it tells the probe of a
+// half-open breaker apart from calls that were allowed before.
+func (b *breaker) allow(now time.Time) (probeToken, *BreakerEvent, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if remaining := b.openedAt.Add(b.config.Cooldown).Sub(now); remaining > 0 {
+			return noProbe, nil, &CircuitOpenError{Key: b.key, RetryAfter: remaining}
+		}
+		event := b.transition(BreakerHalfOpen, "cool-down elapsed, probing upstream", now)
+		return b.startProbe(), event, nil
+	case BreakerHalfOpen:
+		if b.probeInFlight {
+			return noProbe, nil, &CircuitOpenError{Key: b.key, RetryAfter: b.config.Cooldown}
+		}
+		return b.startProbe(), nil, nil
+	}
+	return noProbe, nil, nil
+}
+
+func (b *breaker) startProbe() probeToken {
+	b.probe++
+	b.probeInFlight = true
+	return b.probe
+}
+
+// record feeds the outcome of an allowed call and returns the resulting
+// transition, if any. While half-open only the probe's outcome decides the
+// state, calls that were still in flight from before are just counted.
+func (b *breaker) record(token probeToken, failed bool, now time.Time) *BreakerEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && token != noProbe && token == b.probe {
+		b.probeInFlight = false
+		if failed {
+			return b.transition(BreakerOpen, "probe call failed", now)
+		}
+		return b.transition(BreakerClosed, "probe call succeeded", now)
+	}
+
+	if b.config.Window > 0 && now.Sub(b.windowStart) >= b.config.Window {
+		b.windowStart = now
+		b.windowRequests = 0
+		b.windowFailures = 0
+	}
+	b.windowRequests++
+	if !failed {
+		b.consecutiveFailures = 0
+		return nil
+	}
+	b.windowFailures++
+	b.consecutiveFailures++
+
+	if b.state != BreakerClosed {
+		return nil
+	}
+	if b.config.FailureThreshold > 0 && b.consecutiveFailures >= b.config.FailureThreshold {
+		return b.transition(BreakerOpen, fmt.Sprintf("%d consecutive failures", b.consecutiveFailures), now)
+	}
+	if b.config.ErrorRateThreshold > 0 && b.windowRequests >= b.config.MinRequests {
+		if rate := float64(b.windowFailures) / float64(b.windowRequests); rate >= b.config.ErrorRateThreshold {
+			return b.transition(BreakerOpen, fmt.Sprintf("error rate %.0f%% over %d calls", rate*100, b.windowRequests), now)
+		}
+	}
+	return nil
+}
+
+// release gives back the probe slot of a half-open breaker when the probe
+// never reached the upstream (e.g. it was rate limited locally)
+func (b *breaker) release(token probeToken) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && token != noProbe && token == b.probe {
+		b.probeInFlight = false
+	}
+}
+
+func (b *breaker) transition(to BreakerState, reason string, now time.Time) *BreakerEvent {
+	event := &BreakerEvent{Key: b.key, From: b.state, To: to, Reason: reason, At: now}
+	b.state = to
+	switch to {
+	case BreakerOpen:
+		b.openedAt = now
+	case BreakerClosed:
+		b.consecutiveFailures = 0
+		b.windowStart = now
+		b.windowRequests = 0
+		b.windowFailures = 0
+	}
+	return event
+}
+
+func (b *breaker) status(now time.Time) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Key:                 b.key,
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		WindowRequests:      b.windowRequests,
+		WindowFailures:      b.windowFailures,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if b.state == BreakerOpen {
+		if remaining := b.openedAt.Add(b.config.Cooldown).Sub(now); remaining > 0 {
+			status.RetryAfterMs = remaining.Milliseconds()
+		}
+	}
+	return status
+}
+
+// breakerKey names the breaker guarding a call: the upstream host, plus the
+// first path segment when breakers are kept per endpoint
+func breakerKey(host, path string, perEndpoint bool) string {
+	if !perEndpoint {
+		return host
+	}
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return host + "/" + segment
+}
+
+// breakerSet holds the breakers of a client, created on first use
+type breakerSet struct {
+	mu       sync.Mutex
+	config   BreakerConfig
+	breakers map[string]*breaker
+	now      func() time.Time
+}
+
+func newBreakerSet(config BreakerConfig) *breakerSet {
+	return &breakerSet{config: config, breakers: make(map[string]*breaker), now: time.Now}
+}
+
+func (s *breakerSet) get(key string) *breaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.breakers[key]
+	if !ok {
+		b = newBreaker(key, s.config, s.now())
+		s.breakers[key] = b
+	}
+	return b
+}
+
+func (s *breakerSet) statuses() []BreakerStatus {
+	s.mu.Lock()
+	breakers := make([]*breaker, 0, len(s.breakers))
+	for _, b := range s.breakers {
+		breakers = append(breakers, b)
+	}
+	s.mu.Unlock()
+
+	now := s.now()
+	statuses := make([]BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.status(now))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses
+}
@@ -0,0 +1,169 @@
+package jikan
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Enabled:          true,
+		FailureThreshold: 3,
+		MinRequests:      10,
+		Window:           time.Minute,
+		Cooldown:         10 * time.Second,
+	}
+}
+
+// Test 1: Consecutive failures open the breaker and calls are short-circuited
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker("api.jikan.moe", testBreakerConfig(), now)
+
+	var event *BreakerEvent
+	for i := 0; i < 3; i++ {
+		if _, _, err := b.allow(now); err != nil {
+			t.Fatalf("Expected closed breaker to allow call %d, got %v", i, err)
+		}
+		event = b.record(noProbe, true, now)
+	}
+
+	if event == nil || event.From != BreakerClosed || event.To != BreakerOpen {
+		t.Fatalf("Expected closed -> open transition, got %+v", event)
+	}
+
+	_, _, err := b.allow(now.Add(time.Second))
+	var circuitErr *CircuitOpenError
+	if !errors.As(err, &circuitErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected CircuitOpenError, got %v", err)
+	}
+	if circuitErr.RetryAfter != 9*time.Second {
+		t.Errorf("Expected 9s until probe, got %s", circuitErr.RetryAfter)
+	}
+}
+
+// Test 2: A success resets the consecutive failure count
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker("api.jikan.moe", testBreakerConfig(), now)
+
+	b.record(noProbe, true, now)
+	b.record(noProbe, true, now)
+	b.record(noProbe, false, now)
+	if event := b.record(noProbe, true, now); event != nil {
+		t.Errorf("Expected breaker to stay closed, got %+v", event)
+	}
+}
+
+// Test 3: After the cool-down one probe is let through and decides the state
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker("api.jikan.moe", testBreakerConfig(), now)
+	for i := 0; i < 3; i++ {
+		b.record(noProbe, true, now)
+	}
+
+	later := now.Add(10 * time.Second)
+	probe, event, err := b.allow(later)
+	if err != nil || event == nil || event.To != BreakerHalfOpen {
+		t.Fatalf("Expected open -> half_open on probe, got event=%+v err=%v", event, err)
+	}
+
+	// Only one probe at a time
+	if _, _, err := b.allow(later); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected concurrent call to be rejected while probing, got %v", err)
+	}
+
+	event = b.record(probe, false, later)
+	if event == nil || event.To != BreakerClosed {
+		t.Fatalf("Expected successful probe to close the breaker, got %+v", event)
+	}
+	if _, _, err := b.allow(later); err != nil {
+		t.Errorf("Expected closed breaker to allow calls, got %v", err)
+	}
+}
+
+// Test 4: A failed probe re-opens the breaker for another cool-down
+func TestBreaker_FailedProbeReopens(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker("api.jikan.moe", testBreakerConfig(), now)
+	for i := 0; i < 3; i++ {
+		b.record(noProbe, true, now)
+	}
+
+	later := now.Add(10 * time.Second)
+	probe, _, _ := b.allow(later)
+	event := b.record(probe, true, later)
+	if event == nil || event.From != BreakerHalfOpen || event.To != BreakerOpen {
+		t.Fatalf("Expected half_open -> open, got %+v", event)
+	}
+	if _, _, err := b.allow(later.Add(5 * time.Second)); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected breaker to be open again, got %v", err)
+	}
+}
+
+// Test 5: The error rate threshold opens the breaker without consecutive failures
+func TestBreaker_ErrorRate(t *testing.T) {
+	config := testBreakerConfig()
+	config.FailureThreshold = 0
+	config.ErrorRateThreshold = 0.5
+	config.MinRequests = 4
+
+	now := time.Unix(0, 0)
+	b := newBreaker("api.jikan.moe", config, now)
+
+	b.record(noProbe, true, now)
+	b.record(noProbe, false, now)
+	b.record(noProbe, true, now)
+	event := b.record(noProbe, false, now)
+	if event != nil {
+		t.Fatalf("Expected the 4th call to end with a success and no transition, got %+v", event)
+	}
+	if event := b.record(noProbe, true, now); event == nil || event.To != BreakerOpen {
+		t.Errorf("Expected 60%% error rate to open the breaker, got %+v", event)
+	}
+}
+
+// Test 6: Calls still in flight from before the breaker opened don't decide the probe
+func TestBreaker_StaleResultDuringProbe(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker("api.jikan.moe", testBreakerConfig(), now)
+
+	stale, _, err := b.allow(now)
+	if err != nil || stale != noProbe {
+		t.Fatalf("Expected closed breaker to allow a non-probe call, got token=%d err=%v", stale, err)
+	}
+	for i := 0; i < 3; i++ {
+		b.record(noProbe, true, now)
+	}
+
+	later := now.Add(10 * time.Second)
+	probe, _, err := b.allow(later)
+	if err != nil || probe == noProbe {
+		t.Fatalf("Expected a probe after the cool-down, got token=%d err=%v", probe, err)
+	}
+
+	if event := b.record(stale, false, later); event != nil {
+		t.Errorf("Expected stale success to leave the breaker half_open, got %+v", event)
+	}
+	b.release(stale)
+	if _, _, err := b.allow(later); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected the probe slot to stay taken after a stale result, got %v", err)
+	}
+
+	event := b.record(probe, true, later)
+	if event == nil || event.From != BreakerHalfOpen || event.To != BreakerOpen {
+		t.Fatalf("Expected the probe's failure to re-open the breaker, got %+v", event)
+	}
+}
+
+// Test 7: Breaker keys are per upstream, optionally per endpoint
+func TestBreakerKey(t *testing.T) {
+	if key := breakerKey("api.jikan.moe", "/anime/1/characters", false); key != "api.jikan.moe" {
+		t.Errorf("Expected per-upstream key, got %s", key)
+	}
+	if key := breakerKey("api.jikan.moe", "/anime/1/characters", true); key != "api.jikan.moe/anime" {
+		t.Errorf("Expected per-endpoint key, got %s", key)
+	}
+}
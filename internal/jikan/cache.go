@@ -0,0 +1,339 @@
+package jikan
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"treblle_project/internal/cache"
+	"treblle_project/internal/models"
+)
+
+// CacheConfig configures the response cache in front of the upstream
+type CacheConfig struct {
+	Enabled              bool
+	MaxBytes             int64         // Size cap of the in-memory LRU tier
+	SQLite               bool          // Keep a second, SQLite-backed tier (see SecondTier)
+	SQLiteMaxBytes       int64         // Size cap of the SQLite tier
+	SecondTier           cache.Store   // Larger store consulted on in-memory misses, set by the caller when SQLite is on
+	DefaultTTL           time.Duration // TTL of paths no rule matches
+	Rules                []CacheRule   // First matching rule wins
+	HonorCacheControl    bool          // Let upstream Cache-Control shorten TTLs and forbid storing
+	StaleWhileRevalidate time.Duration // Serve expired entries this long while refreshing them in the background
+	StaleIfError         time.Duration // Serve expired entries this long when the upstream fails
+}
+
+// CacheRule sets the TTL of paths matching Pattern. Patterns use path.Match
+// syntax, and a trailing "/**" matches any number of path segments.
+// A zero TTL disables caching for matching paths.
+type CacheRule struct {
+	Pattern string
+	TTL     time.Duration
+}
+
+func (r CacheRule) matches(p string) bool {
+	if prefix, ok := strings.CutSuffix(r.Pattern, "/**"); ok {
+		return p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+	ok, _ := path.Match(r.Pattern, p)
+	return ok
+}
+
+// ttlFor returns the configured TTL of a request path, ignoring its query string
+func (cfg CacheConfig) ttlFor(requestPath string) time.Duration {
+	p, _, _ := strings.Cut(requestPath, "?")
+	for _, rule := range cfg.Rules {
+		if rule.matches(p) {
+			return rule.TTL
+		}
+	}
+	return cfg.DefaultTTL
+}
+
+// cacheControl holds the Cache-Control directives relevant to a shared cache
+type cacheControl struct {
+	noStore              bool
+	noCache              bool
+	maxAge               time.Duration
+	hasMaxAge            bool
+	staleWhileRevalidate time.Duration
+	hasSWR               bool
+	staleIfError         time.Duration
+	hasSIE               bool
+}
+
+func parseCacheControl(value string) cacheControl {
+	var cc cacheControl
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.Atoi(strings.Trim(arg, `"`))
+		valid := err == nil && seconds >= 0
+		d := time.Duration(seconds) * time.Second
+
+		switch strings.ToLower(name) {
+		case "no-store", "private":
+			cc.noStore = true
+		case "no-cache":
+			cc.noCache = true
+		case "max-age":
+			if valid && !cc.hasMaxAge {
+				cc.maxAge, cc.hasMaxAge = d, true
+			}
+		case "s-maxage":
+			if valid { // Takes precedence over max-age for shared caches
+				cc.maxAge, cc.hasMaxAge = d, true
+			}
+		case "stale-while-revalidate":
+			if valid {
+				cc.staleWhileRevalidate, cc.hasSWR = d, true
+			}
+		case "stale-if-error":
+			if valid {
+				cc.staleIfError, cc.hasSIE = d, true
+			}
+		}
+	}
+	return cc
+}
+
+// newEntry builds the cache entry for a successful upstream response,
+// or returns nil when the response must not be stored. Entries are keyed by
+// path alone, so responses that vary on headers forwarded from the client
+// are not stored.
+func (cfg CacheConfig) newEntry(key string, metrics *RequestMetrics, headers HeaderPolicy, now time.Time) *cache.Entry {
+	if metrics.ResponseStatus != http.StatusOK || headers.variesPerClient(metrics.header) {
+		return nil
+	}
+	ttl := cfg.ttlFor(key)
+	if ttl <= 0 {
+		return nil
+	}
+
+	entry := &cache.Entry{
+		Key:          key,
+		Status:       metrics.ResponseStatus,
+		Header:       metrics.header.Clone(),
+		Body:         metrics.ResponseBody,
+		StoredAt:     now,
+		StaleWindow:  cfg.StaleWhileRevalidate,
+		ErrorWindow:  cfg.StaleIfError,
+		ETag:         metrics.header.Get("ETag"),
+		LastModified: metrics.header.Get("Last-Modified"),
+	}
+
+	if cfg.HonorCacheControl {
+		cc := parseCacheControl(metrics.header.Get("Cache-Control"))
+		if cc.noStore {
+			return nil
+		}
+		if cc.hasMaxAge {
+			// Age is how long the response already sat in upstream caches
+			age, _ := strconv.Atoi(metrics.header.Get("Age"))
+			ttl = min(ttl, cc.maxAge-time.Duration(age)*time.Second)
+		}
+		if cc.noCache {
+			// Must be revalidated before every use, so only worth keeping with a validator
+			if !entry.Revalidatable() {
+				return nil
+			}
+			ttl = 0
+			cc.staleWhileRevalidate, cc.hasSWR = 0, true
+		}
+		if cc.hasSWR {
+			entry.StaleWindow = cc.staleWhileRevalidate
+		}
+		if cc.hasSIE {
+			entry.ErrorWindow = cc.staleIfError
+		}
+	}
+
+	entry.ExpiresAt = now.Add(max(ttl, 0))
+	return entry
+}
+
+// refreshEntry extends an entry the upstream confirmed with 304 Not Modified
+func (cfg CacheConfig) refreshEntry(entry *cache.Entry, metrics *RequestMetrics, headers HeaderPolicy, now time.Time) *cache.Entry {
+	refreshed := *entry
+	refreshed.Header = entry.Header.Clone()
+	// A 304 carries the headers that would have been sent with a 200
+	for _, name := range []string{"Cache-Control", "ETag", "Expires", "Last-Modified", "Age", "Vary"} {
+		if value := metrics.header.Get(name); value != "" {
+			refreshed.Header.Set(name, value)
+		}
+	}
+
+	return cfg.newEntry(entry.Key, &RequestMetrics{
+		ResponseStatus: entry.Status,
+		ResponseBody:   entry.Body,
+		header:         refreshed.Header,
+	}, headers, now)
+}
+
+// responseCache is the client side state of the cache
+type responseCache struct {
+	store cache.Store
+
+	mu           sync.Mutex
+	revalidating map[string]bool // Keys with a background revalidation in flight
+	background   sync.WaitGroup
+	report       func(metrics *RequestMetrics, err error) // Gets the background upstream calls, may be nil
+}
+
+// BackgroundCallReporter is implemented by clients that call the upstream on
+// their own, such as to refresh cache entries served stale, which no request
+// logs otherwise. fn gets each such call once it completes. It must be set
+// before any request is made.
+type BackgroundCallReporter interface {
+	OnBackgroundCall(fn func(metrics *RequestMetrics, err error))
+}
+
+// Ensure Client implements BackgroundCallReporter
+var _ BackgroundCallReporter = (*Client)(nil)
+
+// OnBackgroundCall sets the function background upstream calls are passed to
+func (c *Client) OnBackgroundCall(fn func(metrics *RequestMetrics, err error)) {
+	if c.cache != nil {
+		c.cache.report = fn
+	}
+}
+
+func newResponseCache(cfg CacheConfig) *responseCache {
+	return &responseCache{
+		store:        cache.NewTiered(cache.NewLRU(cfg.MaxBytes), cfg.SecondTier),
+		revalidating: make(map[string]bool),
+	}
+}
+
+// cachedMetrics describes a response served from a cache entry
+func (c *Client) cachedMetrics(key string, entry *cache.Entry, status string, elapsed time.Duration) *RequestMetrics {
+	return &RequestMetrics{
+		Method:         "GET",
+		Path:           key,
+		ResponseStatus: entry.Status,
+		ResponseTimeMs: elapsed.Milliseconds(),
+		ResponseBytes:  int64(len(entry.Body)),
+		ContentType:    entry.Header.Get("Content-Type"),
+		Headers:        recordHeaders(entry.Header),
+		ResponseHeader: c.config.Headers.responseHeader(entry.Header),
+		CacheStatus:    status,
+		ResponseBody:   entry.Body,
+		header:         entry.Header,
+	}
+}
+
+// conditionalHeader builds the validators sent when revalidating an entry
+func conditionalHeader(entry *cache.Entry) http.Header {
+	header := http.Header{}
+	if entry.ETag != "" {
+		header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		header.Set("If-Modified-Since", entry.LastModified)
+	}
+	return header
+}
+
+// cachedRequest serves path from the cache when possible and fetches it
+// otherwise, sending header with upstream requests
+func (c *Client) cachedRequest(ctx context.Context, path string, header http.Header) (*RequestMetrics, error) {
+	startTime := time.Now()
+	entry, found := c.cache.store.Get(path)
+
+	if found && entry.Fresh(startTime) {
+		return c.cachedMetrics(path, entry, models.CacheHit, time.Since(startTime)), nil
+	}
+
+	if found && entry.ServableStale(startTime) {
+		c.revalidateInBackground(path, entry, header)
+		return c.cachedMetrics(path, entry, models.CacheStale, time.Since(startTime)), nil
+	}
+
+	upstreamHeader := header
+	if found && entry.Revalidatable() {
+		upstreamHeader = mergeHeaders(header, conditionalHeader(entry))
+	}
+
+	metrics, err := c.coalescedFetch(ctx, path, upstreamHeader)
+
+	if found && err == nil && metrics.ResponseStatus == http.StatusNotModified {
+		served := *metrics
+		refreshed := c.config.Cache.refreshEntry(entry, metrics, c.config.Headers, time.Now())
+		if refreshed != nil {
+			_ = c.cache.store.Set(refreshed)
+			served.ResponseHeader = c.config.Headers.responseHeader(refreshed.Header)
+		} else {
+			_ = c.cache.store.Delete(path)
+			served.ResponseHeader = c.config.Headers.responseHeader(entry.Header)
+		}
+		served.ResponseStatus = entry.Status
+		served.ResponseBody = entry.Body
+		served.ResponseBytes = int64(len(entry.Body))
+		served.ContentType = entry.Header.Get("Content-Type")
+		served.CacheStatus = models.CacheRevalidated
+		return &served, nil
+	}
+
+	upstreamFailed := (err != nil && !metrics.Cancelled) || metrics.ResponseStatus >= 500
+	if found && upstreamFailed && entry.ServableOnError(startTime) {
+		served := c.cachedMetrics(path, entry, models.CacheStale, time.Since(startTime))
+		served.BreakerEvents = metrics.BreakerEvents
+		return served, nil
+	}
+
+	metrics.CacheStatus = models.CacheMiss
+	if err == nil {
+		if fresh := c.config.Cache.newEntry(path, metrics, c.config.Headers, time.Now()); fresh != nil {
+			_ = c.cache.store.Set(fresh) // Entries too large for the cache are simply not kept
+		}
+	}
+	return metrics, err
+}
+
+// revalidateInBackground refreshes an expired entry while it keeps being served stale
+func (c *Client) revalidateInBackground(path string, entry *cache.Entry, header http.Header) {
+	c.cache.mu.Lock()
+	if c.cache.revalidating[path] {
+		c.cache.mu.Unlock()
+		return
+	}
+	c.cache.revalidating[path] = true
+	c.cache.background.Add(1)
+	c.cache.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.cache.mu.Lock()
+			delete(c.cache.revalidating, path)
+			c.cache.mu.Unlock()
+			c.cache.background.Done()
+		}()
+
+		upstreamHeader := header
+		if entry.Revalidatable() {
+			upstreamHeader = mergeHeaders(header, conditionalHeader(entry))
+		}
+		// Detached from any caller, the refresh outlives the request that triggered it
+		metrics, err := c.coalescedFetch(context.Background(), path, upstreamHeader)
+		// A call joined as a follower is logged by the request that made it
+		if !metrics.Coalesced && c.cache.report != nil {
+			metrics.CacheStatus = models.CacheRefresh
+			c.cache.report(metrics, err)
+		}
+		if err != nil {
+			return // Keep serving the stale entry until its windows run out
+		}
+
+		var updated *cache.Entry
+		if metrics.ResponseStatus == http.StatusNotModified {
+			updated = c.config.Cache.refreshEntry(entry, metrics, c.config.Headers, time.Now())
+		} else {
+			updated = c.config.Cache.newEntry(path, metrics, c.config.Headers, time.Now())
+		}
+		if updated != nil {
+			_ = c.cache.store.Set(updated)
+		}
+	}()
+}
@@ -0,0 +1,257 @@
+package jikan
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"treblle_project/internal/models"
+)
+
+// newCachingTestClient points a Client with caching enabled at a local test server
+func newCachingTestClient(server *httptest.Server) *Client {
+	cfg := DefaultConfig()
+	cfg.BaseURL = server.URL
+	cfg.Cache.Enabled = true
+	return NewClientWithConfig(cfg)
+}
+
+// expireEntry makes a cached entry stale as of now
+func expireEntry(t *testing.T, client *Client, key string) {
+	t.Helper()
+	entry, ok := client.cache.store.Get(key)
+	if !ok {
+		t.Fatalf("Expected %s to be cached", key)
+	}
+	expired := *entry
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	client.cache.store.Set(&expired)
+}
+
+// Test 1: Repeated calls are served from the cache
+func TestClient_CacheHit(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	client := newCachingTestClient(server)
+
+	metrics, err := client.ProxyRequest(context.Background(), "/top/anime", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metrics.CacheStatus != models.CacheMiss {
+		t.Errorf("Expected MISS, got %q", metrics.CacheStatus)
+	}
+
+	metrics, err = client.ProxyRequest(context.Background(), "/top/anime", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metrics.CacheStatus != models.CacheHit {
+		t.Errorf("Expected HIT, got %q", metrics.CacheStatus)
+	}
+	if string(metrics.ResponseBody) != `{"data":[]}` || metrics.ContentType != "application/json" {
+		t.Errorf("Unexpected cached response %q (%s)", metrics.ResponseBody, metrics.ContentType)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls.Load())
+	}
+}
+
+// Test 2: Cache-Control and path rules decide what is stored
+func TestClient_CacheRespectsRulesAndCacheControl(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/anime/1" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := newCachingTestClient(server)
+
+	for _, path := range []string{"/anime/1", "/random/anime"} {
+		client.ProxyRequest(context.Background(), path, nil)
+		metrics, _ := client.ProxyRequest(context.Background(), path, nil)
+		if metrics.CacheStatus != models.CacheMiss {
+			t.Errorf("Expected %s not to be cached, got %q", path, metrics.CacheStatus)
+		}
+	}
+	if calls.Load() != 4 {
+		t.Errorf("Expected 4 upstream calls, got %d", calls.Load())
+	}
+}
+
+// Test 3: Expired entries with an ETag are revalidated with a conditional request
+func TestClient_CacheRevalidatesWithETag(t *testing.T) {
+	var conditional atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=60, stale-while-revalidate=0")
+		w.Write([]byte(`{"data":1}`))
+	}))
+	defer server.Close()
+
+	client := newCachingTestClient(server)
+	client.ProxyRequest(context.Background(), "/anime/1", nil)
+	expireEntry(t, client, "/anime/1")
+
+	metrics, err := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metrics.CacheStatus != models.CacheRevalidated {
+		t.Errorf("Expected REVALIDATED, got %q", metrics.CacheStatus)
+	}
+	if metrics.ResponseStatus != 200 || string(metrics.ResponseBody) != `{"data":1}` {
+		t.Errorf("Expected the cached 200 body, got %d %q", metrics.ResponseStatus, metrics.ResponseBody)
+	}
+	if conditional.Load() != 1 {
+		t.Errorf("Expected 1 conditional request, got %d", conditional.Load())
+	}
+
+	// The 304 renewed the entry
+	metrics, _ = client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if metrics.CacheStatus != models.CacheHit {
+		t.Errorf("Expected HIT after revalidation, got %q", metrics.CacheStatus)
+	}
+}
+
+// Test 4: Stale entries are served while revalidating, reporting the refresh, and when the upstream fails
+func TestClient_CacheServesStale(t *testing.T) {
+	var failing atomic.Bool
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"data":1}`))
+	}))
+	defer server.Close()
+
+	client := newCachingTestClient(server)
+	var refreshes []*RequestMetrics
+	client.OnBackgroundCall(func(metrics *RequestMetrics, err error) {
+		refreshes = append(refreshes, metrics)
+	})
+	client.ProxyRequest(context.Background(), "/anime/1", nil)
+
+	// stale-while-revalidate: served immediately, refreshed in the background
+	expireEntry(t, client, "/anime/1")
+	metrics, _ := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if metrics.CacheStatus != models.CacheStale {
+		t.Errorf("Expected STALE, got %q", metrics.CacheStatus)
+	}
+	client.cache.background.Wait()
+	if calls.Load() != 2 {
+		t.Errorf("Expected a background refresh, got %d upstream calls", calls.Load())
+	}
+	if len(refreshes) != 1 || refreshes[0].CacheStatus != models.CacheRefresh || refreshes[0].ResponseStatus != 200 {
+		t.Errorf("Expected the background refresh to be reported, got %+v", refreshes)
+	}
+	metrics, _ = client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if metrics.CacheStatus != models.CacheHit {
+		t.Errorf("Expected HIT after the background refresh, got %q", metrics.CacheStatus)
+	}
+
+	// stale-if-error: outside the revalidate window, a failing upstream falls back to the entry
+	client.config.Cache.StaleWhileRevalidate = 0
+	client.config.Retry.MaxAttempts = 1
+	entry, _ := client.cache.store.Get("/anime/1")
+	expired := *entry
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	expired.StaleWindow = 0
+	client.cache.store.Set(&expired)
+	failing.Store(true)
+
+	metrics, err := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if err != nil {
+		t.Fatalf("Expected the stale entry instead of an error, got %v", err)
+	}
+	if metrics.CacheStatus != models.CacheStale || metrics.ResponseStatus != 200 {
+		t.Errorf("Expected a stale 200, got %q %d", metrics.CacheStatus, metrics.ResponseStatus)
+	}
+}
+
+// Test 5: Cache rules match path globs and "/**" prefixes
+func TestCacheConfig_TTLFor(t *testing.T) {
+	cfg := CacheConfig{
+		DefaultTTL: time.Minute,
+		Rules: []CacheRule{
+			{Pattern: "/random/**", TTL: 0},
+			{Pattern: "/anime/*", TTL: time.Hour},
+		},
+	}
+
+	tests := []struct {
+		path     string
+		expected time.Duration
+	}{
+		{"/random/anime", 0},
+		{"/random", 0},
+		{"/anime/1", time.Hour},
+		{"/anime/1?sfw=true", time.Hour},
+		{"/anime/1/characters", time.Minute},
+		{"/manga/1", time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.ttlFor(tt.path); got != tt.expected {
+			t.Errorf("ttlFor(%s) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+// Test 6: Responses varying on forwarded client headers are not cached, other Vary fields are
+func TestClient_CacheVary(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/anime/1" {
+			w.Header().Set("Vary", "Accept-Encoding, Accept-Language")
+			w.Write([]byte(`{"lang":"` + r.Header.Get("Accept-Language") + `"}`))
+			return
+		}
+		w.Header().Set("Vary", "Accept-Encoding")
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	client := newCachingTestClient(server)
+
+	for _, lang := range []string{"en", "ja"} {
+		metrics, err := client.ProxyRequest(context.Background(), "/anime/1", http.Header{"Accept-Language": {lang}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if metrics.CacheStatus != models.CacheMiss || string(metrics.ResponseBody) != `{"lang":"`+lang+`"}` {
+			t.Errorf("Expected the %s variant from the upstream, got %q %s", lang, metrics.CacheStatus, metrics.ResponseBody)
+		}
+	}
+
+	for range 2 {
+		if _, err := client.ProxyRequest(context.Background(), "/top/anime", nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected a response varying on a header the proxy doesn't forward to be cached, got %d upstream calls", calls.Load())
+	}
+}
@@ -1,72 +1,343 @@
 package jikan
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"sync"
 	"time"
+	"treblle_project/internal/models"
 )
 
 const BaseURL = "https://api.jikan.moe/v4"
 
+// RecordedHeaders lists the upstream response headers kept with each logged
+// request. Jikan uses X-Request-Fingerprint to identify its cached responses.
+var RecordedHeaders = []string{
+	"X-Request-Fingerprint",
+	"Cache-Control",
+	"Age",
+	"Retry-After",
+}
+
 // JikanClient is an interface for making requests to the Jikan API
 // This allows for easy mocking in tests
 type JikanClient interface {
-	ProxyRequest(path string) (*RequestMetrics, error)
+	ProxyRequest(ctx context.Context, path string, header http.Header) (*RequestMetrics, error)
 }
 
 type Client struct {
 	httpClient *http.Client
 	baseURL    string
+	config     Config
+
+	limitersMu sync.Mutex
+	limiters   map[string]*Limiter // Keyed by upstream host
+
+	breakers *breakerSet    // nil when circuit breaking is disabled
+	cache    *responseCache // nil when caching is disabled
+	flights  *flightGroup   // nil when coalescing is disabled
+}
+
+// BreakerReporter exposes circuit breaker state for monitoring
+type BreakerReporter interface {
+	BreakerStatus() []BreakerStatus
 }
 
-// Ensure Client implements JikanClient
-var _ JikanClient = (*Client)(nil)
+// Ensure Client implements JikanClient and BreakerReporter
+var (
+	_ JikanClient     = (*Client)(nil)
+	_ BreakerReporter = (*Client)(nil)
+)
 
 type RequestMetrics struct {
 	Method         string
 	Path           string
 	ResponseStatus int
 	ResponseTimeMs int64
+	ResponseBytes  int64
+	ContentType    string
+	Headers        map[string]string // Upstream values of RecordedHeaders that were present
+	ResponseHeader http.Header       // Upstream response headers to pass on to the client, per the header policy
+	Timing         Timing
+	Throttle       string // One of the models.Throttle* outcomes
+	QueueWaitMs    int64  // Time spent waiting for a rate limit token, not part of ResponseTimeMs
+	RetryAfter     time.Duration
+	CircuitOpen    bool              // Short-circuited by an open breaker, the upstream was not called
+	BreakerEvents  []BreakerEvent    // Breaker state changes caused by this call
+	Attempt        int               // 1-based attempt number
+	Attempts       []*RequestMetrics // Every attempt, set only when the request was retried
+	CacheStatus    string            // One of the models.Cache* statuses, models.CacheNone when caching is off
+	UpstreamCallID string            // Identifies the upstream call, shared by requests coalesced onto it
+	Coalesced      bool              // Served by another request's in-flight upstream call
+	Streamed       bool              // The response was written to the caller's writer by StreamRequest
+	Cancelled      bool              // The caller's context ended before the call completed
 	ResponseBody   []byte
 	Error          error
+
+	transportErr bool        // The request failed before a complete response was read
+	header       http.Header // Full upstream response headers
 }
 
 func NewClient() *Client {
-	return &Client{
+	return NewClientWithConfig(DefaultConfig())
+}
+
+func NewClientWithConfig(cfg Config) *Client {
+	client := &Client{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: cfg.Timeout,
 		},
-		baseURL: BaseURL,
+		baseURL:  cfg.BaseURL,
+		config:   cfg,
+		limiters: make(map[string]*Limiter),
+	}
+	if cfg.Breaker.Enabled {
+		client.breakers = newBreakerSet(cfg.Breaker)
+	}
+	if cfg.Cache.Enabled {
+		client.cache = newResponseCache(cfg.Cache)
+	}
+	if cfg.Coalesce {
+		client.flights = newFlightGroup()
+	}
+	return client
+}
+
+// BreakerStatus reports the state of every circuit breaker used so far
+func (c *Client) BreakerStatus() []BreakerStatus {
+	if c.breakers == nil {
+		return []BreakerStatus{}
+	}
+	return c.breakers.statuses()
+}
+
+// limiterFor returns the rate limiter of an upstream host, or nil when rate limiting is disabled
+func (c *Client) limiterFor(host string) *Limiter {
+	c.limitersMu.Lock()
+	defer c.limitersMu.Unlock()
+
+	limiter, ok := c.limiters[host]
+	if !ok {
+		limiter = NewLimiter(c.config.RateLimit)
+		c.limiters[host] = limiter
+	}
+	return limiter
+}
+
+// ProxyRequest fetches path from the upstream, or from the response cache
+// when it is enabled, retrying transient failures according to the retry
+// policy. header holds the caller's request headers, of which the header
+// policy's Forward list is sent upstream. Concurrent identical calls share one
+// upstream request when coalescing is enabled. The returned metrics describe
+// the final outcome; when more than one attempt was made, Attempts holds each of them.
+// Cancelling ctx aborts the upstream call, queueing and retry waits.
+func (c *Client) ProxyRequest(ctx context.Context, path string, header http.Header) (*RequestMetrics, error) {
+	forwarded := c.config.Headers.requestHeader(header)
+	if c.cache == nil {
+		return c.coalescedFetch(ctx, path, forwarded)
+	}
+	if isConditional(forwarded) {
+		// The client validates its own copy, let the upstream answer
+		metrics, err := c.coalescedFetch(ctx, path, forwarded)
+		metrics.CacheStatus = models.CacheMiss
+		return metrics, err
+	}
+	return c.cachedRequest(ctx, path, forwarded)
+}
+
+// fetch requests path from the upstream with the retry policy, adding header
+// to every attempt. The policy's Deadline bounds the running attempt too, a
+// call that outlives it ends like one past a deadline set by the caller.
+func (c *Client) fetch(ctx context.Context, path string, header http.Header) (*RequestMetrics, error) {
+	policy := c.config.Retry
+	startTime := time.Now()
+
+	callerCtx := ctx
+	if policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Deadline)
+		defer cancel()
+	}
+
+	var attempts []*RequestMetrics
+	var metrics *RequestMetrics
+	var err error
+	for attempt := 1; ; attempt++ {
+		metrics, err = c.attempt(ctx, path, header, nil)
+		metrics.Attempt = attempt
+		attempts = append(attempts, metrics)
+		if metrics.Cancelled && callerCtx.Err() == nil {
+			err = fmt.Errorf("retry deadline of %s exceeded: %w", policy.Deadline, err)
+			break
+		}
+
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(metrics, err) {
+			break
+		}
+
+		delay := policy.backoff(attempt)
+		if metrics.RetryAfter > delay {
+			delay = metrics.RetryAfter
+		}
+		if policy.Deadline > 0 && time.Since(startTime)+delay > policy.Deadline {
+			break
+		}
+		if ctxErr := sleep(ctx, delay); ctxErr != nil {
+			metrics.Cancelled = true
+			err = fmt.Errorf("request cancelled while waiting to retry: %w", ctxErr)
+			break
+		}
+	}
+
+	if len(attempts) == 1 {
+		return metrics, err
+	}
+
+	// Summarize the attempts in a copy of the final one: the caller waited
+	// for all of them, minus any rate limit queueing
+	final := *metrics
+	final.Attempts = attempts
+	final.QueueWaitMs = 0
+	final.BreakerEvents = nil
+	for _, a := range attempts {
+		final.QueueWaitMs += a.QueueWaitMs
+		final.BreakerEvents = append(final.BreakerEvents, a.BreakerEvents...)
 	}
+	final.ResponseTimeMs = time.Since(startTime).Milliseconds() - final.QueueWaitMs
+	return &final, err
 }
 
-func (c *Client) ProxyRequest(path string) (*RequestMetrics, error) {
+// attempt makes a single upstream request. With a non-nil stream the
+// response is copied there instead of being buffered in ResponseBody.
+func (c *Client) attempt(ctx context.Context, path string, header http.Header, stream http.ResponseWriter) (*RequestMetrics, error) {
 	metrics := &RequestMetrics{
 		Method: "GET",
 		Path:   path,
 	}
 
-	url := c.baseURL + path
-	startTime := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		metrics.Error = err
+		return metrics, fmt.Errorf("failed to build request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
 
-	resp, err := c.httpClient.Get(url)
-	elapsed := time.Since(startTime)
-	metrics.ResponseTimeMs = elapsed.Milliseconds()
+	var brk *breaker
+	var probe probeToken
+	if c.breakers != nil {
+		brk = c.breakers.get(breakerKey(req.URL.Host, path, c.config.Breaker.PerEndpoint))
+		token, event, err := brk.allow(time.Now())
+		probe = token
+		if event != nil {
+			metrics.BreakerEvents = append(metrics.BreakerEvents, *event)
+		}
+		if err != nil {
+			metrics.CircuitOpen = true
+			metrics.Error = err
+			return metrics, err
+		}
+	}
+
+	limiter := c.limiterFor(req.URL.Host)
+	if limiter != nil {
+		wait, err := limiter.Reserve()
+		if err != nil {
+			if brk != nil {
+				brk.release(probe)
+			}
+			metrics.Throttle = models.ThrottleRejected
+			metrics.Error = err
+			return metrics, err
+		}
+		if wait > 0 {
+			metrics.Throttle = models.ThrottleQueued
+			metrics.QueueWaitMs = wait.Milliseconds()
+			if err := sleep(ctx, wait); err != nil {
+				limiter.Cancel()
+				if brk != nil {
+					brk.release(probe)
+				}
+				metrics.Cancelled = true
+				metrics.Error = err
+				return metrics, fmt.Errorf("request cancelled while queued: %w", err)
+			}
+		}
+	}
 
+	if brk != nil {
+		// Server errors and transport failures count against the breaker,
+		// calls the caller gave up on say nothing about the upstream
+		defer func() {
+			if metrics.Cancelled {
+				brk.release(probe)
+				return
+			}
+			failed := metrics.transportErr || metrics.ResponseStatus >= 500
+			if event := brk.record(probe, failed, time.Now()); event != nil {
+				metrics.BreakerEvents = append(metrics.BreakerEvents, *event)
+			}
+		}()
+	}
+
+	startTime := time.Now()
+	trace := newTracer(startTime)
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()))
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		endTime := time.Now()
+		metrics.ResponseTimeMs = endTime.Sub(startTime).Milliseconds()
+		metrics.Timing = trace.timing(endTime)
 		metrics.Error = err
 		metrics.ResponseStatus = 0
+		if ctx.Err() != nil {
+			metrics.Cancelled = true
+			return metrics, fmt.Errorf("request cancelled: %w", err)
+		}
+		metrics.transportErr = true
 		return metrics, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	metrics.ResponseStatus = resp.StatusCode
+	metrics.ContentType = resp.Header.Get("Content-Type")
+	metrics.Headers = recordHeaders(resp.Header)
+	metrics.header = resp.Header
+	metrics.ResponseHeader = c.config.Headers.responseHeader(resp.Header)
+
+	// Honor upstream back-pressure so queued requests don't hit the same wall
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			metrics.RetryAfter = retryAfter
+			if limiter != nil {
+				limiter.BlockFor(retryAfter)
+			}
+		}
+	}
+
+	if stream != nil {
+		return c.streamBody(metrics, resp, stream, trace, startTime)
+	}
 
 	body, err := io.ReadAll(resp.Body)
+	endTime := time.Now()
+	// Wall time includes reading the body, Timing splits it into phases
+	metrics.ResponseTimeMs = endTime.Sub(startTime).Milliseconds()
+	metrics.Timing = trace.timing(endTime)
+	metrics.ResponseBytes = int64(len(body))
 	if err != nil {
 		metrics.Error = err
+		if ctx.Err() != nil {
+			metrics.Cancelled = true
+			return metrics, fmt.Errorf("request cancelled: %w", err)
+		}
+		metrics.transportErr = true
 		return metrics, fmt.Errorf("failed to read response body: %w", err)
 	}
 
@@ -83,3 +354,26 @@ func (c *Client) ProxyRequest(path string) (*RequestMetrics, error) {
 
 	return metrics, nil
 }
+
+// sleep waits for d, returning early with the context error when ctx ends first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recordHeaders picks the RecordedHeaders present in an upstream response
+func recordHeaders(header http.Header) map[string]string {
+	recorded := make(map[string]string)
+	for _, name := range RecordedHeaders {
+		if value := header.Get(name); value != "" {
+			recorded[name] = value
+		}
+	}
+	return recorded
+}
@@ -0,0 +1,243 @@
+package jikan
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"treblle_project/internal/models"
+)
+
+// newTestClient points a Client at a local test server
+func newTestClient(server *httptest.Server) *Client {
+	client := NewClient()
+	client.baseURL = server.URL
+	return client
+}
+
+// Test 1: Response metadata and timing breakdown are captured
+func TestClient_ProxyRequestCapturesMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("X-Request-Fingerprint", "abc123")
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"data":{"mal_id":1}}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	metrics, err := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if metrics.ResponseStatus != 200 {
+		t.Errorf("Expected status 200, got %d", metrics.ResponseStatus)
+	}
+	if metrics.ResponseBytes != int64(len(`{"data":{"mal_id":1}}`)) {
+		t.Errorf("Unexpected response size %d", metrics.ResponseBytes)
+	}
+	if metrics.ContentType != "application/json" {
+		t.Errorf("Expected content type application/json, got %s", metrics.ContentType)
+	}
+	if metrics.Headers["X-Request-Fingerprint"] != "abc123" || metrics.Headers["Cache-Control"] != "max-age=60" {
+		t.Errorf("Expected recorded headers, got %v", metrics.Headers)
+	}
+	if _, ok := metrics.Headers["Age"]; ok {
+		t.Error("Expected absent headers not to be recorded")
+	}
+	if metrics.Timing.TTFBMs < 20 {
+		t.Errorf("Expected TTFB to include server processing time, got %dms", metrics.Timing.TTFBMs)
+	}
+	if metrics.Timing.ConnReused {
+		t.Error("Expected first request to open a new connection")
+	}
+
+	// A second request reuses the keep-alive connection
+	metrics, err = client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !metrics.Timing.ConnReused {
+		t.Error("Expected second request to reuse the connection")
+	}
+	if metrics.Timing.ConnectMs != 0 {
+		t.Errorf("Expected no connect time on a reused connection, got %dms", metrics.Timing.ConnectMs)
+	}
+}
+
+// Test 2: An upstream 429 with Retry-After blocks further requests
+func TestClient_HonorsUpstreamRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	metrics, _ := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if metrics.ResponseStatus != http.StatusTooManyRequests {
+		t.Fatalf("Expected upstream 429, got %d", metrics.ResponseStatus)
+	}
+
+	metrics, err := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	var throttleErr *ThrottleError
+	if !errors.As(err, &throttleErr) {
+		t.Fatalf("Expected the follow-up request to be throttled, got %v", err)
+	}
+	if metrics.Throttle != models.ThrottleRejected {
+		t.Errorf("Expected throttle outcome %q, got %q", models.ThrottleRejected, metrics.Throttle)
+	}
+	if throttleErr.RetryAfter < 29*time.Second {
+		t.Errorf("Expected Retry-After of about 30s, got %s", throttleErr.RetryAfter)
+	}
+}
+
+// Test 3: Transient upstream failures are retried and every attempt is reported
+func TestClient_RetriesTransientFailures(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data":{"mal_id":1}}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.config.Retry.BaseDelay = time.Millisecond
+	client.config.RateLimit.Enabled = false
+
+	metrics, err := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if err != nil {
+		t.Fatalf("Expected eventual success, got %v", err)
+	}
+	if metrics.ResponseStatus != 200 {
+		t.Errorf("Expected final status 200, got %d", metrics.ResponseStatus)
+	}
+	if len(metrics.Attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(metrics.Attempts))
+	}
+	for i, attempt := range metrics.Attempts {
+		if attempt.Attempt != i+1 {
+			t.Errorf("Expected attempt number %d, got %d", i+1, attempt.Attempt)
+		}
+	}
+	if metrics.Attempts[0].ResponseStatus != 503 {
+		t.Errorf("Expected first attempt to record the 503, got %d", metrics.Attempts[0].ResponseStatus)
+	}
+}
+
+// Test 4: Non-retryable statuses are returned after a single attempt
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	metrics, _ := client.ProxyRequest(context.Background(), "/anime/999999", nil)
+	if calls != 1 {
+		t.Errorf("Expected a single upstream call for 404, got %d", calls)
+	}
+	if metrics.Attempts != nil {
+		t.Errorf("Expected no attempt list for a single attempt, got %d", len(metrics.Attempts))
+	}
+}
+
+// Test 5: Retries stop at the overall deadline
+func TestClient_RetryDeadline(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.config.RateLimit.Enabled = false
+	client.config.Retry = RetryPolicy{
+		MaxAttempts:       10,
+		RetryableStatuses: []int{502},
+		BaseDelay:         time.Second,
+		MaxDelay:          time.Second,
+		Deadline:          10 * time.Millisecond,
+	}
+
+	metrics, _ := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if calls != 1 {
+		t.Errorf("Expected the deadline to prevent any retry, got %d calls", calls)
+	}
+	if metrics.ResponseStatus != 502 {
+		t.Errorf("Expected status 502, got %d", metrics.ResponseStatus)
+	}
+}
+
+// Test 6: Repeated server errors open the breaker and later calls are short-circuited
+func TestClient_CircuitBreakerShortCircuits(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.config.RateLimit.Enabled = false
+	client.config.Retry.MaxAttempts = 1
+	client.config.Breaker = BreakerConfig{Enabled: true, FailureThreshold: 2, Cooldown: time.Minute}
+	client.breakers = newBreakerSet(client.config.Breaker)
+
+	client.ProxyRequest(context.Background(), "/anime/1", nil)
+	metrics, _ := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if len(metrics.BreakerEvents) != 1 || metrics.BreakerEvents[0].To != BreakerOpen {
+		t.Fatalf("Expected the second failure to open the breaker, got %+v", metrics.BreakerEvents)
+	}
+
+	metrics, err := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if !errors.Is(err, ErrCircuitOpen) || !metrics.CircuitOpen {
+		t.Fatalf("Expected the third call to be short-circuited, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", calls)
+	}
+
+	statuses := client.BreakerStatus()
+	if len(statuses) != 1 || statuses[0].State != BreakerOpen {
+		t.Errorf("Expected one open breaker in status, got %+v", statuses)
+	}
+}
+
+// Test 7: The deadline also cuts short an attempt that is still running
+func TestClient_RetryDeadlineBoundsAttempt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.config.RateLimit.Enabled = false
+	client.config.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Deadline: 150 * time.Millisecond}
+
+	start := time.Now()
+	metrics, err := client.ProxyRequest(context.Background(), "/anime/1", nil)
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond+100*time.Millisecond {
+		t.Errorf("Expected the call to end at the 150ms deadline, took %s", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !metrics.Cancelled {
+		t.Errorf("Expected a deadline exceeded outcome, got %v (cancelled %v)", err, metrics.Cancelled)
+	}
+}
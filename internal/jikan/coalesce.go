@@ -0,0 +1,184 @@
+package jikan
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"treblle_project/internal/models"
+)
+
+// OrphanedCallReporter is implemented by clients whose shared upstream calls
+// can outlive the request that started them. fn gets each call that completed
+// after its leader gave up while followers still waited on it, which no
+// request logs otherwise. It must be set before any request is made.
+type OrphanedCallReporter interface {
+	OnOrphanedCall(fn func(metrics *RequestMetrics, err error))
+}
+
+// Ensure Client implements OrphanedCallReporter
+var _ OrphanedCallReporter = (*Client)(nil)
+
+// OnOrphanedCall sets the function orphaned coalesced calls are passed to
+func (c *Client) OnOrphanedCall(fn func(metrics *RequestMetrics, err error)) {
+	if c.flights != nil {
+		c.flights.orphaned = fn
+	}
+}
+
+// flight is an upstream call in progress that identical requests wait on
+type flight struct {
+	done       chan struct{}
+	metrics    *RequestMetrics
+	err        error
+	followers  int                // Callers that joined after the leader
+	waiters    int                // Callers still waiting, the call is cancelled when none are left
+	leaderGone bool               // The caller that started the call gave up waiting
+	finished   bool               // metrics and err are set
+	cancel     context.CancelFunc // Cancels the call's own context
+}
+
+// flightGroup deduplicates concurrent identical upstream calls, singleflight style
+type flightGroup struct {
+	mu       sync.Mutex
+	flights  map[string]*flight
+	orphaned func(metrics *RequestMetrics, err error) // Gets the calls no caller is left to log, may be nil
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+// do runs fn once per key at a time. Callers arriving while it runs wait for
+// its result instead and get leader == false. fn runs with a context of its
+// own, so one caller going away does not fail the others; it is cancelled once
+// every caller is gone. A caller whose ctx ends first gets nil metrics and the
+// context error. When that caller is the leader and followers are still
+// served, the result is passed to g.orphaned instead, so the call is logged
+// once. The shared result must not be modified.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (*RequestMetrics, error)) (metrics *RequestMetrics, err error, leader bool) {
+	g.mu.Lock()
+	f, ok := g.flights[key]
+	if ok {
+		f.followers++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+		go func() {
+			f.metrics, f.err = fn(callCtx)
+			g.mu.Lock()
+			f.finished = true
+			orphaned := f.leaderGone && f.waiters > 0
+			g.mu.Unlock()
+			g.forget(key, f)
+			cancel()
+			close(f.done)
+			if orphaned && g.orphaned != nil {
+				g.orphaned(f.metrics, f.err)
+			}
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.metrics, f.err, !ok
+	case <-ctx.Done():
+		g.mu.Lock()
+		if f.finished {
+			// Too late to give up, the result is this caller's to log
+			g.mu.Unlock()
+			<-f.done
+			return f.metrics, f.err, !ok
+		}
+		f.waiters--
+		abandoned := f.waiters == 0
+		f.leaderGone = f.leaderGone || !ok
+		g.mu.Unlock()
+		if abandoned {
+			g.forget(key, f) // Later callers start a fresh call
+			f.cancel()
+		}
+		return nil, ctx.Err(), !ok
+	}
+}
+
+// forget removes f from the group unless it was already replaced
+func (g *flightGroup) forget(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// followers returns how many callers joined the in-flight call for key after
+// its leader, whether or not they are still waiting
+func (g *flightGroup) followers(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.flights[key]; ok {
+		return f.followers
+	}
+	return 0
+}
+
+// flightKey identifies identical upstream calls: same path and same request headers
+func flightKey(path string, header http.Header) string {
+	return path + headerKey(header)
+}
+
+// newUpstreamCallID returns a random identifier shared by every request served by one upstream call
+func newUpstreamCallID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// coalescedFetch fetches path, sharing one upstream call between concurrent identical requests.
+// Followers get the leader's response with their own wait as response time, and
+// without the leader's attempts and breaker events, which are logged once by the leader,
+// or reported as an orphaned call when the leader gave up.
+func (c *Client) coalescedFetch(ctx context.Context, path string, header http.Header) (*RequestMetrics, error) {
+	fetch := func(ctx context.Context) (*RequestMetrics, error) {
+		metrics, err := c.fetch(ctx, path, header)
+		metrics.UpstreamCallID = newUpstreamCallID()
+		return metrics, err
+	}
+	if c.flights == nil {
+		return fetch(ctx)
+	}
+
+	startTime := time.Now()
+	shared, err, leader := c.flights.do(ctx, flightKey(path, header), fetch)
+	if shared == nil {
+		// Gave up waiting, the call itself may carry on for the others
+		return &RequestMetrics{
+			Method:         "GET",
+			Path:           path,
+			ResponseTimeMs: time.Since(startTime).Milliseconds(),
+			Coalesced:      !leader,
+			Cancelled:      true,
+			Error:          err,
+		}, fmt.Errorf("request cancelled: %w", err)
+	}
+
+	// Every caller gets its own copy, the shared result stays untouched
+	metrics := *shared
+	if !leader {
+		metrics.Coalesced = true
+		metrics.ResponseTimeMs = time.Since(startTime).Milliseconds()
+		metrics.QueueWaitMs = 0
+		if metrics.Throttle == models.ThrottleQueued {
+			metrics.Throttle = models.ThrottleNone // Only the leader waited for a token
+		}
+		metrics.Attempts = nil
+		metrics.BreakerEvents = nil
+	}
+	return &metrics, err
+}
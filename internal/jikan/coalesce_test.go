@@ -0,0 +1,155 @@
+package jikan
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test 1: Concurrent identical requests share one upstream call
+func TestClient_CoalescesConcurrentRequests(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.flights = newFlightGroup()
+
+	const followers = 4
+	results := make([]*RequestMetrics, followers+1)
+	var wg sync.WaitGroup
+	request := func(i int) {
+		defer wg.Done()
+		metrics, err := client.ProxyRequest(context.Background(), "/top/anime", nil)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		results[i] = metrics
+	}
+
+	wg.Add(1)
+	go request(0)
+	waitFor(t, func() bool { return calls.Load() == 1 })
+	for i := 1; i <= followers; i++ {
+		wg.Add(1)
+		go request(i)
+	}
+	waitFor(t, func() bool { return client.flights.followers("/top/anime") == followers })
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls.Load())
+	}
+	coalesced := 0
+	for _, metrics := range results {
+		if metrics.UpstreamCallID == "" || metrics.UpstreamCallID != results[0].UpstreamCallID {
+			t.Errorf("Expected every request to share the upstream call id, got %q and %q", metrics.UpstreamCallID, results[0].UpstreamCallID)
+		}
+		if string(metrics.ResponseBody) != `{"data":[]}` {
+			t.Errorf("Unexpected body %q", metrics.ResponseBody)
+		}
+		if metrics.Coalesced {
+			coalesced++
+		}
+	}
+	if results[0].Coalesced || coalesced != followers {
+		t.Errorf("Expected the first request to lead and %d followers, got %d", followers, coalesced)
+	}
+
+	// Once the call finished, the next request goes upstream again
+	metrics, _ := client.ProxyRequest(context.Background(), "/top/anime", nil)
+	if metrics.Coalesced || metrics.UpstreamCallID == results[0].UpstreamCallID {
+		t.Error("Expected a new upstream call after the in-flight one completed")
+	}
+}
+
+// Test 2: Conditional requests only coalesce with identical validators
+func TestFlightKey(t *testing.T) {
+	plain := flightKey("/anime/1", nil)
+	conditional := flightKey("/anime/1", http.Header{"If-None-Match": {`"v1"`}})
+
+	if plain == conditional {
+		t.Error("Expected conditional and plain requests to use different keys")
+	}
+	if conditional != flightKey("/anime/1", http.Header{"If-None-Match": {`"v1"`}}) {
+		t.Error("Expected identical conditional requests to share a key")
+	}
+}
+
+// Test 3: A call whose leader gave up is reported once it completes for its followers
+func TestClient_CoalescedCallOrphaned(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.flights = newFlightGroup()
+	orphans := make(chan *RequestMetrics, 2)
+	client.OnOrphanedCall(func(metrics *RequestMetrics, err error) {
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		orphans <- metrics
+	})
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan *RequestMetrics)
+	go func() {
+		metrics, _ := client.ProxyRequest(leaderCtx, "/top/anime", nil)
+		leaderDone <- metrics
+	}()
+	waitFor(t, func() bool { return calls.Load() == 1 })
+
+	followerDone := make(chan *RequestMetrics)
+	go func() {
+		metrics, _ := client.ProxyRequest(context.Background(), "/top/anime", nil)
+		followerDone <- metrics
+	}()
+	waitFor(t, func() bool { return client.flights.followers("/top/anime") == 1 })
+
+	cancelLeader()
+	if leader := <-leaderDone; !leader.Cancelled || leader.Coalesced {
+		t.Errorf("Expected the leader to be cancelled, got %+v", leader)
+	}
+	close(release)
+	follower := <-followerDone
+
+	select {
+	case orphan := <-orphans:
+		if orphan.Coalesced || orphan.ResponseStatus != http.StatusOK || orphan.UpstreamCallID != follower.UpstreamCallID {
+			t.Errorf("Expected the upstream call shared with the follower, got %+v", orphan)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the orphaned call to be reported")
+	}
+	if len(orphans) != 0 {
+		t.Error("Expected the call to be reported once")
+	}
+}
+
+// waitFor polls cond until it holds or a second passes
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
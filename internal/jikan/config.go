@@ -0,0 +1,240 @@
+package jikan
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config controls how the client talks to the upstream API
+type Config struct {
+	BaseURL   string
+	Timeout   time.Duration
+	RateLimit RateLimitConfig
+	Retry     RetryPolicy
+	Breaker   BreakerConfig
+	Cache     CacheConfig
+	Coalesce  bool // Share one upstream call between concurrent identical requests
+	Stream    StreamConfig
+	Headers   HeaderPolicy
+}
+
+// RateLimitConfig configures the client-side token buckets. Jikan allows
+// roughly 3 requests per second and 60 per minute.
+type RateLimitConfig struct {
+	Enabled   bool
+	PerSecond float64       // Sustained requests per second
+	Burst     int           // Requests allowed back to back before the per-second rate applies
+	PerMinute float64       // Requests per rolling minute, 0 disables the minute bucket
+	MaxWait   time.Duration // How long a request may queue for a token, 0 rejects immediately
+}
+
+// DefaultConfig matches Jikan's published limits
+func DefaultConfig() Config {
+	return Config{
+		BaseURL: BaseURL,
+		Timeout: 10 * time.Second,
+		RateLimit: RateLimitConfig{
+			Enabled:   true,
+			PerSecond: 3,
+			Burst:     3,
+			PerMinute: 60,
+			MaxWait:   2 * time.Second,
+		},
+		Retry: RetryPolicy{
+			MaxAttempts:       3,
+			RetryableStatuses: []int{429, 502, 503, 504},
+			BaseDelay:         200 * time.Millisecond,
+			MaxDelay:          2 * time.Second,
+			Deadline:          15 * time.Second,
+		},
+		Breaker: BreakerConfig{
+			Enabled:            true,
+			FailureThreshold:   5,
+			ErrorRateThreshold: 0.5,
+			MinRequests:        10,
+			Window:             time.Minute,
+			Cooldown:           30 * time.Second,
+		},
+		Coalesce: true,
+		Headers: HeaderPolicy{
+			Deny:    []string{"Set-Cookie", "Alt-Svc"},
+			Forward: []string{"Accept", "Accept-Language", "If-None-Match", "If-Modified-Since"},
+		},
+		Stream: StreamConfig{
+			Enabled:      false,
+			ValidateJSON: true,
+			BufferSize:   32 * 1024,
+		},
+		Cache: CacheConfig{
+			Enabled:        false,
+			MaxBytes:       64 * 1024 * 1024,
+			SQLiteMaxBytes: 512 * 1024 * 1024,
+			DefaultTTL:     5 * time.Minute,
+			Rules: []CacheRule{
+				{Pattern: "/random/**", TTL: 0}, // A different entry every call
+				{Pattern: "/top/**", TTL: 10 * time.Minute},
+			},
+			HonorCacheControl:    true,
+			StaleWhileRevalidate: 30 * time.Second,
+			StaleIfError:         10 * time.Minute,
+		},
+	}
+}
+
+// ConfigFromEnv starts from DefaultConfig and applies JIKAN_* environment overrides
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+
+	if v := os.Getenv("JIKAN_BASE_URL"); v != "" {
+		cfg.BaseURL = v
+	}
+	envDuration("JIKAN_TIMEOUT", &cfg.Timeout)
+
+	envBool("JIKAN_RATE_LIMIT_ENABLED", &cfg.RateLimit.Enabled)
+	envFloat("JIKAN_RATE_LIMIT_PER_SECOND", &cfg.RateLimit.PerSecond)
+	envInt("JIKAN_RATE_LIMIT_BURST", &cfg.RateLimit.Burst)
+	envFloat("JIKAN_RATE_LIMIT_PER_MINUTE", &cfg.RateLimit.PerMinute)
+	envDuration("JIKAN_RATE_LIMIT_MAX_WAIT", &cfg.RateLimit.MaxWait)
+
+	envInt("JIKAN_RETRY_MAX_ATTEMPTS", &cfg.Retry.MaxAttempts)
+	envIntList("JIKAN_RETRY_STATUSES", &cfg.Retry.RetryableStatuses)
+	envDuration("JIKAN_RETRY_BASE_DELAY", &cfg.Retry.BaseDelay)
+	envDuration("JIKAN_RETRY_MAX_DELAY", &cfg.Retry.MaxDelay)
+	envDuration("JIKAN_RETRY_DEADLINE", &cfg.Retry.Deadline)
+
+	envBool("JIKAN_BREAKER_ENABLED", &cfg.Breaker.Enabled)
+	envBool("JIKAN_BREAKER_PER_ENDPOINT", &cfg.Breaker.PerEndpoint)
+	envInt("JIKAN_BREAKER_FAILURE_THRESHOLD", &cfg.Breaker.FailureThreshold)
+	envFloat("JIKAN_BREAKER_ERROR_RATE", &cfg.Breaker.ErrorRateThreshold)
+	envInt("JIKAN_BREAKER_MIN_REQUESTS", &cfg.Breaker.MinRequests)
+	envDuration("JIKAN_BREAKER_WINDOW", &cfg.Breaker.Window)
+	envDuration("JIKAN_BREAKER_COOLDOWN", &cfg.Breaker.Cooldown)
+
+	envBool("JIKAN_COALESCE_ENABLED", &cfg.Coalesce)
+
+	envList("JIKAN_HEADERS_ALLOW", &cfg.Headers.Allow)
+	envList("JIKAN_HEADERS_DENY", &cfg.Headers.Deny)
+	envList("JIKAN_HEADERS_FORWARD", &cfg.Headers.Forward)
+	if v, ok := os.LookupEnv("JIKAN_USER_AGENT"); ok {
+		cfg.Headers.UserAgent = v
+	}
+
+	envBool("JIKAN_STREAM_ENABLED", &cfg.Stream.Enabled)
+	envBool("JIKAN_STREAM_VALIDATE_JSON", &cfg.Stream.ValidateJSON)
+	envInt("JIKAN_STREAM_BUFFER_SIZE", &cfg.Stream.BufferSize)
+
+	envBool("JIKAN_CACHE_ENABLED", &cfg.Cache.Enabled)
+	envInt64("JIKAN_CACHE_MAX_BYTES", &cfg.Cache.MaxBytes)
+	envBool("JIKAN_CACHE_SQLITE", &cfg.Cache.SQLite)
+	envInt64("JIKAN_CACHE_SQLITE_MAX_BYTES", &cfg.Cache.SQLiteMaxBytes)
+	envDuration("JIKAN_CACHE_DEFAULT_TTL", &cfg.Cache.DefaultTTL)
+	envCacheRules("JIKAN_CACHE_TTLS", &cfg.Cache.Rules)
+	envBool("JIKAN_CACHE_HONOR_CACHE_CONTROL", &cfg.Cache.HonorCacheControl)
+	envDuration("JIKAN_CACHE_STALE_WHILE_REVALIDATE", &cfg.Cache.StaleWhileRevalidate)
+	envDuration("JIKAN_CACHE_STALE_IF_ERROR", &cfg.Cache.StaleIfError)
+
+	return cfg
+}
+
+func envDuration(name string, dst *time.Duration) {
+	if v := os.Getenv(name); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("Ignoring invalid %s=%q: %v", name, v, err)
+			return
+		}
+		*dst = d
+	}
+}
+
+func envBool(name string, dst *bool) {
+	if v := os.Getenv(name); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("Ignoring invalid %s=%q: %v", name, v, err)
+			return
+		}
+		*dst = b
+	}
+}
+
+func envFloat(name string, dst *float64) {
+	if v := os.Getenv(name); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Printf("Ignoring invalid %s=%q: %v", name, v, err)
+			return
+		}
+		*dst = f
+	}
+}
+
+func envInt(name string, dst *int) {
+	if v := os.Getenv(name); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			log.Printf("Ignoring invalid %s=%q: %v", name, v, err)
+			return
+		}
+		*dst = i
+	}
+}
+
+func envInt64(name string, dst *int64) {
+	if v := os.Getenv(name); v != "" {
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Printf("Ignoring invalid %s=%q: %v", name, v, err)
+			return
+		}
+		*dst = i
+	}
+}
+
+// envCacheRules parses comma separated pattern=ttl pairs, e.g. "/top/**=10m,/anime/*=1h"
+func envCacheRules(name string, dst *[]CacheRule) {
+	if v := os.Getenv(name); v != "" {
+		var rules []CacheRule
+		for _, part := range strings.Split(v, ",") {
+			pattern, ttl, ok := strings.Cut(strings.TrimSpace(part), "=")
+			d, err := time.ParseDuration(ttl)
+			if !ok || err != nil {
+				log.Printf("Ignoring invalid %s=%q: expected pattern=duration pairs", name, v)
+				return
+			}
+			rules = append(rules, CacheRule{Pattern: pattern, TTL: d})
+		}
+		*dst = rules
+	}
+}
+
+// envList parses a comma separated list, an empty value clears it
+func envList(name string, dst *[]string) {
+	if v, ok := os.LookupEnv(name); ok {
+		values := []string{}
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+		*dst = values
+	}
+}
+
+func envIntList(name string, dst *[]int) {
+	if v := os.Getenv(name); v != "" {
+		var values []int
+		for _, part := range strings.Split(v, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				log.Printf("Ignoring invalid %s=%q: %v", name, v, err)
+				return
+			}
+			values = append(values, i)
+		}
+		*dst = values
+	}
+}
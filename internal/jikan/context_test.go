@@ -0,0 +1,106 @@
+package jikan
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test 1: Cancelling the context aborts the upstream call without retrying or tripping the breaker
+func TestClient_ContextCancellation(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.config.Breaker.FailureThreshold = 1
+	client.breakers = newBreakerSet(client.config.Breaker)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	metrics, err := client.ProxyRequest(ctx, "/anime/1", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a deadline error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Expected the upstream call to be aborted")
+	}
+	if !metrics.Cancelled {
+		t.Error("Expected the metrics to be marked cancelled")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected no retries, got %d calls", calls.Load())
+	}
+	for _, status := range client.BreakerStatus() {
+		if status.State != BreakerClosed {
+			t.Errorf("Expected cancelled calls not to open the breaker, got %s", status.State)
+		}
+	}
+}
+
+// Test 2: A coalesced call carries on when one caller leaves and stops when all do
+func TestFlightGroup_Cancellation(t *testing.T) {
+	group := newFlightGroup()
+	release := make(chan struct{})
+	var callCtx context.Context
+	started := make(chan struct{})
+	fn := func(ctx context.Context) (*RequestMetrics, error) {
+		callCtx = ctx
+		close(started)
+		select {
+		case <-release:
+			return &RequestMetrics{ResponseStatus: 200}, nil
+		case <-ctx.Done():
+			return &RequestMetrics{Cancelled: true}, ctx.Err()
+		}
+	}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan error)
+	go func() {
+		_, err, _ := group.do(leaderCtx, "/anime/1", fn)
+		leaderDone <- err
+	}()
+	<-started
+
+	followerDone := make(chan *RequestMetrics)
+	go func() {
+		metrics, _, _ := group.do(context.Background(), "/anime/1", fn)
+		followerDone <- metrics
+	}()
+	waitFor(t, func() bool { return group.followers("/anime/1") == 1 })
+
+	cancelLeader()
+	if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the leader to give up, got %v", err)
+	}
+	if callCtx.Err() != nil {
+		t.Fatal("Expected the call to carry on for the follower")
+	}
+
+	close(release)
+	if metrics := <-followerDone; metrics == nil || metrics.ResponseStatus != 200 {
+		t.Errorf("Expected the follower to get the response, got %+v", metrics)
+	}
+
+	// Once every caller is gone the call is cancelled
+	started = make(chan struct{})
+	release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go group.do(ctx, "/anime/2", fn)
+	<-started
+	cancel()
+	waitFor(t, func() bool { return callCtx.Err() != nil })
+}
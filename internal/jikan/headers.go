@@ -0,0 +1,127 @@
+package jikan
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// hopByHopHeaders only apply to a single connection and are never forwarded (RFC 9110, section 7.6.1)
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// HeaderPolicy decides which headers cross the proxy in each direction
+type HeaderPolicy struct {
+	Allow     []string // Upstream response headers passed to the client, empty passes all but Deny
+	Deny      []string // Upstream response headers never passed to the client
+	Forward   []string // Client request headers forwarded upstream
+	UserAgent string   // User-Agent sent upstream, empty keeps the client's default
+}
+
+// responseHeader selects the upstream response headers passed on to the client
+func (p HeaderPolicy) responseHeader(upstream http.Header) http.Header {
+	header := upstream.Clone()
+	if header == nil {
+		return http.Header{}
+	}
+
+	// Headers listed in Connection are hop-by-hop too
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			header.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+	// The body may be re-sent or replaced (cache, 304 revalidation), the server computes the length
+	header.Del("Content-Length")
+
+	if len(p.Allow) > 0 {
+		allowed := http.Header{}
+		for _, name := range p.Allow {
+			if values := header.Values(name); len(values) > 0 {
+				allowed[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		header = allowed
+	}
+	for _, name := range p.Deny {
+		header.Del(name)
+	}
+	return header
+}
+
+// requestHeader selects the client request headers sent upstream
+func (p HeaderPolicy) requestHeader(client http.Header) http.Header {
+	header := http.Header{}
+	for _, name := range p.Forward {
+		if values := client.Values(name); len(values) > 0 {
+			header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	if p.UserAgent != "" {
+		header.Set("User-Agent", p.UserAgent)
+	}
+	return header
+}
+
+// variesPerClient reports whether an upstream response listing Vary fields
+// may differ between clients: Vary is "*" or names a forwarded header.
+// Other request headers are the same for every call the proxy makes.
+func (p HeaderPolicy) variesPerClient(upstream http.Header) bool {
+	for _, value := range upstream.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return true
+			}
+			for _, forwarded := range p.Forward {
+				if strings.EqualFold(name, forwarded) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// mergeHeaders combines headers, later ones winning
+func mergeHeaders(headers ...http.Header) http.Header {
+	merged := http.Header{}
+	for _, header := range headers {
+		for name, values := range header {
+			merged[name] = values
+		}
+	}
+	return merged
+}
+
+// isConditional reports whether a request carries cache validators
+func isConditional(header http.Header) bool {
+	return header.Get("If-None-Match") != "" || header.Get("If-Modified-Since") != ""
+}
+
+// headerKey serializes headers in a stable order
+func headerKey(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString("\n" + name + ": " + strings.Join(header[name], ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package jikan
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test 1: Hop-by-hop headers are stripped and allow/deny lists applied
+func TestHeaderPolicy_ResponseHeader(t *testing.T) {
+	upstream := http.Header{
+		"Content-Type":      {"application/json"},
+		"Cache-Control":     {"max-age=60"},
+		"Connection":        {"keep-alive, X-Internal"},
+		"X-Internal":        {"secret"},
+		"Keep-Alive":        {"timeout=5"},
+		"Transfer-Encoding": {"chunked"},
+		"Content-Length":    {"42"},
+		"Set-Cookie":        {"session=1"},
+	}
+
+	header := DefaultConfig().Headers.responseHeader(upstream)
+	for _, name := range []string{"Connection", "X-Internal", "Keep-Alive", "Transfer-Encoding", "Content-Length", "Set-Cookie"} {
+		if header.Get(name) != "" {
+			t.Errorf("Expected %s to be stripped", name)
+		}
+	}
+	if header.Get("Cache-Control") != "max-age=60" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected end-to-end headers to pass, got %v", header)
+	}
+
+	allowOnly := HeaderPolicy{Allow: []string{"content-type"}}.responseHeader(upstream)
+	if len(allowOnly) != 1 || allowOnly.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected only Content-Type to be allowed, got %v", allowOnly)
+	}
+}
+
+// Test 2: Selected client headers and the User-Agent override are sent upstream
+func TestClient_ForwardsRequestHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Header().Set("X-RateLimit-Remaining", "59")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.config.Headers.UserAgent = "api-monitor/1.0"
+
+	metrics, err := client.ProxyRequest(context.Background(), "/anime/1", http.Header{
+		"Accept":        {"application/json"},
+		"Authorization": {"Bearer token"},
+		"User-Agent":    {"curl/8.0"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if received.Get("Accept") != "application/json" {
+		t.Error("Expected Accept to be forwarded")
+	}
+	if received.Get("Authorization") != "" {
+		t.Error("Expected headers outside the forward list not to be sent")
+	}
+	if received.Get("User-Agent") != "api-monitor/1.0" {
+		t.Errorf("Expected the User-Agent override, got %q", received.Get("User-Agent"))
+	}
+	if metrics.ResponseHeader.Get("X-RateLimit-Remaining") != "59" {
+		t.Errorf("Expected upstream headers to be kept for the client, got %v", metrics.ResponseHeader)
+	}
+}
@@ -0,0 +1,165 @@
+package jikan
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned (wrapped in a *ThrottleError) when a request
+// would have to wait longer than RateLimitConfig.MaxWait for a token
+var ErrRateLimited = errors.New("upstream rate limit exceeded")
+
+// ThrottleError tells the caller when the request may be retried
+type ThrottleError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// tokenBucket refills at rate tokens per second up to capacity. Tokens may go
+// negative: each reservation that has to wait borrows from the future.
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, capacity float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// wait reports how long until a token is available, without taking it
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take() {
+	b.tokens--
+}
+
+// give returns a token taken by take
+func (b *tokenBucket) give(now time.Time) {
+	b.refill(now)
+	b.tokens = math.Min(b.capacity, b.tokens+1)
+}
+
+// Limiter combines the per-second and per-minute buckets of one upstream and
+// any block imposed by the upstream through Retry-After
+type Limiter struct {
+	mu           sync.Mutex
+	buckets      []*tokenBucket
+	maxWait      time.Duration
+	blockedUntil time.Time
+	now          func() time.Time
+}
+
+// NewLimiter builds a limiter from cfg, or returns nil when rate limiting is disabled
+func NewLimiter(cfg RateLimitConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	l := &Limiter{maxWait: cfg.MaxWait, now: time.Now}
+	now := l.now()
+	if cfg.PerSecond > 0 {
+		burst := float64(cfg.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		l.buckets = append(l.buckets, newTokenBucket(cfg.PerSecond, burst, now))
+	}
+	if cfg.PerMinute > 0 {
+		l.buckets = append(l.buckets, newTokenBucket(cfg.PerMinute/60, cfg.PerMinute, now))
+	}
+	return l
+}
+
+// Reserve takes a token from every bucket and returns how long the caller
+// must wait before sending. If that exceeds MaxWait nothing is taken and a
+// *ThrottleError is returned instead.
+func (l *Limiter) Reserve() (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	if l.blockedUntil.After(now) {
+		wait = l.blockedUntil.Sub(now)
+	}
+	for _, b := range l.buckets {
+		if w := b.wait(now); w > wait {
+			wait = w
+		}
+	}
+
+	if wait > l.maxWait {
+		return 0, &ThrottleError{RetryAfter: wait}
+	}
+
+	for _, b := range l.buckets {
+		b.take()
+	}
+	return wait, nil
+}
+
+// Cancel gives back the tokens of a reservation the caller gave up on before
+// sending, so a cancelled wait doesn't delay the requests queued after it
+func (l *Limiter) Cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for _, b := range l.buckets {
+		b.give(now)
+	}
+}
+
+// BlockFor stops handing out tokens for d, used when the upstream answers with Retry-After
+func (l *Limiter) BlockFor(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := l.now().Add(d); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,147 @@
+package jikan
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestLimiter builds a limiter driven by a fake clock
+func newTestLimiter(cfg RateLimitConfig, clock *time.Time) *Limiter {
+	cfg.Enabled = true
+	l := NewLimiter(cfg)
+	l.now = func() time.Time { return *clock }
+	for _, b := range l.buckets {
+		b.last = *clock
+	}
+	return l
+}
+
+// Test 1: Burst is served immediately, then requests queue at the sustained rate
+func TestLimiter_BurstThenQueue(t *testing.T) {
+	clock := time.Unix(0, 0)
+	l := newTestLimiter(RateLimitConfig{PerSecond: 2, Burst: 2, MaxWait: time.Second}, &clock)
+
+	for i := 0; i < 2; i++ {
+		wait, err := l.Reserve()
+		if err != nil || wait != 0 {
+			t.Fatalf("Expected burst request %d to pass immediately, got wait=%s err=%v", i, wait, err)
+		}
+	}
+
+	wait, err := l.Reserve()
+	if err != nil {
+		t.Fatalf("Expected third request to queue, got %v", err)
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("Expected 500ms wait at 2 req/s, got %s", wait)
+	}
+}
+
+// Test 2: Requests that would wait longer than MaxWait are rejected without taking a token
+func TestLimiter_RejectsBeyondMaxWait(t *testing.T) {
+	clock := time.Unix(0, 0)
+	l := newTestLimiter(RateLimitConfig{PerSecond: 1, Burst: 1, MaxWait: 0}, &clock)
+
+	if _, err := l.Reserve(); err != nil {
+		t.Fatalf("Expected first request to pass, got %v", err)
+	}
+
+	_, err := l.Reserve()
+	var throttleErr *ThrottleError
+	if !errors.As(err, &throttleErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ThrottleError, got %v", err)
+	}
+	if throttleErr.RetryAfter != time.Second {
+		t.Errorf("Expected retry after 1s, got %s", throttleErr.RetryAfter)
+	}
+
+	// The rejected call did not borrow a token, so one second later a request passes
+	clock = clock.Add(time.Second)
+	if wait, err := l.Reserve(); err != nil || wait != 0 {
+		t.Errorf("Expected request to pass after refill, got wait=%s err=%v", wait, err)
+	}
+}
+
+// Test 3: The per-minute bucket limits sustained traffic
+func TestLimiter_PerMinuteBucket(t *testing.T) {
+	clock := time.Unix(0, 0)
+	l := newTestLimiter(RateLimitConfig{PerSecond: 100, Burst: 100, PerMinute: 3, MaxWait: 0}, &clock)
+
+	for i := 0; i < 3; i++ {
+		if _, err := l.Reserve(); err != nil {
+			t.Fatalf("Expected request %d to pass, got %v", i, err)
+		}
+	}
+	if _, err := l.Reserve(); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected per-minute limit to reject, got %v", err)
+	}
+}
+
+// Test 4: Upstream Retry-After blocks the limiter
+func TestLimiter_BlockFor(t *testing.T) {
+	clock := time.Unix(0, 0)
+	l := newTestLimiter(RateLimitConfig{PerSecond: 10, Burst: 10, MaxWait: 5 * time.Second}, &clock)
+
+	l.BlockFor(3 * time.Second)
+
+	wait, err := l.Reserve()
+	if err != nil {
+		t.Fatalf("Expected request to queue behind the block, got %v", err)
+	}
+	if wait != 3*time.Second {
+		t.Errorf("Expected 3s wait, got %s", wait)
+	}
+}
+
+// Test 5: Retry-After parsing accepts seconds and HTTP dates
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"120", 120 * time.Second, true},
+		{"Mon, 15 Jan 2024 10:00:30 GMT", 30 * time.Second, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; expected %s, %v", tt.value, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+// Test 6: A reservation cancelled while queued gives its tokens back
+func TestLimiter_Cancel(t *testing.T) {
+	clock := time.Unix(0, 0)
+	l := newTestLimiter(RateLimitConfig{PerSecond: 2, Burst: 1, PerMinute: 60, MaxWait: time.Second}, &clock)
+
+	if wait, err := l.Reserve(); err != nil || wait != 0 {
+		t.Fatalf("Expected first request to pass, got wait=%s err=%v", wait, err)
+	}
+	if wait, err := l.Reserve(); err != nil || wait != 500*time.Millisecond {
+		t.Fatalf("Expected second request to queue for 500ms, got wait=%s err=%v", wait, err)
+	}
+	l.Cancel()
+
+	// Without the refund the next request would queue behind the cancelled one for 1s
+	if wait, err := l.Reserve(); err != nil || wait != 500*time.Millisecond {
+		t.Errorf("Expected the cancelled token to be reused, got wait=%s err=%v", wait, err)
+	}
+
+	// Refunds never fill a bucket past its capacity
+	clock = clock.Add(time.Minute)
+	l.Cancel()
+	if _, err := l.Reserve(); err != nil {
+		t.Fatalf("Expected request to pass, got %v", err)
+	}
+	if wait, err := l.Reserve(); err != nil || wait != 500*time.Millisecond {
+		t.Errorf("Expected the burst to stay at 1, got wait=%s err=%v", wait, err)
+	}
+}
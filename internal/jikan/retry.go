@@ -0,0 +1,57 @@
+package jikan
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+	"treblle_project/internal/models"
+)
+
+// RetryPolicy decides whether and when a failed upstream call is attempted again.
+// Only idempotent methods are retried.
+type RetryPolicy struct {
+	MaxAttempts       int           // Total attempts including the first, 1 disables retries
+	RetryableStatuses []int         // Upstream statuses worth retrying, network errors always are
+	BaseDelay         time.Duration // Backoff before the second attempt, doubled for each following one
+	MaxDelay          time.Duration // Cap on a single backoff
+	Deadline          time.Duration // Overall budget for all attempts and backoffs, running attempts included, 0 means unbounded
+}
+
+func (p RetryPolicy) shouldRetry(metrics *RequestMetrics, err error) bool {
+	if metrics.Method != "GET" && metrics.Method != "HEAD" {
+		return false
+	}
+	// Rejected locally by the rate limiter or an open breaker, retrying would only be rejected again,
+	// and nobody is waiting for a cancelled request
+	if metrics.Throttle == models.ThrottleRejected || metrics.CircuitOpen || metrics.Cancelled {
+		return false
+	}
+	if err != nil && metrics.transportErr {
+		return true
+	}
+	return slices.Contains(p.RetryableStatuses, metrics.ResponseStatus)
+}
+
+// backoff returns the delay after the given attempt: exponential growth with
+// full jitter, so concurrent clients don't retry in lockstep
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	return rand.N(p.maxBackoff(attempt)) + 1
+}
+
+// maxBackoff is the longest delay backoff may return after the given attempt,
+// BaseDelay doubled per attempt up to MaxDelay
+func (p RetryPolicy) maxBackoff(attempt int) time.Duration {
+	ceiling := time.Duration(math.MaxInt64)
+	if p.MaxDelay > 0 {
+		ceiling = p.MaxDelay
+	}
+	// Compare before shifting, a shift past the ceiling may wrap around to any value
+	if shift := max(attempt-1, 0); shift < 63 && p.BaseDelay <= ceiling>>shift {
+		return p.BaseDelay << shift
+	}
+	return ceiling
+}
@@ -0,0 +1,69 @@
+package jikan
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+	"treblle_project/internal/models"
+)
+
+// Test 1: Backoff grows exponentially, stays within the cap without overflowing and is jittered
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+
+	for i := 0; i < 100; i++ {
+		if d := policy.backoff(1); d <= 0 || d > 100*time.Millisecond {
+			t.Fatalf("Expected first backoff within (0, 100ms], got %s", d)
+		}
+		if d := policy.backoff(2); d <= 0 || d > 200*time.Millisecond {
+			t.Fatalf("Expected second backoff within (0, 200ms], got %s", d)
+		}
+		if d := policy.backoff(10); d <= 0 || d > 300*time.Millisecond {
+			t.Fatalf("Expected capped backoff within (0, 300ms], got %s", d)
+		}
+	}
+
+	// Without a cap the delay keeps doubling until it saturates, it never wraps around
+	uncapped := RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	previous := time.Duration(0)
+	for attempt := 1; attempt <= 100; attempt++ {
+		ceiling := uncapped.maxBackoff(attempt)
+		if ceiling < previous {
+			t.Fatalf("Expected attempt %d to wait up to at least %s, got %s", attempt, previous, ceiling)
+		}
+		previous = ceiling
+	}
+	if previous != time.Duration(math.MaxInt64) {
+		t.Errorf("Expected the delay to saturate, got %s", previous)
+	}
+}
+
+// Test 2: Only idempotent methods with retryable outcomes are retried
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	policy := DefaultConfig().Retry
+
+	tests := []struct {
+		name     string
+		metrics  *RequestMetrics
+		err      error
+		expected bool
+	}{
+		{"retryable status", &RequestMetrics{Method: "GET", ResponseStatus: 503}, nil, true},
+		{"not retryable status", &RequestMetrics{Method: "GET", ResponseStatus: 404}, nil, false},
+		{"network error", &RequestMetrics{Method: "GET", transportErr: true}, errTest, true},
+		{"invalid JSON", &RequestMetrics{Method: "GET", ResponseStatus: 200}, errTest, false},
+		{"locally throttled", &RequestMetrics{Method: "GET", Throttle: models.ThrottleRejected}, errTest, false},
+		{"non idempotent", &RequestMetrics{Method: "POST", ResponseStatus: 503}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.shouldRetry(tt.metrics, tt.err); got != tt.expected {
+				t.Errorf("Expected shouldRetry=%v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+var errTest = errors.New("test error")
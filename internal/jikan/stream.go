@@ -0,0 +1,175 @@
+package jikan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Streamer is implemented by clients that can pipe upstream responses
+// straight to the caller instead of buffering them
+type Streamer interface {
+	StreamingEnabled() bool
+	StreamRequest(ctx context.Context, path string, header http.Header, w http.ResponseWriter) (*RequestMetrics, error)
+}
+
+// Ensure Client implements Streamer
+var _ Streamer = (*Client)(nil)
+
+// StreamConfig controls streaming mode
+type StreamConfig struct {
+	Enabled      bool
+	ValidateJSON bool // Validate 2xx bodies incrementally while they are forwarded
+	BufferSize   int  // Copy buffer size in bytes, which bounds memory per request
+}
+
+// StreamingEnabled reports whether responses should be streamed
+func (c *Client) StreamingEnabled() bool {
+	return c.config.Stream.Enabled
+}
+
+// StreamRequest fetches path and copies the upstream response, with the
+// headers allowed by the header policy, to w as it arrives. header holds the
+// caller's request headers, as for ProxyRequest. Memory use is bounded by the copy buffer, so ResponseBody is not
+// set. Metrics.Streamed reports whether anything was written to w: when it is
+// false the upstream was not reached or failed before responding, and the
+// caller still owns the response.
+//
+// Streamed requests make a single attempt and bypass the cache and
+// coalescing, which all need the full body.
+func (c *Client) StreamRequest(ctx context.Context, path string, header http.Header, w http.ResponseWriter) (*RequestMetrics, error) {
+	metrics, err := c.attempt(ctx, path, c.config.Headers.requestHeader(header), w)
+	metrics.Attempt = 1
+	metrics.UpstreamCallID = newUpstreamCallID()
+	return metrics, err
+}
+
+// streamBody forwards resp to w, filling in size, timing and validation results
+func (c *Client) streamBody(metrics *RequestMetrics, resp *http.Response, w http.ResponseWriter, trace *tracer, startTime time.Time) (*RequestMetrics, error) {
+	for name, values := range metrics.ResponseHeader {
+		w.Header()[name] = values
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(resp.StatusCode)
+	metrics.Streamed = true
+
+	out := &countingWriter{w: w}
+	buf := make([]byte, max(c.config.Stream.BufferSize, 512))
+
+	var jsonErr, copyErr error
+	if c.config.Stream.ValidateJSON && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// The decoder pulls from the upstream through the tee, so each chunk is
+		// forwarded as soon as it has been read for validation
+		tee := io.TeeReader(resp.Body, out)
+		jsonErr = validateJSONStream(tee)
+		if jsonErr == nil || !isJSONError(jsonErr) {
+			copyErr, jsonErr = jsonErr, nil // Not a validation failure, e.g. a read or write error
+		}
+		if copyErr == nil {
+			_, copyErr = io.CopyBuffer(io.Discard, tee, buf) // Whatever the decoder did not consume
+		}
+	} else {
+		_, copyErr = io.CopyBuffer(out, resp.Body, buf)
+	}
+
+	endTime := time.Now()
+	metrics.ResponseTimeMs = endTime.Sub(startTime).Milliseconds()
+	metrics.Timing = trace.timing(endTime)
+	metrics.ResponseBytes = out.n
+
+	switch {
+	case out.err != nil:
+		// The client went away, which is not the upstream's fault
+		metrics.Error = out.err
+		return metrics, fmt.Errorf("failed to stream response: %w", out.err)
+	case resp.Request.Context().Err() != nil:
+		metrics.Error = copyErr
+		metrics.Cancelled = true
+		return metrics, fmt.Errorf("request cancelled: %w", resp.Request.Context().Err())
+	case copyErr != nil:
+		metrics.Error = copyErr
+		metrics.transportErr = true
+		return metrics, fmt.Errorf("failed to read response body: %w", copyErr)
+	case jsonErr != nil:
+		metrics.Error = jsonErr
+		return metrics, fmt.Errorf("invalid JSON response: %w", jsonErr)
+	}
+	return metrics, nil
+}
+
+// errIncompleteJSON is returned for bodies that end before their JSON value
+// does. A connection dropped mid-body is a read error (io.ErrUnexpectedEOF)
+// instead, which is the network's fault rather than the JSON's.
+var errIncompleteJSON = errors.New("unexpected end of JSON input")
+
+// errTrailingJSON is returned for bodies with a second value after the first
+var errTrailingJSON = errors.New("invalid data after top-level JSON value")
+
+// validateJSONStream checks that r holds exactly one JSON value, token by token, without keeping it in memory
+func validateJSONStream(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errIncompleteJSON // No value, or a truncated one
+			}
+			return err
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			break
+		}
+	}
+
+	// Only whitespace may follow the value
+	switch _, err := dec.Token(); {
+	case errors.Is(err, io.EOF):
+		return nil
+	case err != nil:
+		return err
+	default:
+		return errTrailingJSON
+	}
+}
+
+// isJSONError reports whether err comes from the JSON itself rather than from
+// reading it, a truncated read being a read error
+func isJSONError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	return errors.As(err, &syntaxErr) || errors.Is(err, errIncompleteJSON) || errors.Is(err, errTrailingJSON)
+}
+
+// countingWriter forwards writes, flushing each chunk to the client, and counts bytes written
+type countingWriter struct {
+	w   http.ResponseWriter
+	n   int64
+	err error // First error writing to the client
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	if err != nil {
+		cw.err = err
+		return n, err
+	}
+	if flusher, ok := cw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, nil
+}
@@ -0,0 +1,144 @@
+package jikan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// newStreamingTestClient points a Client with streaming enabled at a local test server
+func newStreamingTestClient(server *httptest.Server, validate bool) *Client {
+	cfg := DefaultConfig()
+	cfg.BaseURL = server.URL
+	cfg.Stream.Enabled = true
+	cfg.Stream.ValidateJSON = validate
+	cfg.Stream.BufferSize = 1024
+	return NewClientWithConfig(cfg)
+}
+
+// Test 1: Upstream bodies are forwarded as they arrive, without being buffered
+func TestClient_StreamRequest(t *testing.T) {
+	body := `{"data":[` + strings.Repeat(`{"mal_id":1},`, 10000) + `{"mal_id":2}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := newStreamingTestClient(server, true)
+	recorder := httptest.NewRecorder()
+
+	metrics, err := client.StreamRequest(context.Background(), "/anime", nil, recorder)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !metrics.Streamed || recorder.Code != 200 {
+		t.Errorf("Expected a streamed 200, got streamed=%v status %d", metrics.Streamed, recorder.Code)
+	}
+	if recorder.Body.String() != body {
+		t.Error("Expected the full body to be forwarded")
+	}
+	if metrics.ResponseBytes != int64(len(body)) {
+		t.Errorf("Expected %d bytes, got %d", len(body), metrics.ResponseBytes)
+	}
+	if metrics.ResponseBody != nil {
+		t.Error("Expected streamed bodies not to be kept in memory")
+	}
+	if !recorder.Flushed {
+		t.Error("Expected chunks to be flushed to the client")
+	}
+}
+
+// Test 2: Invalid JSON is reported after being forwarded, unless validation is off
+func TestClient_StreamRequestValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [1, 2`))
+	}))
+	defer server.Close()
+
+	recorder := httptest.NewRecorder()
+	metrics, err := newStreamingTestClient(server, true).StreamRequest(context.Background(), "/anime/1", nil, recorder)
+	if err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("Expected an invalid JSON error, got %v", err)
+	}
+	if recorder.Body.String() != `{"data": [1, 2` || metrics.transportErr {
+		t.Error("Expected the body to be forwarded and not counted as a transport error")
+	}
+
+	_, err = newStreamingTestClient(server, false).StreamRequest(context.Background(), "/anime/1", nil, httptest.NewRecorder())
+	if err != nil {
+		t.Errorf("Expected no error with validation off, got %v", err)
+	}
+}
+
+// Test 3: Failures before the upstream responds leave the response to the caller
+func TestClient_StreamRequestNotStarted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	recorder := httptest.NewRecorder()
+	metrics, err := newStreamingTestClient(server, true).StreamRequest(context.Background(), "/anime/1", nil, recorder)
+	if err == nil {
+		t.Fatal("Expected an error for an unreachable upstream")
+	}
+	if metrics.Streamed || recorder.Body.Len() != 0 {
+		t.Error("Expected nothing to be written")
+	}
+}
+
+// Test 4: JSON values are validated token by token, with nothing but whitespace after them
+func TestValidateJSONStream(t *testing.T) {
+	tests := []struct {
+		body  string
+		valid bool
+	}{
+		{`{"data":{"mal_id":1}}`, true},
+		{`[1, "two", null]`, true},
+		{`"scalar"`, true},
+		{``, false},
+		{`{"data":`, false},
+		{`{"data" 1}`, false},
+		{"{}\n", true},
+		{`{} garbage`, false},
+		{`{} {}`, false},
+		{`"scalar" 1`, false},
+	}
+
+	for _, tt := range tests {
+		err := validateJSONStream(strings.NewReader(tt.body))
+		if (err == nil) != tt.valid {
+			t.Errorf("validateJSONStream(%q) = %v, expected valid=%v", tt.body, err, tt.valid)
+		}
+	}
+}
+
+// Test 5: A connection dropped mid-body is a transport error, not invalid JSON
+func TestClient_StreamRequestTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte(`{"data": [1, 2`)) // The server closes the connection short of Content-Length
+	}))
+	defer server.Close()
+
+	metrics, err := newStreamingTestClient(server, true).StreamRequest(context.Background(), "/anime/1", nil, httptest.NewRecorder())
+	if err == nil || strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("Expected a read error, got %v", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !metrics.transportErr {
+		t.Errorf("Expected the truncated body to count as a transport error, got %v", err)
+	}
+
+	// Only a body that ends before its value does is invalid JSON
+	dropped := validateJSONStream(io.MultiReader(strings.NewReader(`{"data": [1, 2`), iotest.ErrReader(io.ErrUnexpectedEOF)))
+	if dropped == nil || isJSONError(dropped) {
+		t.Errorf("Expected a dropped read to be a read error, got %v", dropped)
+	}
+	if incomplete := validateJSONStream(strings.NewReader(`{"data": [1, 2`)); !isJSONError(incomplete) {
+		t.Errorf("Expected an incomplete body to be invalid JSON, got %v", incomplete)
+	}
+}
@@ -0,0 +1,91 @@
+package jikan
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing is the per-phase latency breakdown of a single upstream request.
+// Phases that did not happen (e.g. DNS and TCP on a reused connection) stay zero.
+type Timing struct {
+	DNSMs      int64 // DNS lookup
+	ConnectMs  int64 // TCP connect
+	TLSMs      int64 // TLS handshake
+	TTFBMs     int64 // Request start until the first response byte
+	TransferMs int64 // First response byte until the body was fully read
+	ConnReused bool  // Whether an idle keep-alive connection was reused
+}
+
+// tracer collects httptrace callbacks for one request. Callbacks can fire
+// from dialer goroutines, so every field is guarded by mu.
+type tracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func newTracer(start time.Time) *tracer {
+	return &tracer{start: start}
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// Dual-stack dialing may start several connects, measure from the first
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone:       func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+}
+
+func (t *tracer) mark(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*field = time.Now()
+}
+
+// timing computes the breakdown once the body has been read at end
+func (t *tracer) timing(end time.Time) Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := Timing{
+		DNSMs:      between(t.dnsStart, t.dnsDone),
+		ConnectMs:  between(t.connectStart, t.connectDone),
+		TLSMs:      between(t.tlsStart, t.tlsDone),
+		TTFBMs:     between(t.start, t.firstByte),
+		TransferMs: between(t.firstByte, end),
+		ConnReused: t.reused,
+	}
+	return timing
+}
+
+func between(from, to time.Time) int64 {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from).Milliseconds()
+}
@@ -10,6 +10,7 @@ type Problem struct {
 	Description string    `json:"description" db:"description" example:"The requested resource could not be found"` // Human-readable description
 	ThresholdMs int64     `json:"threshold_ms" db:"threshold_ms" example:"400"`                                     // Threshold that triggered this problem (for slow_response)
 	CreatedAt   time.Time `json:"created_at" db:"created_at" example:"2024-01-15T10:30:00Z"`                        // When the problem was detected
+	RuleVersion string    `json:"rule_version" db:"rule_version" example:"1"`                                       // Version of the detection rules that last recorded it, empty for problems from before versioning
 
 	// Joined fields from api_requests
 	Method         string `json:"method,omitempty" db:"method" example:"GET"`                  // HTTP method from related request
@@ -17,3 +18,17 @@ type Problem struct {
 	ResponseStatus int    `json:"response,omitempty" db:"response_status" example:"404"`       // Response status from related request
 	ResponseTimeMs int64  `json:"response_time,omitempty" db:"response_time_ms" example:"150"` // Response time from related request
 }
+
+// RequestProblems are the problems detected on one request, listed when
+// problems are grouped by request
+type RequestProblems struct {
+	RequestID      int       `json:"request_id" example:"5"`                          // Request the problems were detected on
+	Method         string    `json:"method" example:"GET"`                            // HTTP method of the request
+	Path           string    `json:"path" example:"/anime/999"`                       // Path of the request
+	ResponseStatus int       `json:"response" example:"404"`                          // Response status of the request
+	ResponseTimeMs int64     `json:"response_time" example:"3000"`                    // Response time of the request
+	CreatedAt      time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`       // When the request was logged
+	ProblemCount   int       `json:"problem_count" example:"2"`                       // Number of problems
+	ProblemTypes   []string  `json:"problem_types" example:"not_found,slow_response"` // Types of the problems, in detection order
+	Problems       []Problem `json:"problems,omitempty"`                              // The problems, in detection order
+}
@@ -2,12 +2,59 @@ package models
 
 import "time"
 
+// Outcomes of a proxied request other than a normal upstream exchange (empty outcome)
+const (
+	OutcomeCircuitOpen      = "circuit_open"      // Short-circuited by an open circuit breaker
+	OutcomeStreaming        = "streaming"         // Placeholder of a streamed response still being sent
+	OutcomeClientCancelled  = "client_cancelled"  // The client disconnected before the upstream call completed
+	OutcomeDeadlineExceeded = "deadline_exceeded" // The deadline set by the client passed before the upstream call completed
+)
+
+// Cache statuses of a proxied request, reported by the response cache
+const (
+	CacheNone        = ""            // Caching is disabled or the request is not cacheable
+	CacheHit         = "HIT"         // Served from a fresh cache entry, the upstream was not called
+	CacheMiss        = "MISS"        // Fetched from the upstream
+	CacheStale       = "STALE"       // Served from an expired entry (stale-while-revalidate or stale-if-error)
+	CacheRevalidated = "REVALIDATED" // The upstream confirmed an expired entry with 304 Not Modified
+	CacheRefresh     = "REFRESH"     // Background refresh of an entry served stale, made by no client request
+)
+
+// Client-side rate limiting outcomes of a proxied request
+const (
+	ThrottleNone     = ""         // Sent straight away
+	ThrottleQueued   = "queued"   // Waited for a rate limit token before being sent
+	ThrottleRejected = "rejected" // Would have waited too long, the upstream was not called
+)
+
 // APIRequest represents a logged API request with response metrics
 type APIRequest struct {
-	ID             int       `json:"id" db:"id" example:"1"`                                    // Unique identifier
-	Method         string    `json:"method" db:"method" example:"GET"`                          // HTTP method
-	Path           string    `json:"path" db:"path" example:"/anime/1"`                         // Request path
-	ResponseStatus int       `json:"response" db:"response_status" example:"200"`               // HTTP response status code
-	ResponseTimeMs int64     `json:"response_time" db:"response_time_ms" example:"150"`         // Response time in milliseconds
-	CreatedAt      time.Time `json:"created_at" db:"created_at" example:"2024-01-15T10:30:00Z"` // When the request was logged
+	ID              int               `json:"id" db:"id" example:"1"`                                                      // Unique identifier
+	Method          string            `json:"method" db:"method" example:"GET"`                                            // HTTP method
+	Path            string            `json:"path" db:"path" example:"/anime/1"`                                           // Request path
+	ResponseStatus  int               `json:"response" db:"response_status" example:"200"`                                 // HTTP response status code
+	ResponseTimeMs  int64             `json:"response_time" db:"response_time_ms" example:"150"`                           // Response time in milliseconds
+	ResponseBytes   int64             `json:"response_bytes" db:"response_bytes" example:"2048"`                           // Size of the upstream response body in bytes
+	ContentType     string            `json:"content_type" db:"content_type" example:"application/json"`                   // Content-Type of the upstream response
+	UpstreamHeaders map[string]string `json:"upstream_headers" db:"upstream_headers"`                                      // Selected upstream response headers (Cache-Control, Age, ...)
+	Timing          RequestTiming     `json:"timing"`                                                                      // Latency breakdown of the upstream call
+	ThrottleStatus  string            `json:"throttle_status" db:"throttle_status" example:"queued"`                       // Client-side rate limiting outcome: "", "queued" or "rejected"
+	QueueWaitMs     int64             `json:"queue_wait_ms" db:"queue_wait_ms" example:"250"`                              // Time spent queued for a rate limit token, excluded from response_time
+	Outcome         string            `json:"outcome,omitempty" db:"outcome" example:"client_cancelled"`                   // One of the Outcome* constants: circuit_open, streaming, client_cancelled or deadline_exceeded, empty for a normal upstream exchange
+	CacheStatus     string            `json:"cache_status,omitempty" db:"cache_status" example:"HIT"`                      // Response cache outcome: HIT, MISS, STALE, REVALIDATED or REFRESH, empty when caching is off
+	Coalesced       bool              `json:"coalesced" db:"coalesced" example:"false"`                                    // Served by another request's in-flight upstream call
+	UpstreamCallID  string            `json:"upstream_call_id,omitempty" db:"upstream_call_id" example:"9f86d081884c7d65"` // Upstream call that produced the response, shared by coalesced requests
+	ParentID        *int              `json:"parent_id,omitempty" db:"parent_id" example:"41"`                             // For retry attempts, the request they belong to
+	Attempt         int               `json:"attempt" db:"attempt" example:"1"`                                            // Attempt number for retry rows, total attempts made for the request itself
+	CreatedAt       time.Time         `json:"created_at" db:"created_at" example:"2024-01-15T10:30:00Z"`                   // When the request was logged
+}
+
+// RequestTiming is the latency breakdown of an upstream call captured with HTTP client tracing
+type RequestTiming struct {
+	DNSMs      int64 `json:"dns_ms" db:"dns_ms" example:"12"`             // DNS lookup time in milliseconds
+	ConnectMs  int64 `json:"connect_ms" db:"connect_ms" example:"20"`     // TCP connect time in milliseconds
+	TLSMs      int64 `json:"tls_ms" db:"tls_ms" example:"45"`             // TLS handshake time in milliseconds
+	TTFBMs     int64 `json:"ttfb_ms" db:"ttfb_ms" example:"130"`          // Time to first response byte in milliseconds
+	TransferMs int64 `json:"transfer_ms" db:"transfer_ms" example:"20"`   // Body transfer time in milliseconds
+	ConnReused bool  `json:"conn_reused" db:"conn_reused" example:"true"` // Whether a keep-alive connection was reused
 }
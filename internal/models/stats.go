@@ -0,0 +1,26 @@
+package models
+
+// RequestStats aggregates response times and their breakdown over a set of logged requests.
+// Counts are of proxied requests, not of their retry attempts. Latency figures only cover
+// single upstream calls, so cache hits and coalesced requests are left out and retried
+// requests are covered by their attempts.
+type RequestStats struct {
+	Count             int     `json:"count" example:"120"`                // Number of matching proxied requests, retry attempts not included
+	QueuedCount       int     `json:"queued_count" example:"7"`           // Requests that waited for a rate limit token
+	RejectedCount     int     `json:"rejected_count" example:"2"`         // Requests rejected by the client-side rate limiter
+	CacheHitCount     int     `json:"cache_hit_count" example:"40"`       // Requests served from a fresh cache entry
+	CacheStaleCount   int     `json:"cache_stale_count" example:"3"`      // Requests served from an expired cache entry
+	CacheMissCount    int     `json:"cache_miss_count" example:"25"`      // Cacheable requests fetched from the upstream
+	CoalescedCount    int     `json:"coalesced_count" example:"12"`       // Requests served by another request's upstream call
+	AvgQueueWaitMs    float64 `json:"avg_queue_wait_ms" example:"310.0"`  // Average rate limit wait of queued requests in milliseconds
+	AvgResponseTimeMs float64 `json:"avg_response_time" example:"210.5"`  // Average total response time in milliseconds
+	MinResponseTimeMs int64   `json:"min_response_time" example:"80"`     // Fastest response time in milliseconds
+	MaxResponseTimeMs int64   `json:"max_response_time" example:"1900"`   // Slowest response time in milliseconds
+	AvgDNSMs          float64 `json:"avg_dns_ms" example:"1.2"`           // Average DNS lookup time in milliseconds
+	AvgConnectMs      float64 `json:"avg_connect_ms" example:"3.4"`       // Average TCP connect time in milliseconds
+	AvgTLSMs          float64 `json:"avg_tls_ms" example:"8.1"`           // Average TLS handshake time in milliseconds
+	AvgTTFBMs         float64 `json:"avg_ttfb_ms" example:"180.2"`        // Average time to first byte in milliseconds
+	AvgTransferMs     float64 `json:"avg_transfer_ms" example:"17.6"`     // Average body transfer time in milliseconds
+	ConnReusedRatio   float64 `json:"conn_reused_ratio" example:"0.85"`   // Share of requests that reused a connection (0-1)
+	AvgResponseBytes  float64 `json:"avg_response_bytes" example:"20480"` // Average response size in bytes
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Resources a saved view can target
+const (
+	ViewResourceRequests = "requests"
+	ViewResourceProblems = "problems"
+)
+
+// View is a saved, named combination of filters, sort and columns for the
+// request or problem list, table and CSV endpoints
+type View struct {
+	ID        int               `json:"id" db:"id" example:"1"`                                    // Unique identifier
+	Name      string            `json:"name" db:"name" example:"slow-anime"`                       // Unique name used in ?view=
+	Resource  string            `json:"resource" db:"resource" example:"problems"`                 // Target resource: requests or problems
+	Filters   map[string]string `json:"filters" db:"filters"`                                      // Query parameters applied by the view, such as response or q
+	Sort      string            `json:"sort" db:"sort" example:"-response_time"`                   // Sort spec, same syntax as the sort parameter
+	Columns   []string          `json:"columns" db:"columns"`                                      // Columns of the table and CSV views, in order, all when empty
+	CreatedAt time.Time         `json:"created_at" db:"created_at" example:"2024-01-15T10:30:00Z"` // When the view was created
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at" example:"2024-01-15T10:30:00Z"` // When the view was last changed
+}
@@ -0,0 +1,178 @@
+package query
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of a field's values
+type Type int
+
+const (
+	Int Type = iota
+	String
+	Time
+	Bool
+)
+
+func (t Type) String() string {
+	return [...]string{"integer", "string", "time", "boolean"}[t]
+}
+
+// Field maps a field name of the language to a SQL column
+type Field struct {
+	Column string // Trusted SQL, never derived from the expression
+	Type   Type
+}
+
+// Fields is the whitelist of fields an expression may use
+type Fields map[string]Field
+
+// Options tune how values are read
+type Options struct {
+	// ParseTime reads time values, RFC3339 only when nil
+	ParseTime func(string) (time.Time, error)
+}
+
+// Condition is a compiled expression: SQL with ? placeholders and their arguments
+type Condition struct {
+	SQL  string
+	Args []any
+}
+
+// operators lists the operators each type supports
+var operators = map[Type][]string{
+	Int:    {"=", "!=", ">", ">=", "<", "<=", "IN", "NOT IN"},
+	String: {"=", "!=", ">", ">=", "<", "<=", "~", "!~", "IN", "NOT IN"},
+	Time:   {"=", "!=", ">", ">=", "<", "<="},
+	Bool:   {"=", "!="},
+}
+
+// Compile type checks node against fields and turns it into a SQL condition
+func Compile(node Node, fields Fields, opts Options) (*Condition, error) {
+	c := &compiler{fields: fields, opts: opts}
+	var sql strings.Builder
+	if err := c.compile(node, &sql); err != nil {
+		return nil, err
+	}
+	return &Condition{SQL: sql.String(), Args: c.args}, nil
+}
+
+// ParseAndCompile parses input and compiles it against fields
+func ParseAndCompile(input string, fields Fields, opts Options) (*Condition, error) {
+	node, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(node, fields, opts)
+}
+
+type compiler struct {
+	fields Fields
+	opts   Options
+	args   []any
+}
+
+func (c *compiler) compile(node Node, sql *strings.Builder) error {
+	switch n := node.(type) {
+	case *Logical:
+		sql.WriteString("(")
+		if err := c.compile(n.Left, sql); err != nil {
+			return err
+		}
+		sql.WriteString(" " + n.Op + " ")
+		if err := c.compile(n.Right, sql); err != nil {
+			return err
+		}
+		sql.WriteString(")")
+
+	case *Not:
+		sql.WriteString("NOT (")
+		if err := c.compile(n.Expr, sql); err != nil {
+			return err
+		}
+		sql.WriteString(")")
+
+	case *Comparison:
+		return c.comparison(n, sql)
+	}
+	return nil
+}
+
+func (c *compiler) comparison(n *Comparison, sql *strings.Builder) error {
+	field, ok := c.fields[strings.ToLower(n.Field)]
+	if !ok {
+		return errorAt(n.FieldPos, "unknown field %s, expected one of %s", quote(n.Field), strings.Join(c.fieldNames(), ", "))
+	}
+	if !slices.Contains(operators[field.Type], n.Op) {
+		return errorAt(n.FieldPos, "operator %s is not supported for %s field %s", n.Op, field.Type, n.Field)
+	}
+
+	for _, v := range n.Values {
+		if n.Op == "~" || n.Op == "!~" {
+			if _, err := regexp.Compile(v.Text); err != nil {
+				return errorAt(v.Pos, "invalid regular expression %s: %s", quote(v.Text), err)
+			}
+		}
+		arg, err := c.convert(v, field.Type)
+		if err != nil {
+			return err
+		}
+		c.args = append(c.args, arg)
+	}
+
+	switch n.Op {
+	case "IN", "NOT IN":
+		sql.WriteString(field.Column + " " + n.Op + " (" + strings.TrimSuffix(strings.Repeat("?, ", len(n.Values)), ", ") + ")")
+	case "~":
+		sql.WriteString(field.Column + " REGEXP ?")
+	case "!~":
+		sql.WriteString(field.Column + " NOT REGEXP ?")
+	default:
+		sql.WriteString(field.Column + " " + n.Op + " ?")
+	}
+	return nil
+}
+
+// convert reads a literal as a value of type t
+func (c *compiler) convert(v Value, t Type) (any, error) {
+	switch t {
+	case Int:
+		n, err := strconv.ParseInt(v.Text, 10, 64)
+		if err != nil || v.Quoted {
+			return nil, errorAt(v.Pos, "expected an integer, got %s", quote(v.Text))
+		}
+		return n, nil
+
+	case Bool:
+		b, err := strconv.ParseBool(v.Text)
+		if err != nil || v.Quoted {
+			return nil, errorAt(v.Pos, "expected true or false, got %s", quote(v.Text))
+		}
+		return b, nil
+
+	case Time:
+		parse := c.opts.ParseTime
+		if parse == nil {
+			parse = func(s string) (time.Time, error) { return time.Parse(time.RFC3339Nano, s) }
+		}
+		ts, err := parse(v.Text)
+		if err != nil {
+			return nil, errorAt(v.Pos, "expected a time, got %s", quote(v.Text))
+		}
+		return ts, nil
+	}
+	return v.Text, nil
+}
+
+func (c *compiler) fieldNames() []string {
+	names := make([]string, 0, len(c.fields))
+	for name := range c.fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,132 @@
+package query
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF    tokenKind = iota
+	tokenWord             // Field names, keywords and bare values such as 500, GET or -15m
+	tokenString           // Quoted with ' or ", backslash escapes the next character
+	tokenOp               // = != > >= < <= ~ !~
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string // Unquoted text for strings
+	pos  int    // 1-based character position in the input
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenString:
+		return "string " + quote(t.text)
+	default:
+		return quote(t.text)
+	}
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// isWordChar reports whether r can be part of a bare word. Signs, dots and
+// colons let numbers, relative times and timestamps be written unquoted.
+func isWordChar(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("_-+.:/*", r)
+}
+
+// lex splits input into tokens
+func lex(input string) ([]token, error) {
+	var tokens []token
+	pos := 1 // Character position of i, for error messages
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		start := pos
+
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i += size
+			pos++
+
+		case r == '(' || r == ')' || r == ',':
+			kind := map[rune]tokenKind{'(': tokenLParen, ')': tokenRParen, ',': tokenComma}[r]
+			tokens = append(tokens, token{kind: kind, text: string(r), pos: start})
+			i++
+			pos++
+
+		case r == '=' || r == '~':
+			tokens = append(tokens, token{kind: tokenOp, text: string(r), pos: start})
+			i++
+			pos++
+
+		case r == '!' || r == '<' || r == '>':
+			op := string(r)
+			if i+1 < len(input) && (input[i+1] == '=' || (r == '!' && input[i+1] == '~')) {
+				op += string(input[i+1])
+			}
+			if op == "!" {
+				return nil, errorAt(start, "unexpected '!', expected != or !~")
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: start})
+			i += len(op)
+			pos += len(op)
+
+		case r == '\'' || r == '"':
+			var text strings.Builder
+			i += size
+			pos++
+			closed := false
+			for i < len(input) {
+				c, n := utf8.DecodeRuneInString(input[i:])
+				i += n
+				pos++
+				if c == '\\' && i < len(input) {
+					escaped, m := utf8.DecodeRuneInString(input[i:])
+					text.WriteRune(escaped)
+					i += m
+					pos++
+					continue
+				}
+				if c == r {
+					closed = true
+					break
+				}
+				text.WriteRune(c)
+			}
+			if !closed {
+				return nil, errorAt(start, "unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text.String(), pos: start})
+
+		case isWordChar(r):
+			j := i
+			for j < len(input) {
+				c, n := utf8.DecodeRuneInString(input[j:])
+				if !isWordChar(c) {
+					break
+				}
+				j += n
+				pos++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: input[i:j], pos: start})
+			i = j
+
+		default:
+			return nil, errorAt(start, "unexpected character %q", r)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: pos}), nil
+}
@@ -0,0 +1,278 @@
+// Package query implements the filter expression language of the q= query
+// parameter, such as
+//
+//	status >= 500 OR (response_time > 800 AND path ~ '/anime/')
+//
+// Expressions are parsed into an AST and compiled into a parameterized SQL
+// condition. Field names are looked up in a whitelist and every value becomes
+// a bind argument, so user input never reaches the SQL text.
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits keeping expressions cheap to parse and to run
+const (
+	MaxLength      = 4096 // Characters
+	MaxDepth       = 32   // Nested parentheses and NOTs
+	MaxComparisons = 64
+	MaxListValues  = 100 // Values of one IN list
+)
+
+// Error is a syntax or type error at a position of the expression
+type Error struct {
+	Pos int // 1-based character position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+func errorAt(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Node is a node of a parsed expression
+type Node interface {
+	node()
+}
+
+// Logical combines two expressions with AND or OR
+type Logical struct {
+	Op    string // "AND" or "OR"
+	Left  Node
+	Right Node
+}
+
+// Not negates an expression
+type Not struct {
+	Expr Node
+}
+
+// Comparison compares a field with one value, or with a list for IN and NOT IN
+type Comparison struct {
+	Field    string
+	FieldPos int
+	Op       string // = != > >= < <= ~ !~ IN "NOT IN"
+	Values   []Value
+}
+
+// Value is a literal as written, typed against its field when compiling
+type Value struct {
+	Text   string
+	Quoted bool
+	Pos    int
+}
+
+func (*Logical) node()    {}
+func (*Not) node()        {}
+func (*Comparison) node() {}
+
+// Parse parses an expression
+func Parse(input string) (Node, error) {
+	if len(input) > MaxLength {
+		return nil, errorAt(MaxLength, "expression longer than %d characters", MaxLength)
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, errorAt(1, "empty expression")
+	}
+	node, err := p.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorAt(t.pos, "unexpected %s, expected AND, OR or end of input", t.describe())
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens      []token
+	next        int
+	comparisons int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the given keyword, case-insensitively
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && strings.EqualFold(t.text, word)
+}
+
+// or := and ("OR" and)*
+func (p *parser) or(depth int) (Node, error) {
+	left, err := p.and(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.advance()
+		right, err := p.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// and := unary ("AND" unary)*
+func (p *parser) and(depth int) (Node, error) {
+	left, err := p.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		p.advance()
+		right, err := p.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// unary := "NOT" unary | "(" or ")" | comparison
+func (p *parser) unary(depth int) (Node, error) {
+	if depth >= MaxDepth {
+		return nil, errorAt(p.peek().pos, "expression nested deeper than %d levels", MaxDepth)
+	}
+
+	if p.keyword("NOT") {
+		p.advance()
+		expr, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr}, nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		open := p.advance()
+		expr, err := p.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t.kind != tokenRParen {
+			return nil, errorAt(t.pos, "unexpected %s, expected ')' closing the '(' at position %d", t.describe(), open.pos)
+		}
+		p.advance()
+		return expr, nil
+	}
+
+	return p.comparison()
+}
+
+// comparison := field op value | field ["NOT"] "IN" "(" value ("," value)* ")"
+func (p *parser) comparison() (Node, error) {
+	field := p.advance()
+	if field.kind != tokenWord || isKeyword(field.text) {
+		return nil, errorAt(field.pos, "unexpected %s, expected a field name", field.describe())
+	}
+
+	p.comparisons++
+	if p.comparisons > MaxComparisons {
+		return nil, errorAt(field.pos, "more than %d comparisons", MaxComparisons)
+	}
+
+	c := &Comparison{Field: field.text, FieldPos: field.pos}
+
+	switch {
+	case p.peek().kind == tokenOp:
+		c.Op = p.advance().text
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		c.Values = []Value{value}
+		return c, nil
+
+	case p.keyword("IN"), p.keyword("NOT"):
+		c.Op = "IN"
+		if p.keyword("NOT") {
+			p.advance()
+			if !p.keyword("IN") {
+				t := p.peek()
+				return nil, errorAt(t.pos, "unexpected %s, expected IN after NOT", t.describe())
+			}
+			c.Op = "NOT IN"
+		}
+		p.advance()
+		values, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		c.Values = values
+		return c, nil
+	}
+
+	t := p.peek()
+	return nil, errorAt(t.pos, "unexpected %s, expected an operator (=, !=, >, >=, <, <=, ~, !~, IN, NOT IN) after %s", t.describe(), field.text)
+}
+
+// list := "(" value ("," value)* ")"
+func (p *parser) list() ([]Value, error) {
+	if t := p.advance(); t.kind != tokenLParen {
+		return nil, errorAt(t.pos, "unexpected %s, expected '(' starting a list", t.describe())
+	}
+
+	var values []Value
+	for {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if len(values) > MaxListValues {
+			return nil, errorAt(value.Pos, "list longer than %d values", MaxListValues)
+		}
+
+		switch t := p.advance(); t.kind {
+		case tokenComma:
+		case tokenRParen:
+			return values, nil
+		default:
+			return nil, errorAt(t.pos, "unexpected %s, expected ',' or ')'", t.describe())
+		}
+	}
+}
+
+func (p *parser) value() (Value, error) {
+	t := p.advance()
+	switch {
+	case t.kind == tokenString:
+		return Value{Text: t.text, Quoted: true, Pos: t.pos}, nil
+	case t.kind == tokenWord && !isKeyword(t.text):
+		return Value{Text: t.text, Pos: t.pos}, nil
+	}
+	return Value{}, errorAt(t.pos, "unexpected %s, expected a value", t.describe())
+}
+
+func isKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "AND", "OR", "NOT", "IN":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testFields = Fields{
+	"method":        {Column: "method", Type: String},
+	"path":          {Column: "path", Type: String},
+	"status":        {Column: "response_status", Type: Int},
+	"response_time": {Column: "response_time_ms", Type: Int},
+	"created_at":    {Column: "created_at", Type: Time},
+	"coalesced":     {Column: "coalesced", Type: Bool},
+}
+
+// Test 1: Expressions compile to parameterized SQL with AND binding tighter than OR
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input string
+		sql   string
+		args  []any
+	}{
+		{
+			"status >= 500 OR (response_time > 800 AND path ~ '/anime/')",
+			"(response_status >= ? OR (response_time_ms > ? AND path REGEXP ?))",
+			[]any{int64(500), int64(800), "/anime/"},
+		},
+		{
+			"status >= 500 or response_time > 800 and path !~ top",
+			"(response_status >= ? OR (response_time_ms > ? AND path NOT REGEXP ?))",
+			[]any{int64(500), int64(800), "top"},
+		},
+		{
+			"NOT method IN (GET, 'HEAD') AND status != 404",
+			"(NOT (method IN (?, ?)) AND response_status != ?)",
+			[]any{"GET", "HEAD", int64(404)},
+		},
+		{
+			"Status not in (500,502) and coalesced = false",
+			"(response_status NOT IN (?, ?) AND coalesced = ?)",
+			[]any{int64(500), int64(502), false},
+		},
+		{
+			`path = "/anime/1?q=\"x\""`,
+			"path = ?",
+			[]any{`/anime/1?q="x"`},
+		},
+		{
+			"created_at >= 2025-10-24T10:30:00Z",
+			"created_at >= ?",
+			[]any{time.Date(2025, 10, 24, 10, 30, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, tt := range tests {
+		cond, err := ParseAndCompile(tt.input, testFields, Options{})
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if cond.SQL != tt.sql {
+			t.Errorf("%q: expected SQL %q, got %q", tt.input, tt.sql, cond.SQL)
+		}
+		if !reflect.DeepEqual(cond.Args, tt.args) {
+			t.Errorf("%q: expected args %#v, got %#v", tt.input, tt.args, cond.Args)
+		}
+	}
+}
+
+// Test 2: Syntax and type errors report the position they occur at
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{"", 1, "empty expression"},
+		{"status >", 9, "expected a value"},
+		{"status 500", 8, "expected an operator"},
+		{"(status = 500", 14, "closing the '(' at position 1"},
+		{"status = 500)", 13, "expected AND, OR or end of input"},
+		{"status = 500 AND", 17, "expected a field name"},
+		{"path = 'abc", 8, "unterminated string"},
+		{"status ! 500", 8, "expected != or !~"},
+		{"status = 500; DROP TABLE x", 13, "unexpected character ';'"},
+		{"method NOT = GET", 12, "expected IN after NOT"},
+		{"method IN GET", 11, "expected '(' starting a list"},
+		{"method IN (GET POST)", 16, "expected ',' or ')'"},
+		{"bogus = 1", 1, "unknown field 'bogus'"},
+		{"status = '500'", 10, "expected an integer"},
+		{"status = abc", 10, "expected an integer"},
+		{"status ~ 5", 1, "operator ~ is not supported for integer field status"},
+		{"coalesced > true", 1, "operator > is not supported"},
+		{"created_at > yesterday", 14, "expected a time"},
+		{"path ~ '('", 8, "invalid regular expression"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseAndCompile(tt.input, testFields, Options{})
+		var qerr *Error
+		if !errors.As(err, &qerr) {
+			t.Errorf("%q: expected an *Error, got %v", tt.input, err)
+			continue
+		}
+		if qerr.Pos != tt.pos || !strings.Contains(qerr.Msg, tt.msg) {
+			t.Errorf("%q: expected %q at position %d, got %v", tt.input, tt.msg, tt.pos, err)
+		}
+	}
+}
+
+// Test 3: Oversized expressions are rejected before they cost anything
+func TestParse_Limits(t *testing.T) {
+	inputs := []string{
+		strings.Repeat(" ", MaxLength+1),
+		strings.Repeat("(", MaxDepth+1) + "status = 1" + strings.Repeat(")", MaxDepth+1),
+		strings.Repeat("NOT ", MaxDepth+1) + "status = 1",
+		strings.Repeat("status = 1 OR ", MaxComparisons) + "status = 1",
+		"status IN (" + strings.TrimSuffix(strings.Repeat("1,", MaxListValues+1), ",") + ")",
+	}
+
+	for _, input := range inputs {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("%.40q...: expected an error", input)
+		}
+	}
+
+	if _, err := Parse(strings.Repeat("(", MaxDepth-1) + "status = 1" + strings.Repeat(")", MaxDepth-1)); err != nil {
+		t.Errorf("Expected nesting within the limit to parse, got %v", err)
+	}
+}
+
+// Test 4: Times are read with the ParseTime option
+func TestCompile_ParseTime(t *testing.T) {
+	now := time.Date(2025, 10, 24, 12, 0, 0, 0, time.UTC)
+	opts := Options{ParseTime: func(s string) (time.Time, error) {
+		if s == "-15m" {
+			return now.Add(-15 * time.Minute), nil
+		}
+		return time.Time{}, errors.New("unsupported")
+	}}
+
+	cond, err := ParseAndCompile("created_at >= -15m", testFields, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := cond.Args[0].(time.Time); !got.Equal(now.Add(-15 * time.Minute)) {
+		t.Errorf("Expected %v, got %v", now.Add(-15*time.Minute), got)
+	}
+}
+
+// sqlPattern matches SQL made only of whitelisted columns, operators and placeholders
+var sqlPattern = regexp.MustCompile(`^(?:[()?, ]|AND|OR|NOT|IN|REGEXP|method|path|response_status|response_time_ms|created_at|coalesced|=|!=|>=?|<=?)*$`)
+
+// Test 5: Injection payloads end up as bind arguments or are rejected, never in the SQL
+func TestCompile_SQLInjection(t *testing.T) {
+	payloads := []string{
+		`'; DROP TABLE api_requests; --`,
+		`' OR '1'='1`,
+		`" OR ""="`,
+		`GET' UNION SELECT * FROM problems --`,
+		`\'; DELETE FROM api_requests WHERE 1=1; --`,
+		`/anime/*/ OR 1=1`,
+		"x\x00y",
+		`%' --`,
+	}
+
+	for _, payload := range payloads {
+		quoted := "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(payload) + "'"
+		for _, input := range []string{
+			"path = " + quoted,
+			"method IN (" + quoted + ", GET)",
+			"path ~ " + quoted + " OR NOT path != " + quoted,
+		} {
+			cond, err := ParseAndCompile(input, testFields, Options{})
+			if err != nil {
+				// Rejected payloads are safe too, but these are all valid strings
+				t.Errorf("%q: unexpected error %v", input, err)
+				continue
+			}
+			if !sqlPattern.MatchString(cond.SQL) {
+				t.Errorf("%q: SQL contains more than columns and placeholders: %q", input, cond.SQL)
+			}
+			if cond.Args[0] != payload {
+				t.Errorf("%q: expected the payload as argument, got %#v", input, cond.Args[0])
+			}
+		}
+	}
+
+	// Fields and bare values that aren't plain words can't be written at all
+	for _, input := range []string{
+		"path = x; DROP TABLE api_requests",
+		"1=1 OR path = x",
+		"path = x OR 1=1 --",
+		"(SELECT 1) = 1",
+		"status = 1 UNION SELECT 1",
+		"status = 1/**/OR/**/1=1",
+		"response_status = 500",
+		"api_requests.path = x",
+		"path -- = x",
+		"`path` = x",
+		"path = x'",
+	} {
+		if cond, err := ParseAndCompile(input, testFields, Options{}); err == nil {
+			t.Errorf("%q: expected an error, got SQL %q", input, cond.SQL)
+		}
+	}
+}
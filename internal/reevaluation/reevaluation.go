@@ -0,0 +1,197 @@
+// Package reevaluation replays problem detection over logged requests, so
+// changed rules and thresholds apply to history too
+package reevaluation
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	"treblle_project/internal/detector"
+	"treblle_project/internal/models"
+	"treblle_project/internal/repository"
+)
+
+// pageSize is how many requests a run checks, and changes, at a time
+const pageSize = 500
+
+// MaxListedChanges is how many added and how many removed problems a Report
+// lists, later ones are only counted
+const MaxListedChanges = 1000
+
+// Rules are the detection rules a run replays
+type Rules struct {
+	Version  string            // Tags the problems the rules record
+	Types    []string          // Problem types the rules record, problems of other types are left alone
+	Detector detector.Detector // Finds the problems of a request
+}
+
+// DefaultRules are the rules the proxy records problems with
+var DefaultRules = Rules{
+	Version:  detector.Version,
+	Types:    detector.Types,
+	Detector: detector.Default,
+}
+
+// Options configure a run
+type Options struct {
+	From  time.Time // Inclusive lower bound on the created_at of checked requests, none when zero
+	To    time.Time // Exclusive upper bound on the created_at of checked requests, none when zero
+	Apply bool      // Change the stored problems, a dry run only reports the changes
+	Rules Rules
+}
+
+// Report is the outcome of a run: the problems the rules add and remove
+type Report struct {
+	RuleVersion  string           `json:"rule_version" example:"2"`                     // Version of the replayed rules
+	From         time.Time        `json:"from,omitzero" example:"2024-01-01T00:00:00Z"` // Lower bound on created_at, absent for none
+	To           time.Time        `json:"to,omitzero" example:"2024-02-01T00:00:00Z"`   // Upper bound on created_at, absent for none
+	Applied      bool             `json:"applied" example:"false"`                      // Whether the changes were stored, false for a dry run
+	Checked      int              `json:"checked" example:"1200"`                       // Requests checked
+	Unchanged    int              `json:"unchanged" example:"80"`                       // Stored problems the rules record again
+	Retagged     int              `json:"retagged" example:"12"`                        // Unchanged problems tagged with an older rule version, retagged when applied
+	AddedCount   int              `json:"added_count" example:"14"`                     // Problems the rules record that aren't stored
+	RemovedCount int              `json:"removed_count" example:"3"`                    // Stored problems the rules no longer record
+	Added        []models.Problem `json:"added"`                                        // The first MaxListedChanges added problems
+	Removed      []models.Problem `json:"removed"`                                      // The first MaxListedChanges removed problems
+	Truncated    bool             `json:"truncated" example:"false"`                    // More problems changed than added and removed list
+}
+
+// Job replays detection over stored requests
+type Job struct {
+	requests *repository.RequestRepository
+	problems *repository.ProblemRepository
+}
+
+func NewJob(requests *repository.RequestRepository, problems *repository.ProblemRepository) *Job {
+	return &Job{requests: requests, problems: problems}
+}
+
+// Run checks the requests created in the range of opts, oldest first, a page
+// at a time. Each page's changes are applied in one transaction, so an
+// error leaves the pages before it changed.
+func (j *Job) Run(opts Options) (*Report, error) {
+	report := &Report{
+		RuleVersion: opts.Rules.Version,
+		From:        opts.From,
+		To:          opts.To,
+		Applied:     opts.Apply,
+		Added:       []models.Problem{},
+		Removed:     []models.Problem{},
+	}
+
+	filters := repository.RequestFilters{
+		CreatedAfter:  opts.From,
+		CreatedBefore: opts.To,
+		SortBy:        "created_at",
+		Limit:         pageSize,
+	}
+	for {
+		page, err := j.requests.ListPage(filters)
+		if err != nil {
+			return report, fmt.Errorf("failed to list requests: %w", err)
+		}
+		if err := j.check(page.Items, opts, report); err != nil {
+			return report, err
+		}
+		if page.NextCursor == "" {
+			return report, nil
+		}
+		filters.Cursor = page.NextCursor
+	}
+}
+
+// check compares the stored problems of requests with the ones the rules
+// record, applying the difference when asked to
+func (j *Job) check(requests []models.APIRequest, opts Options, report *Report) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(requests))
+	for i, req := range requests {
+		ids[i] = req.ID
+	}
+	stored := map[int][]models.Problem{}
+	err := j.problems.Each(repository.ProblemFilters{
+		RequestIDs:   ids,
+		ProblemTypes: repository.ValueFilter{Include: opts.Rules.Types},
+	}, func(p *models.Problem) error {
+		stored[p.RequestID] = append(stored[p.RequestID], *p)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list problems: %w", err)
+	}
+
+	var removed []models.Problem
+	var added []*models.Problem
+	var retagged []int
+	unchanged := 0
+	for i := range requests {
+		req := &requests[i]
+
+		detected := opts.Rules.Detector.Detect(req)
+
+		// A stored problem of the same type and threshold is recorded again,
+		// its rule version becomes the one that last found it
+		for _, p := range stored[req.ID] {
+			i := slices.IndexFunc(detected, func(d *models.Problem) bool { return sameProblem(d, &p) })
+			if i < 0 {
+				removed = append(removed, p)
+				continue
+			}
+			detected = slices.Delete(detected, i, i+1)
+			unchanged++
+			if p.RuleVersion != opts.Rules.Version {
+				retagged = append(retagged, p.ID)
+			}
+		}
+
+		for _, problem := range detected {
+			problem.RequestID = req.ID
+			problem.CreatedAt = req.CreatedAt // Would have been detected when the request was logged
+			problem.RuleVersion = opts.Rules.Version
+			problem.Method, problem.Path = req.Method, req.Path
+			problem.ResponseStatus, problem.ResponseTimeMs = req.ResponseStatus, req.ResponseTimeMs
+			added = append(added, problem)
+		}
+	}
+
+	if opts.Apply && (len(removed) > 0 || len(added) > 0 || len(retagged) > 0) {
+		changes := repository.ProblemChanges{Add: added, Retag: retagged, RuleVersion: opts.Rules.Version}
+		for _, p := range removed {
+			changes.Remove = append(changes.Remove, p.ID)
+		}
+		if err := j.problems.Replace(changes); err != nil {
+			return err
+		}
+	}
+
+	report.Checked += len(requests)
+	report.Unchanged += unchanged
+	report.Retagged += len(retagged)
+	for _, p := range removed {
+		report.RemovedCount++
+		report.Removed = appendListed(report, report.Removed, p)
+	}
+	for _, p := range added {
+		report.AddedCount++
+		report.Added = appendListed(report, report.Added, *p)
+	}
+	return nil
+}
+
+// sameProblem reports whether a and b record the same finding
+func sameProblem(a, b *models.Problem) bool {
+	return a.ProblemType == b.ProblemType && a.ThresholdMs == b.ThresholdMs
+}
+
+// appendListed appends p to list unless it holds MaxListedChanges problems
+// already, flagging the report as truncated then
+func appendListed(report *Report, list []models.Problem, p models.Problem) []models.Problem {
+	if len(list) == MaxListedChanges {
+		report.Truncated = true
+		return list
+	}
+	return append(list, p)
+}
@@ -0,0 +1,132 @@
+package reevaluation
+
+import (
+	"testing"
+	"time"
+	"treblle_project/internal/detector"
+	"treblle_project/internal/models"
+	"treblle_project/internal/repository"
+	"treblle_project/internal/testutil"
+)
+
+// testRules record not_found for 404s and slow_response from 1000ms
+var testRules = Rules{
+	Version: "2",
+	Types:   []string{"not_found", "slow_response"},
+	Detector: detector.Func(func(req *models.APIRequest) []*models.Problem {
+		switch {
+		case req.ResponseStatus == 404:
+			return []*models.Problem{{ProblemType: "not_found", Description: "Not found"}}
+		case req.ResponseTimeMs >= 1000:
+			return []*models.Problem{{ProblemType: "slow_response", Description: "Slow", ThresholdMs: 1000}}
+		}
+		return nil
+	}),
+}
+
+// Test 1: Dry runs report the difference, applying it stores it once, retags the problems found again and leaves other problems alone
+func TestJob_Run(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	requests := repository.NewRequestRepository(db)
+	problems := repository.NewProblemRepository(db)
+
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	items := []repository.BatchItem{
+		// A stored 404 is recorded again
+		{
+			Request:  &models.APIRequest{Method: "GET", Path: "/anime/1", ResponseStatus: 404, ResponseTimeMs: 100, CreatedAt: at(1)},
+			Problems: []*models.Problem{{ProblemType: "not_found", Description: "Not found", CreatedAt: at(1)}},
+		},
+		// A slow response nothing was recorded for
+		{Request: &models.APIRequest{Method: "GET", Path: "/anime/2", ResponseStatus: 200, ResponseTimeMs: 1500, CreatedAt: at(2)}},
+		// A slow response under the old threshold that isn't slow anymore
+		{
+			Request:  &models.APIRequest{Method: "GET", Path: "/anime/3", ResponseStatus: 200, ResponseTimeMs: 500, CreatedAt: at(3)},
+			Problems: []*models.Problem{{ProblemType: "slow_response", Description: "Slow", ThresholdMs: 400, CreatedAt: at(3)}},
+		},
+		// A circuit breaker event isn't a detected problem
+		{
+			Request:  &models.APIRequest{Method: "GET", Path: "/anime/4", ResponseStatus: 200, ResponseTimeMs: 100, CreatedAt: at(4)},
+			Problems: []*models.Problem{{ProblemType: "circuit_breaker_open", Description: "Open", CreatedAt: at(4)}},
+		},
+		// Outside the range
+		{Request: &models.APIRequest{Method: "GET", Path: "/anime/5", ResponseStatus: 404, ResponseTimeMs: 100, CreatedAt: at(60)}},
+	}
+	// Enough fast requests to take more than one page
+	for i := range pageSize {
+		items = append(items, repository.BatchItem{Request: &models.APIRequest{Method: "GET", Path: "/anime/fast", ResponseStatus: 200, ResponseTimeMs: 10, CreatedAt: at(5).Add(time.Duration(i) * time.Millisecond)}})
+	}
+	if err := requests.CreateBatch(items); err != nil {
+		t.Fatalf("Failed to create requests: %v", err)
+	}
+
+	job := NewJob(requests, problems)
+	opts := Options{From: start, To: at(30), Rules: testRules}
+	report, err := job.Run(opts)
+	if err != nil {
+		t.Fatalf("Failed to run: %v", err)
+	}
+	if report.Applied || report.Checked != 4+pageSize || report.Unchanged != 1 || report.Retagged != 1 || report.AddedCount != 1 || report.RemovedCount != 1 {
+		t.Errorf("Unexpected dry run report %+v", report)
+	}
+	if len(report.Added) != 1 || report.Added[0].Path != "/anime/2" || report.Added[0].RuleVersion != "2" || !report.Added[0].CreatedAt.Equal(at(2)) {
+		t.Errorf("Expected slow_response on /anime/2 to be added, got %+v", report.Added)
+	}
+	if len(report.Removed) != 1 || report.Removed[0].Path != "/anime/3" {
+		t.Errorf("Expected slow_response on /anime/3 to be removed, got %+v", report.Removed)
+	}
+	if count, _ := problems.Count(repository.ProblemFilters{}); count != 3 {
+		t.Errorf("Expected a dry run to change nothing, got %d problems", count)
+	}
+
+	opts.Apply = true
+	if report, err = job.Run(opts); err != nil || !report.Applied || report.AddedCount != 1 || report.RemovedCount != 1 {
+		t.Fatalf("Unexpected report %+v (%v)", report, err)
+	}
+	stored, err := problems.List(repository.ProblemFilters{SortBy: "created_at"})
+	if err != nil {
+		t.Fatalf("Failed to list problems: %v", err)
+	}
+	var got []string
+	for _, p := range stored {
+		got = append(got, p.Path+" "+p.ProblemType+" "+p.RuleVersion)
+	}
+	expected := []string{"/anime/1 not_found 2", "/anime/2 slow_response 2", "/anime/4 circuit_breaker_open "}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, got)
+			break
+		}
+	}
+
+	// The rules agree with the stored problems now
+	if report, err = job.Run(opts); err != nil || report.AddedCount != 0 || report.RemovedCount != 0 || report.Unchanged != 2 || report.Retagged != 0 {
+		t.Errorf("Expected a second run to change nothing, got %+v (%v)", report, err)
+	}
+}
+
+// Test 2: Placeholders of streamed responses left behind by a failed update aren't flagged
+func TestJob_RunSkipsStreamingPlaceholders(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer db.Close()
+	requests := repository.NewRequestRepository(db)
+	problems := repository.NewProblemRepository(db)
+
+	placeholder := &models.APIRequest{Method: "GET", Path: "/anime/1", Outcome: models.OutcomeStreaming, CreatedAt: time.Now()}
+	if _, err := requests.Create(placeholder); err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	report, err := NewJob(requests, problems).Run(Options{Apply: true, Rules: DefaultRules})
+	if err != nil {
+		t.Fatalf("Failed to run: %v", err)
+	}
+	if report.Checked != 1 || report.AddedCount != 0 {
+		t.Errorf("Expected the placeholder to be checked without problems, got %+v", report)
+	}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"strings"
+	"time"
+	"treblle_project/internal/query"
+)
+
+// ValueFilter matches a column against any of Include (everything when
+// empty) and none of Exclude
+type ValueFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// conditions returns the WHERE conditions and arguments matching column against f
+func (f ValueFilter) conditions(column string) ([]string, []any) {
+	var where []string
+	var args []any
+	if len(f.Include) > 0 {
+		where = append(where, column+" IN ("+placeholders(len(f.Include))+")")
+		for _, v := range f.Include {
+			args = append(args, v)
+		}
+	}
+	if len(f.Exclude) > 0 {
+		where = append(where, column+" NOT IN ("+placeholders(len(f.Exclude))+")")
+		for _, v := range f.Exclude {
+			args = append(args, v)
+		}
+	}
+	return where, args
+}
+
+// StatusRange is an inclusive range of response statuses, Min == Max for a single status
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// StatusFilter matches statuses in any of Include (everything when empty)
+// and in none of Exclude
+type StatusFilter struct {
+	Include []StatusRange
+	Exclude []StatusRange
+}
+
+// conditions returns the WHERE conditions and arguments matching column against f
+func (f StatusFilter) conditions(column string) ([]string, []any) {
+	var where []string
+	var args []any
+	if len(f.Include) > 0 {
+		alternatives := make([]string, len(f.Include))
+		for i, r := range f.Include {
+			alternatives[i] = column + " BETWEEN ? AND ?"
+			args = append(args, r.Min, r.Max)
+		}
+		where = append(where, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	for _, r := range f.Exclude {
+		where = append(where, column+" NOT BETWEEN ? AND ?")
+		args = append(args, r.Min, r.Max)
+	}
+	return where, args
+}
+
+// pathConditions returns the WHERE conditions matching column against a
+// regular expression and a GLOB pattern, each optional
+func pathConditions(column, regex, glob string) ([]string, []any) {
+	var where []string
+	var args []any
+	if regex != "" {
+		where = append(where, column+" REGEXP ?")
+		args = append(args, regex)
+	}
+	if glob != "" {
+		where = append(where, column+" GLOB ?")
+		args = append(args, glob)
+	}
+	return where, args
+}
+
+// expressionConditions returns the WHERE condition of a compiled q= expression,
+// with time arguments converted to how created_at is stored
+func expressionConditions(cond *query.Condition) ([]string, []any) {
+	if cond == nil {
+		return nil, nil
+	}
+	args := make([]any, len(cond.Args))
+	for i, arg := range cond.Args {
+		if t, ok := arg.(time.Time); ok {
+			arg = storedTime(t)
+		}
+		args[i] = arg
+	}
+	return []string{"(" + cond.SQL + ")"}, args
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns used with likeEscape
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeEscape is the ESCAPE clause of LIKE patterns built with escapeLike
+const likeEscape = ` ESCAPE '\'`
+
+// escapeLike makes s match itself literally in a LIKE pattern
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a cursor can't be decoded or doesn't match the sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page is one page of a keyset paginated listing
+type Page[T any] struct {
+	Items      []T
+	NextCursor string // Empty on the last page
+	PrevCursor string // Empty on the first page
+	Total      *int   // Rows matching the filters, only when requested
+}
+
+// keyKind tells how a sort key value round-trips through a cursor
+type keyKind int
+
+const (
+	kindInt keyKind = iota
+	kindString
+	kindTime
+)
+
+// sortColumn is a column rows can be ordered by
+type sortColumn struct {
+	column string
+	kind   keyKind
+}
+
+// sortKey is a sortColumn with a direction
+type sortKey struct {
+	sortColumn
+	desc bool
+}
+
+// cursor points between two rows: after the row with these sort values and
+// id, or before it when paging backwards. Sort records the order it was issued for.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+	ID     int    `json:"id"`
+	Before bool   `json:"b,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	encoded, _ := json.Marshal(c) // Values are ints, strings and times
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor parses a cursor for the given sort keys, restoring value types
+func decodeCursor(s string, keys []sortKey) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != sortSignature(keys) || len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	for i, key := range keys {
+		switch v := c.Values[i].(type) {
+		case float64:
+			if key.kind != kindInt {
+				return nil, ErrInvalidCursor
+			}
+			c.Values[i] = int64(v)
+		case string:
+			switch key.kind {
+			case kindString:
+			case kindTime:
+				t, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return nil, ErrInvalidCursor
+				}
+				c.Values[i] = t
+			default:
+				return nil, ErrInvalidCursor
+			}
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// sortSignature identifies an order, so cursors can't be replayed against another one
+func sortSignature(keys []sortKey) string {
+	return orderByClause(keys, false)
+}
+
+// withTieBreaker appends the id column so the order is total, following the first key's direction
+func withTieBreaker(keys []sortKey, idColumn string) []sortKey {
+	return append(slices.Clone(keys), sortKey{sortColumn{idColumn, kindInt}, keys[0].desc})
+}
+
+// orderByClause builds the ORDER BY list for keys, reversed when paging backwards
+func orderByClause(keys []sortKey, reverse bool) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		dir := "ASC"
+		if key.desc != reverse {
+			dir = "DESC"
+		}
+		parts[i] = key.column + " " + dir
+	}
+	return strings.Join(parts, ", ")
+}
+
+// keysetCondition matches the rows after c in the order of keys (before it when c.Before).
+// keys must include the tie breaker, whose value is c.ID.
+func keysetCondition(keys []sortKey, c *cursor) (string, []any) {
+	values := append(slices.Clone(c.Values), c.ID)
+
+	var alternatives []string
+	var args []any
+	for i, key := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].column+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if key.desc != c.Before {
+			op = "<"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s ?", key.column, op))
+		args = append(args, values[i])
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// paginate turns the rows fetched for a page (limit+1 at most, in query order)
+// into a Page, computing cursors from each row's sort values and id
+func paginate[T any](items []T, limit int, c *cursor, keys []sortKey, keyOf func(T) ([]any, int)) Page[T] {
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	before := c != nil && c.Before
+	if before {
+		slices.Reverse(items)
+	}
+
+	page := Page[T]{Items: items}
+	if len(items) == 0 {
+		return page
+	}
+
+	signature := sortSignature(keys)
+	first, firstID := keyOf(items[0])
+	last, lastID := keyOf(items[len(items)-1])
+	if hasMore || before {
+		page.NextCursor = encodeCursor(cursor{Sort: signature, Values: last, ID: lastID})
+	}
+	if (before && hasMore) || (!before && c != nil) {
+		page.PrevCursor = encodeCursor(cursor{Sort: signature, Values: first, ID: firstID, Before: true})
+	}
+	return page
+}
+
+// keysetQuery completes a query filtered by where (empty or starting with
+// " WHERE ") with the keyset condition of c and the ORDER BY of keys
+func keysetQuery(query, where string, args []any, keys []sortKey, c *cursor) (string, []any) {
+	query += where
+	args = slices.Clone(args)
+
+	if c != nil {
+		condition, conditionArgs := keysetCondition(keys, c)
+		if where == "" {
+			query += " WHERE " + condition
+		} else {
+			query += " AND " + condition
+		}
+		args = append(args, conditionArgs...)
+	}
+
+	query += " ORDER BY " + orderByClause(keys, c != nil && c.Before)
+	return query, args
+}
+
+// streamCursor decodes the cursor an export starts from. Exports run
+// forwards, so cursors pointing at a previous page are rejected.
+func streamCursor(s string, keys []sortKey) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	c, err := decodeCursor(s, keys)
+	if err != nil {
+		return nil, err
+	}
+	if c.Before {
+		return nil, fmt.Errorf("%w: exports continue from next cursors only", ErrInvalidCursor)
+	}
+	return c, nil
+}
+
+// limitOffset appends LIMIT and OFFSET clauses to an export query. SQLite
+// needs a LIMIT before OFFSET, -1 standing for no limit.
+func limitOffset(query string, args []any, limit, offset int, c *cursor) (string, []any) {
+	if limit <= 0 {
+		limit = -1
+	}
+	if limit < 0 && (c != nil || offset <= 0) {
+		return query, args
+	}
+	query += " LIMIT ?"
+	args = append(args, limit)
+	if c == nil && offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, offset)
+	}
+	return query, args
+}
@@ -1,13 +1,21 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 	"treblle_project/internal/database"
 	"treblle_project/internal/models"
+	"treblle_project/internal/query"
 )
 
+// ErrProblemExists is returned when a request already has a problem of the same type
+var ErrProblemExists = errors.New("problem already recorded")
+
+// GroupByRequest groups problems by the request they were detected on, see ListGroupsPage
+const GroupByRequest = "request"
+
 type ProblemRepository struct {
 	db *database.DB
 }
@@ -19,22 +27,41 @@ func NewProblemRepository(db *database.DB) *ProblemRepository {
 type ProblemFilters struct {
 	Method        string
 	Response      int
+	Methods       ValueFilter  // HTTP methods, combined with Method
+	Statuses      StatusFilter // Response statuses, combined with Response
+	ProblemTypes  ValueFilter
+	RuleVersions  ValueFilter // Versions of the detection rules that recorded the problems
+	RequestIDs    []int       // Only problems of these requests
+	PathRegex     string      // Regular expression the path must match
+	PathGlob      string      // GLOB pattern the path must match
 	MinTime       int64
 	MaxTime       int64
-	CreatedAfter  time.Time
-	CreatedBefore time.Time
+	CreatedAfter  time.Time // Inclusive lower bound on created_at
+	CreatedBefore time.Time // Exclusive upper bound on created_at
 	Search        string
+	Expression    *query.Condition // Compiled q= expression over ProblemQueryFields
 	SortBy        string
 	Limit         int
 	Offset        int
+	Cursor        string // Opaque keyset cursor from a previous page, takes precedence over Offset
+	WithTotal     bool   // Also count every matching row
+	GroupBy       string // GroupByRequest to list the requests with their problems through ListGroupsPage and EachGroup instead
+}
+
+// insertProblem inserts a problems row, the arguments are those of problemArgs
+const insertProblem = `INSERT INTO problems (request_id, problem_type, description, threshold_ms, created_at, rule_version)
+	VALUES (?, ?, ?, ?, ?, ?)`
+
+// problemArgs returns the arguments of insertProblem for problem
+func problemArgs(problem *models.Problem) []any {
+	return []any{problem.RequestID, problem.ProblemType, problem.Description, problem.ThresholdMs, storedTime(problem.CreatedAt), problem.RuleVersion}
 }
 
 func (r *ProblemRepository) Create(problem *models.Problem) (int64, error) {
-	result, err := r.db.Exec(
-		`INSERT INTO problems (request_id, problem_type, description, threshold_ms, created_at)
-		VALUES (?, ?, ?, ?, ?)`,
-		problem.RequestID, problem.ProblemType, problem.Description, problem.ThresholdMs, problem.CreatedAt,
-	)
+	result, err := r.db.Exec(insertProblem, problemArgs(problem)...)
+	if isUniqueViolation(err) {
+		return 0, fmt.Errorf("%w: %s on request %d", ErrProblemExists, problem.ProblemType, problem.RequestID)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to create problem: %w", err)
 	}
@@ -47,14 +74,281 @@ func (r *ProblemRepository) Create(problem *models.Problem) (int64, error) {
 	return id, nil
 }
 
+// ProblemChanges are changes to stored problems made together
+type ProblemChanges struct {
+	Remove      []int             // IDs of the problems to delete
+	Add         []*models.Problem // Problems to insert, their IDs are set
+	Retag       []int             // IDs of the problems to tag with RuleVersion
+	RuleVersion string
+}
+
+// Replace applies changes in one transaction. Nothing changes when one of
+// changes.Add is already recorded, ErrProblemExists is returned.
+func (r *ProblemRepository) Replace(changes ProblemChanges) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range changes.Remove {
+		if _, err := tx.Exec("DELETE FROM problems WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete problem: %w", err)
+		}
+	}
+	for _, id := range changes.Retag {
+		if _, err := tx.Exec("UPDATE problems SET rule_version = ? WHERE id = ?", changes.RuleVersion, id); err != nil {
+			return fmt.Errorf("failed to update problem: %w", err)
+		}
+	}
+	for _, problem := range changes.Add {
+		result, err := tx.Exec(insertProblem, problemArgs(problem)...)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %s on request %d", ErrProblemExists, problem.ProblemType, problem.RequestID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create problem: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		problem.ID = int(id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// problemSortColumns are the fields problems can be sorted by
+var problemSortColumns = map[string]sortColumn{
+	"id":            {"p.id", kindInt},
+	"created_at":    {"p.created_at", kindTime},
+	"response_time": {"r.response_time_ms", kindInt},
+	"method":        {"r.method", kindString},
+	"path":          {"r.path", kindString},
+	"status":        {"r.response_status", kindInt},
+	"response":      {"r.response_status", kindInt},
+	"problem_type":  {"p.problem_type", kindString},
+	"threshold":     {"p.threshold_ms", kindInt},
+}
+
+// ProblemQueryFields are the fields q= expressions over problems can use,
+// created_at being the time the problem was detected
+var ProblemQueryFields = query.Fields{
+	"method":         {Column: "r.method", Type: query.String},
+	"path":           {Column: "r.path", Type: query.String},
+	"status":         {Column: "r.response_status", Type: query.Int},
+	"response":       {Column: "r.response_status", Type: query.Int},
+	"response_time":  {Column: "r.response_time_ms", Type: query.Int},
+	"response_bytes": {Column: "r.response_bytes", Type: query.Int},
+	"content_type":   {Column: "r.content_type", Type: query.String},
+	"created_at":     {Column: "p.created_at", Type: query.Time},
+	"request_id":     {Column: "p.request_id", Type: query.Int},
+	"problem_type":   {Column: "p.problem_type", Type: query.String},
+	"description":    {Column: "p.description", Type: query.String},
+	"threshold":      {Column: "p.threshold_ms", Type: query.Int},
+	"rule_version":   {Column: "p.rule_version", Type: query.String},
+}
+
+// ValidateProblemSort checks a sort spec against the fields problems can be sorted by
+func ValidateProblemSort(spec string) error {
+	_, err := problemSortKeys(spec)
+	return err
+}
+
+// problemSortKeys parses filters.SortBy, newest first by default
+func problemSortKeys(sortBy string) ([]sortKey, error) {
+	return parseSort(sortBy, problemSortColumns, []sortKey{{problemSortColumns["created_at"], true}})
+}
+
+// problemSortValue returns the value of a sort column for a problem
+func problemSortValue(p models.Problem, column string) any {
+	switch column {
+	case "r.response_time_ms":
+		return p.ResponseTimeMs
+	case "r.method":
+		return p.Method
+	case "r.path":
+		return p.Path
+	case "r.response_status":
+		return p.ResponseStatus
+	case "p.problem_type":
+		return p.ProblemType
+	case "p.threshold_ms":
+		return p.ThresholdMs
+	case "p.id":
+		return p.ID
+	default:
+		return p.CreatedAt
+	}
+}
+
 func (r *ProblemRepository) List(filters ProblemFilters) ([]models.Problem, error) {
-	query := `
-		SELECT 
-			p.id, p.request_id, p.problem_type, p.description, p.threshold_ms, p.created_at,
-			r.method, r.path, r.response_status, r.response_time_ms
-		FROM problems p
-		INNER JOIN api_requests r ON p.request_id = r.id
-	`
+	page, err := r.ListPage(filters)
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// ListPage returns one page of problems. Pages are keyset paginated with
+// filters.Cursor, which takes precedence over filters.Offset.
+func (r *ProblemRepository) ListPage(filters ProblemFilters) (*Page[models.Problem], error) {
+	sortKeys, err := problemSortKeys(filters.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	keys := withTieBreaker(sortKeys, "p.id")
+
+	var c *cursor
+	if filters.Cursor != "" {
+		if c, err = decodeCursor(filters.Cursor, sortKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	where, whereArgs := problemWhere(filters)
+	query, args := keysetQuery(problemSelect, where, whereArgs, keys, c)
+
+	// Pagination, one extra row tells whether there is another page
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 100 // Default limit
+	}
+	query += " LIMIT ?"
+	args = append(args, limit+1)
+
+	if c == nil && filters.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, filters.Offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query problems: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []models.Problem
+	for rows.Next() {
+		p, err := scanProblem(rows)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, *p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	rows.Close() // Release the connection before counting
+
+	page := paginate(problems, limit, c, sortKeys, func(p models.Problem) ([]any, int) {
+		values := make([]any, len(sortKeys))
+		for i, key := range sortKeys {
+			values[i] = problemSortValue(p, key.column)
+		}
+		return values, p.ID
+	})
+
+	if filters.WithTotal {
+		total, err := r.Count(filters)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	return &page, nil
+}
+
+// Count returns the number of problems matching filters, ignoring sorting and pagination
+func (r *ProblemRepository) Count(filters ProblemFilters) (int, error) {
+	where, args := problemWhere(filters)
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*)"+problemFrom+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count problems: %w", err)
+	}
+	return total, nil
+}
+
+// Each calls fn with every problem matching filters in sort order, reading
+// rows one at a time so memory stays bounded whatever the number of rows.
+// A zero filters.Limit means no limit. Iteration stops at the first error.
+func (r *ProblemRepository) Each(filters ProblemFilters, fn func(*models.Problem) error) error {
+	sortKeys, err := problemSortKeys(filters.SortBy)
+	if err != nil {
+		return err
+	}
+	c, err := streamCursor(filters.Cursor, sortKeys)
+	if err != nil {
+		return err
+	}
+
+	where, whereArgs := problemWhere(filters)
+	query, args := keysetQuery(problemSelect, where, whereArgs, withTieBreaker(sortKeys, "p.id"), c)
+	query, args = limitOffset(query, args, filters.Limit, filters.Offset, c)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query problems: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProblem(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
+}
+
+// problemFrom joins problems with the request they were detected on
+const problemFrom = `
+	FROM problems p
+	INNER JOIN api_requests r ON p.request_id = r.id
+`
+
+// problemSelect selects the columns read by scanProblem
+const problemSelect = `
+	SELECT
+		p.id, p.request_id, p.problem_type, p.description, p.threshold_ms, p.created_at, p.rule_version,
+		r.method, r.path, r.response_status, r.response_time_ms` + problemFrom
+
+// scanProblem reads one row selected with problemSelect
+func scanProblem(row rowScanner) (*models.Problem, error) {
+	var p models.Problem
+	err := row.Scan(
+		&p.ID,
+		&p.RequestID,
+		&p.ProblemType,
+		&p.Description,
+		&p.ThresholdMs,
+		&p.CreatedAt,
+		&p.RuleVersion,
+		&p.Method,
+		&p.Path,
+		&p.ResponseStatus,
+		&p.ResponseTimeMs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan problem: %w", err)
+	}
+	return &p, nil
+}
+
+// problemWhere builds the WHERE clause (with leading space) and its arguments for filters
+func problemWhere(filters ProblemFilters) (string, []any) {
 	where := []string{}
 	args := []any{}
 
@@ -68,6 +362,29 @@ func (r *ProblemRepository) List(filters ProblemFilters) ([]models.Problem, erro
 		args = append(args, filters.Response)
 	}
 
+	methodWhere, methodArgs := filters.Methods.conditions("r.method")
+	where = append(where, methodWhere...)
+	args = append(args, methodArgs...)
+
+	statusWhere, statusArgs := filters.Statuses.conditions("r.response_status")
+	where = append(where, statusWhere...)
+	args = append(args, statusArgs...)
+
+	typeWhere, typeArgs := filters.ProblemTypes.conditions("p.problem_type")
+	where = append(where, typeWhere...)
+	args = append(args, typeArgs...)
+
+	versionWhere, versionArgs := filters.RuleVersions.conditions("p.rule_version")
+	where = append(where, versionWhere...)
+	args = append(args, versionArgs...)
+
+	if len(filters.RequestIDs) > 0 {
+		where = append(where, "p.request_id IN ("+placeholders(len(filters.RequestIDs))+")")
+		for _, id := range filters.RequestIDs {
+			args = append(args, id)
+		}
+	}
+
 	if filters.MinTime > 0 {
 		where = append(where, "r.response_time_ms >= ?")
 		args = append(args, filters.MinTime)
@@ -80,78 +397,253 @@ func (r *ProblemRepository) List(filters ProblemFilters) ([]models.Problem, erro
 
 	if !filters.CreatedAfter.IsZero() {
 		where = append(where, "p.created_at >= ?")
-		args = append(args, filters.CreatedAfter)
+		args = append(args, storedTime(filters.CreatedAfter))
 	}
 
 	if !filters.CreatedBefore.IsZero() {
-		where = append(where, "p.created_at <= ?")
-		args = append(args, filters.CreatedBefore)
+		where = append(where, "p.created_at < ?")
+		args = append(args, storedTime(filters.CreatedBefore))
 	}
 
 	if filters.Search != "" {
-		where = append(where, "r.path LIKE ?")
-		args = append(args, "%"+filters.Search+"%")
+		where = append(where, "r.path LIKE ?"+likeEscape)
+		args = append(args, "%"+escapeLike(filters.Search)+"%")
 	}
 
-	if len(where) > 0 {
-		query += " WHERE " + strings.Join(where, " AND ")
+	pathWhere, pathArgs := pathConditions("r.path", filters.PathRegex, filters.PathGlob)
+	where = append(where, pathWhere...)
+	args = append(args, pathArgs...)
+
+	exprWhere, exprArgs := expressionConditions(filters.Expression)
+	where = append(where, exprWhere...)
+	args = append(args, exprArgs...)
+
+	if len(where) == 0 {
+		return "", args
 	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// problemGroupSortColumns are the fields problems grouped by request can be sorted by
+var problemGroupSortColumns = map[string]sortColumn{
+	"created_at":    {"g.created_at", kindTime},
+	"response_time": {"g.response_time_ms", kindInt},
+	"method":        {"g.method", kindString},
+	"path":          {"g.path", kindString},
+	"status":        {"g.response_status", kindInt},
+	"response":      {"g.response_status", kindInt},
+	"problem_count": {"g.problem_count", kindInt},
+}
+
+// ValidateProblemGroupSort checks a sort spec against the fields problems
+// grouped by request can be sorted by
+func ValidateProblemGroupSort(spec string) error {
+	_, err := problemGroupSortKeys(spec)
+	return err
+}
 
-	// Sorting
-	var sortBy string
-	switch filters.SortBy {
-	case "response_time":
-		sortBy = "response_time_ms DESC"
-	case "created_at":
-		sortBy = "p.created_at DESC"
+// problemGroupSortKeys parses filters.SortBy of grouped problems, newest request first by default
+func problemGroupSortKeys(sortBy string) ([]sortKey, error) {
+	return parseSort(sortBy, problemGroupSortColumns, []sortKey{{problemGroupSortColumns["created_at"], true}})
+}
+
+// problemGroupSortValue returns the value of a sort column for a group
+func problemGroupSortValue(g models.RequestProblems, column string) any {
+	switch column {
+	case "g.response_time_ms":
+		return g.ResponseTimeMs
+	case "g.method":
+		return g.Method
+	case "g.path":
+		return g.Path
+	case "g.response_status":
+		return g.ResponseStatus
+	case "g.problem_count":
+		return g.ProblemCount
 	default:
-		sortBy = "p.created_at DESC"
+		return g.CreatedAt
+	}
+}
+
+// problemGroupQuery selects one row per request with problems matching
+// where, read by scanProblemGroup. Filters apply to problems, so a group
+// only holds the matching ones.
+func problemGroupQuery(where string) string {
+	return `
+	SELECT g.request_id, g.method, g.path, g.response_status, g.response_time_ms, g.created_at, g.problem_count, g.problem_types
+	FROM (
+		SELECT
+			p.request_id, r.method, r.path, r.response_status, r.response_time_ms, r.created_at,
+			COUNT(*) AS problem_count, GROUP_CONCAT(p.problem_type, ',' ORDER BY p.id) AS problem_types` + problemFrom + where + `
+		GROUP BY p.request_id
+	) g`
+}
+
+// scanProblemGroup reads one row selected with problemGroupQuery
+func scanProblemGroup(row rowScanner) (*models.RequestProblems, error) {
+	var g models.RequestProblems
+	var types string
+	err := row.Scan(
+		&g.RequestID,
+		&g.Method,
+		&g.Path,
+		&g.ResponseStatus,
+		&g.ResponseTimeMs,
+		&g.CreatedAt,
+		&g.ProblemCount,
+		&types,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan problem group: %w", err)
+	}
+	g.ProblemTypes = strings.Split(types, ",")
+	return &g, nil
+}
+
+// ListGroupsPage returns one page of the requests with problems matching
+// filters, each with its matching problems. Pages are keyset paginated like
+// ListPage, over the sort fields of ValidateProblemGroupSort.
+func (r *ProblemRepository) ListGroupsPage(filters ProblemFilters) (*Page[models.RequestProblems], error) {
+	sortKeys, err := problemGroupSortKeys(filters.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	keys := withTieBreaker(sortKeys, "g.request_id")
+
+	var c *cursor
+	if filters.Cursor != "" {
+		if c, err = decodeCursor(filters.Cursor, sortKeys); err != nil {
+			return nil, err
+		}
 	}
-	query += " ORDER BY " + sortBy
 
-	// Pagination
-	if filters.Limit > 0 {
-		query += " LIMIT ?"
-		args = append(args, filters.Limit)
-	} else {
-		query += " LIMIT 100" // Default limit
+	where, whereArgs := problemWhere(filters)
+	query, args := keysetQuery(problemGroupQuery(where), "", whereArgs, keys, c)
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 100 // Default limit
 	}
+	query += " LIMIT ?"
+	args = append(args, limit+1)
 
-	if filters.Offset > 0 {
+	if c == nil && filters.Offset > 0 {
 		query += " OFFSET ?"
 		args = append(args, filters.Offset)
 	}
 
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query problems: %w", err)
+		return nil, fmt.Errorf("failed to query problem groups: %w", err)
 	}
 	defer rows.Close()
 
-	var problems []models.Problem
+	var groups []models.RequestProblems
 	for rows.Next() {
-		var p models.Problem
-		err := rows.Scan(
-			&p.ID,
-			&p.RequestID,
-			&p.ProblemType,
-			&p.Description,
-			&p.ThresholdMs,
-			&p.CreatedAt,
-			&p.Method,
-			&p.Path,
-			&p.ResponseStatus,
-			&p.ResponseTimeMs,
-		)
+		g, err := scanProblemGroup(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan problem: %w", err)
+			return nil, err
 		}
-		problems = append(problems, p)
+		groups = append(groups, *g)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
+	rows.Close() // Release the connection before reading the problems
+
+	page := paginate(groups, limit, c, sortKeys, func(g models.RequestProblems) ([]any, int) {
+		values := make([]any, len(sortKeys))
+		for i, key := range sortKeys {
+			values[i] = problemGroupSortValue(g, key.column)
+		}
+		return values, g.RequestID
+	})
+
+	if err := r.attachProblems(page.Items, filters); err != nil {
+		return nil, err
+	}
+
+	if filters.WithTotal {
+		total, err := r.CountGroups(filters)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	return &page, nil
+}
+
+// attachProblems sets the problems of groups matching filters, in detection
+// order like their ProblemTypes
+func (r *ProblemRepository) attachProblems(groups []models.RequestProblems, filters ProblemFilters) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(groups))
+	ids := make([]int, len(groups))
+	for i, g := range groups {
+		index[g.RequestID] = i
+		ids[i] = g.RequestID
+	}
 
-	return problems, nil
+	byRequest := filters
+	byRequest.RequestIDs = ids
+	byRequest.SortBy = "id"
+	byRequest.Limit, byRequest.Offset, byRequest.Cursor = 0, 0, ""
+	return r.Each(byRequest, func(p *models.Problem) error {
+		g := &groups[index[p.RequestID]]
+		g.Problems = append(g.Problems, *p)
+		return nil
+	})
+}
+
+// CountGroups returns the number of requests with problems matching filters
+func (r *ProblemRepository) CountGroups(filters ProblemFilters) (int, error) {
+	where, args := problemWhere(filters)
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(DISTINCT p.request_id)"+problemFrom+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count problem groups: %w", err)
+	}
+	return total, nil
+}
+
+// EachGroup calls fn with every request with problems matching filters in
+// sort order, like Each. The problems themselves are not read, only their
+// count and types.
+func (r *ProblemRepository) EachGroup(filters ProblemFilters, fn func(*models.RequestProblems) error) error {
+	sortKeys, err := problemGroupSortKeys(filters.SortBy)
+	if err != nil {
+		return err
+	}
+	c, err := streamCursor(filters.Cursor, sortKeys)
+	if err != nil {
+		return err
+	}
+
+	where, whereArgs := problemWhere(filters)
+	query, args := keysetQuery(problemGroupQuery(where), "", whereArgs, withTieBreaker(sortKeys, "g.request_id"), c)
+	query, args = limitOffset(query, args, filters.Limit, filters.Offset, c)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query problem groups: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g, err := scanProblemGroup(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(g); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
 }
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
+	"treblle_project/internal/models"
 )
 
 // Test 1: Basic Create
@@ -184,7 +187,7 @@ func TestProblemRepository_SortByResponseTime(t *testing.T) {
 		"not_found", "Problem 3", 0)
 
 	// Sort by response time (descending)
-	filters := ProblemFilters{SortBy: "response_time", Limit: 100}
+	filters := ProblemFilters{SortBy: "-response_time", Limit: 100}
 	results, err := problemRepo.List(filters)
 	if err != nil {
 		t.Fatalf("Failed to list problems: %v", err)
@@ -250,3 +253,206 @@ func TestProblemRepository_JoinedFields(t *testing.T) {
 		t.Errorf("Expected joined response time 150, got %d", p.ResponseTimeMs)
 	}
 }
+
+// Test 8: Multiple sort keys with mixed directions
+func TestProblemRepository_SortByMultipleKeys(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	problemRepo := NewProblemRepository(db)
+
+	createTestProblem(t, db, int(createTestRequest(t, db, "GET", "/b", 404, 100)), "not_found", "Problem 1", 0)
+	createTestProblem(t, db, int(createTestRequest(t, db, "GET", "/a", 200, 900)), "slow_response", "Problem 2", 400)
+	createTestProblem(t, db, int(createTestRequest(t, db, "GET", "/c", 404, 100)), "not_found", "Problem 3", 0)
+
+	results, err := problemRepo.List(ProblemFilters{SortBy: "-problem_type,path", Limit: 100})
+	if err != nil {
+		t.Fatalf("Failed to list problems: %v", err)
+	}
+
+	var paths []string
+	for _, p := range results {
+		paths = append(paths, p.Path)
+	}
+	if strings.Join(paths, ",") != "/a,/b,/c" {
+		t.Errorf("Expected /a,/b,/c, got %v", paths)
+	}
+
+	// Keyset pages follow the same order
+	page, err := problemRepo.ListPage(ProblemFilters{SortBy: "-problem_type,path", Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to list page: %v", err)
+	}
+	next, err := problemRepo.ListPage(ProblemFilters{SortBy: "-problem_type,path", Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("Failed to list next page: %v", err)
+	}
+	if len(next.Items) != 1 || next.Items[0].Path != "/c" {
+		t.Errorf("Expected /c on the second page, got %+v", next.Items)
+	}
+
+	for _, spec := range []string{"latency", "path,-path", "-"} {
+		if _, err := problemRepo.List(ProblemFilters{SortBy: spec}); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("Expected ErrInvalidSort for %q, got %v", spec, err)
+		}
+	}
+}
+
+// Test 9: Multi-value, class, negation and path pattern filters
+func TestProblemRepository_MultiValueFilters(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	problemRepo := NewProblemRepository(db)
+
+	createTestProblem(t, db, int(createTestRequest(t, db, "GET", "/anime/1", 404, 100)), "not_found", "Not found", 0)
+	createTestProblem(t, db, int(createTestRequest(t, db, "GET", "/anime/1/characters", 500, 100)), "server_error", "Server error", 0)
+	createTestProblem(t, db, int(createTestRequest(t, db, "POST", "/top/anime", 503, 100)), "server_error", "Server error", 0)
+	createTestProblem(t, db, int(createTestRequest(t, db, "GET", "/top/manga", 200, 900)), "slow_response", "Slow", 400)
+
+	tests := []struct {
+		name     string
+		filters  ProblemFilters
+		expected string
+	}{
+		{"status list", ProblemFilters{Statuses: StatusFilter{Include: []StatusRange{{404, 404}, {503, 503}}}}, "/anime/1,/top/anime"},
+		{"status class", ProblemFilters{Statuses: StatusFilter{Include: []StatusRange{{500, 599}}}}, "/anime/1/characters,/top/anime"},
+		{"negated status", ProblemFilters{Statuses: StatusFilter{Exclude: []StatusRange{{200, 200}, {500, 500}}}}, "/anime/1,/top/anime"},
+		{"methods", ProblemFilters{Methods: ValueFilter{Exclude: []string{"GET"}}}, "/top/anime"},
+		{"problem types", ProblemFilters{ProblemTypes: ValueFilter{Include: []string{"server_error", "slow_response"}}}, "/anime/1/characters,/top/anime,/top/manga"},
+		{"negated problem type", ProblemFilters{ProblemTypes: ValueFilter{Exclude: []string{"server_error"}}}, "/anime/1,/top/manga"},
+		{"path regex", ProblemFilters{PathRegex: `^/anime/\d+$`}, "/anime/1"},
+		{"path glob", ProblemFilters{PathGlob: "/top/*"}, "/top/anime,/top/manga"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filters.SortBy = "path"
+			results, err := problemRepo.List(tt.filters)
+			if err != nil {
+				t.Fatalf("Failed to list problems: %v", err)
+			}
+
+			var paths []string
+			for _, p := range results {
+				paths = append(paths, p.Path)
+			}
+			if got := strings.Join(paths, ","); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+// Test 10: Replace swaps problems in one go, and rule version and request filters narrow the list
+func TestProblemRepository_Replace(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewProblemRepository(db)
+
+	first := int(createTestRequest(t, db, "GET", "/anime/1", 404, 100))
+	second := int(createTestRequest(t, db, "GET", "/anime/2", 200, 900))
+	oldID := int(createTestProblem(t, db, second, "slow_response", "Slow", 400))
+	createTestProblem(t, db, first, "not_found", "Not found", 0)
+
+	added := &models.Problem{RequestID: second, ProblemType: "slow_response", Description: "Slow", ThresholdMs: 800, CreatedAt: time.Now(), RuleVersion: "2"}
+	if err := repo.Replace(ProblemChanges{Remove: []int{oldID}, Add: []*models.Problem{added}}); err != nil {
+		t.Fatalf("Failed to replace problems: %v", err)
+	}
+	if added.ID == 0 {
+		t.Error("Expected the added problem to get an id")
+	}
+
+	// A problem recorded meanwhile rolls the whole swap back
+	duplicate := &models.Problem{RequestID: first, ProblemType: "not_found", Description: "Not found", CreatedAt: time.Now(), RuleVersion: "2"}
+	if err := repo.Replace(ProblemChanges{Remove: []int{added.ID}, Add: []*models.Problem{duplicate}}); !errors.Is(err, ErrProblemExists) {
+		t.Errorf("Expected a duplicate problem to be rejected, got %v", err)
+	}
+
+	problems, err := repo.List(ProblemFilters{RequestIDs: []int{second}})
+	if err != nil || len(problems) != 1 || problems[0].ThresholdMs != 800 || problems[0].RuleVersion != "2" {
+		t.Errorf("Expected only the new problem of the second request, got %+v (%v)", problems, err)
+	}
+
+	tests := []struct {
+		filter   ValueFilter
+		expected string
+	}{
+		{ValueFilter{Include: []string{"2"}}, "slow_response"},
+		{ValueFilter{Include: []string{""}}, "not_found"},
+		{ValueFilter{Exclude: []string{"2"}}, "not_found"},
+	}
+	for _, tt := range tests {
+		problems, err := repo.List(ProblemFilters{RuleVersions: tt.filter})
+		if err != nil || len(problems) != 1 || problems[0].ProblemType != tt.expected {
+			t.Errorf("%+v: expected only %s, got %+v (%v)", tt.filter, tt.expected, problems, err)
+		}
+	}
+}
+
+// Test 11: A request has one problem of each type, and grouping lists each request once with its problems
+func TestProblemRepository_Groups(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewProblemRepository(db)
+
+	slow := int(createTestRequest(t, db, "GET", "/anime/1", 404, 3000))
+	createTestProblem(t, db, slow, "not_found", "Not found", 0)
+	createTestProblem(t, db, slow, "slow_response", "Slow", 400)
+	for i := 2; i <= 3; i++ {
+		id := int(createTestRequest(t, db, "GET", fmt.Sprintf("/anime/%d", i), 404, 100))
+		createTestProblem(t, db, id, "not_found", "Not found", 0)
+	}
+
+	_, err := repo.Create(&models.Problem{RequestID: slow, ProblemType: "not_found", Description: "Again", CreatedAt: time.Now()})
+	if !errors.Is(err, ErrProblemExists) {
+		t.Errorf("Expected a second not_found on the request to be rejected, got %v", err)
+	}
+
+	page, err := repo.ListGroupsPage(ProblemFilters{SortBy: "-problem_count", Limit: 2, WithTotal: true})
+	if err != nil {
+		t.Fatalf("Failed to list groups: %v", err)
+	}
+	if len(page.Items) != 2 || page.Total == nil || *page.Total != 3 || page.NextCursor == "" {
+		t.Fatalf("Expected a first page of 2 out of 3 requests, got %+v", page)
+	}
+	first := page.Items[0]
+	if first.RequestID != slow || first.ProblemCount != 2 || strings.Join(first.ProblemTypes, ",") != "not_found,slow_response" || len(first.Problems) != 2 {
+		t.Errorf("Expected the slow 404 with both problems first, got %+v", first)
+	}
+
+	next, err := repo.ListGroupsPage(ProblemFilters{SortBy: "-problem_count", Limit: 2, Cursor: page.NextCursor})
+	if err != nil || len(next.Items) != 1 || next.Items[0].RequestID == page.Items[1].RequestID {
+		t.Errorf("Expected the remaining request on the next page, got %+v (%v)", next, err)
+	}
+
+	// Filters apply to problems before grouping
+	var counts []int
+	err = repo.EachGroup(ProblemFilters{ProblemTypes: ValueFilter{Include: []string{"slow_response"}}}, func(g *models.RequestProblems) error {
+		counts = append(counts, g.ProblemCount)
+		return nil
+	})
+	if err != nil || len(counts) != 1 || counts[0] != 1 {
+		t.Errorf("Expected one request with one slow_response, got %v (%v)", counts, err)
+	}
+
+	// Problems follow their types in detection order, whatever their timestamps
+	reordered := int(createTestRequest(t, db, "GET", "/anime/4", 404, 3000))
+	for i, problemType := range []string{"not_found", "slow_response"} {
+		_, err := repo.Create(&models.Problem{RequestID: reordered, ProblemType: problemType, CreatedAt: time.Now().Add(-time.Duration(i) * time.Hour)})
+		if err != nil {
+			t.Fatalf("Failed to create problem: %v", err)
+		}
+	}
+	groups, err := repo.ListGroupsPage(ProblemFilters{RequestIDs: []int{reordered}})
+	if err != nil || len(groups.Items) != 1 {
+		t.Fatalf("Expected one group, got %+v (%v)", groups, err)
+	}
+	if g := groups.Items[0]; len(g.Problems) != 2 || g.Problems[0].ProblemType != g.ProblemTypes[0] || g.Problems[1].ProblemType != g.ProblemTypes[1] {
+		t.Errorf("Expected problems in the order of %v, got %+v", g.ProblemTypes, g.Problems)
+	}
+
+	if err := ValidateProblemGroupSort("threshold"); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("Expected threshold to be rejected as a group sort, got %v", err)
+	}
+}
@@ -2,13 +2,37 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 	"treblle_project/internal/database"
 	"treblle_project/internal/models"
+	"treblle_project/internal/query"
 )
 
+// requestColumns is the column list shared by every query returning api_requests rows,
+// in the order expected by scanRequest
+const requestColumns = `id, method, path, response_status, response_time_ms,
+	response_bytes, content_type, upstream_headers,
+	dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, conn_reused,
+	throttle_status, queue_wait_ms, outcome, cache_status, coalesced, upstream_call_id,
+	parent_id, attempt, created_at`
+
+// reachedUpstream matches rows whose latency was measured against the upstream
+const reachedUpstream = `throttle_status != 'rejected' AND outcome = '' AND cache_status NOT IN ('HIT', 'STALE') AND coalesced = 0`
+
+// proxiedRequest matches the rows of proxied requests, leaving out their retry
+// attempts and background cache refreshes, which no client made
+const proxiedRequest = `parent_id IS NULL AND cache_status != 'REFRESH'`
+
+// singleCall matches rows timing one upstream call: retry attempts, and
+// requests that weren't retried. A retried request's time spans every
+// attempt and the backoffs between them.
+const singleCall = `(parent_id IS NOT NULL OR NOT EXISTS (SELECT 1 FROM api_requests a WHERE a.parent_id = api_requests.id))`
+
 type RequestRepository struct {
 	db *database.DB
 }
@@ -18,24 +42,67 @@ func NewRequestRepository(db *database.DB) *RequestRepository {
 }
 
 type RequestFilters struct {
-	Method        string
-	Response      int
-	MinTime       int64
-	MaxTime       int64
-	CreatedAfter  time.Time
-	CreatedBefore time.Time
-	Search        string
-	SortBy        string
-	Limit         int
-	Offset        int
+	Method          string
+	Response        int
+	Methods         ValueFilter  // HTTP methods, combined with Method
+	Statuses        StatusFilter // Response statuses, combined with Response
+	PathRegex       string       // Regular expression the path must match
+	PathGlob        string       // GLOB pattern the path must match
+	MinTime         int64
+	MaxTime         int64
+	CreatedAfter    time.Time // Inclusive lower bound on created_at
+	CreatedBefore   time.Time // Exclusive upper bound on created_at
+	MinBytes        int64
+	MaxBytes        int64
+	ContentType     string
+	Headers         map[string]string // Upstream header name -> exact value
+	Throttle        string            // Rate limiting outcome: "queued", "rejected" or "none" for requests sent straight away
+	Outcome         string            // Outcome such as "circuit_open", or "none" for normal upstream exchanges
+	CacheStatus     string            // Cache outcome such as "HIT", "MISS" or "REFRESH", or "none" for requests made with caching off
+	Coalesced       *bool             // Only requests served (true) or not served (false) by another request's upstream call
+	UpstreamCallID  string            // Only requests produced by this upstream call
+	ParentID        int               // Only the retry attempts of this request
+	ExcludeAttempts bool              // Leave out retry attempt rows, keeping one row per proxied request
+	Search          string
+	Expression      *query.Condition // Compiled q= expression over RequestQueryFields
+	SortBy          string
+	Limit           int
+	Offset          int
+	Cursor          string // Opaque keyset cursor from a previous page, takes precedence over Offset
+	WithTotal       bool   // Also count every matching row
+}
+
+// insertRequest inserts an api_requests row, the arguments are those of requestArgs
+const insertRequest = `INSERT INTO api_requests (method, path, response_status, response_time_ms,
+		response_bytes, content_type, upstream_headers,
+		dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, conn_reused,
+		throttle_status, queue_wait_ms, outcome, cache_status, coalesced, upstream_call_id,
+		parent_id, attempt, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// requestArgs returns the arguments of insertRequest for req
+func requestArgs(req *models.APIRequest) ([]any, error) {
+	headers, err := encodeHeaders(req.UpstreamHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		req.Method, req.Path, req.ResponseStatus, req.ResponseTimeMs,
+		req.ResponseBytes, req.ContentType, headers,
+		req.Timing.DNSMs, req.Timing.ConnectMs, req.Timing.TLSMs, req.Timing.TTFBMs, req.Timing.TransferMs, req.Timing.ConnReused,
+		req.ThrottleStatus, req.QueueWaitMs, req.Outcome, req.CacheStatus, req.Coalesced, req.UpstreamCallID,
+		req.ParentID, max(req.Attempt, 1), storedTime(req.CreatedAt),
+	}, nil
 }
 
 func (r *RequestRepository) Create(req *models.APIRequest) (int64, error) {
-	result, err := r.db.Exec(
-		`INSERT INTO api_requests (method, path, response_status, response_time_ms, created_at)
-		VALUES (?, ?, ?, ?, ?)`,
-		req.Method, req.Path, req.ResponseStatus, req.ResponseTimeMs, req.CreatedAt,
-	)
+	args, err := requestArgs(req)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.db.Exec(insertRequest, args...)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -48,8 +115,375 @@ func (r *RequestRepository) Create(req *models.APIRequest) (int64, error) {
 	return id, nil
 }
 
+// BatchItem is a request to insert with the problems found on it
+type BatchItem struct {
+	Request  *models.APIRequest
+	Problems []*models.Problem // Their RequestID is set once the request is inserted
+}
+
+// CreateBatch inserts the requests of items and their problems in one
+// transaction, setting their IDs. Nothing is inserted when one fails.
+func (r *RequestRepository) CreateBatch(items []BatchItem) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	requestStmt, err := tx.Prepare(insertRequest)
+	if err != nil {
+		return fmt.Errorf("failed to prepare request insert: %w", err)
+	}
+	defer requestStmt.Close()
+
+	problemStmt, err := tx.Prepare(insertProblem)
+	if err != nil {
+		return fmt.Errorf("failed to prepare problem insert: %w", err)
+	}
+	defer problemStmt.Close()
+
+	for _, item := range items {
+		args, err := requestArgs(item.Request)
+		if err != nil {
+			return err
+		}
+		result, err := requestStmt.Exec(args...)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		item.Request.ID = int(id)
+
+		for _, problem := range item.Problems {
+			problem.RequestID = item.Request.ID
+			result, err := problemStmt.Exec(problemArgs(problem)...)
+			if err != nil {
+				return fmt.Errorf("failed to create problem: %w", err)
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return fmt.Errorf("failed to get last insert id: %w", err)
+			}
+			problem.ID = int(id)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Update overwrites a logged request. Rows are dated when their call
+// completed, so created_at is overwritten too unless req.CreatedAt is zero.
+func (r *RequestRepository) Update(req *models.APIRequest) error {
+	headers, err := encodeHeaders(req.UpstreamHeaders)
+	if err != nil {
+		return err
+	}
+
+	var createdAt any
+	if !req.CreatedAt.IsZero() {
+		createdAt = storedTime(req.CreatedAt)
+	}
+
+	_, err = r.db.Exec(
+		`UPDATE api_requests SET method = ?, path = ?, response_status = ?, response_time_ms = ?,
+			response_bytes = ?, content_type = ?, upstream_headers = ?,
+			dns_ms = ?, connect_ms = ?, tls_ms = ?, ttfb_ms = ?, transfer_ms = ?, conn_reused = ?,
+			throttle_status = ?, queue_wait_ms = ?, outcome = ?, cache_status = ?, coalesced = ?, upstream_call_id = ?,
+			parent_id = ?, attempt = ?, created_at = COALESCE(?, created_at)
+		WHERE id = ?`,
+		req.Method, req.Path, req.ResponseStatus, req.ResponseTimeMs,
+		req.ResponseBytes, req.ContentType, headers,
+		req.Timing.DNSMs, req.Timing.ConnectMs, req.Timing.TLSMs, req.Timing.TTFBMs, req.Timing.TransferMs, req.Timing.ConnReused,
+		req.ThrottleStatus, req.QueueWaitMs, req.Outcome, req.CacheStatus, req.Coalesced, req.UpstreamCallID,
+		req.ParentID, max(req.Attempt, 1), createdAt,
+		req.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update request: %w", err)
+	}
+
+	return nil
+}
+
+// requestSortColumns are the fields requests can be sorted by
+var requestSortColumns = map[string]sortColumn{
+	"created_at":     {"created_at", kindTime},
+	"response_time":  {"response_time_ms", kindInt},
+	"method":         {"method", kindString},
+	"path":           {"path", kindString},
+	"status":         {"response_status", kindInt},
+	"response":       {"response_status", kindInt},
+	"response_bytes": {"response_bytes", kindInt},
+	"content_type":   {"content_type", kindString},
+}
+
+// RequestQueryFields are the fields q= expressions over requests can use
+var RequestQueryFields = query.Fields{
+	"method":           {Column: "method", Type: query.String},
+	"path":             {Column: "path", Type: query.String},
+	"status":           {Column: "response_status", Type: query.Int},
+	"response":         {Column: "response_status", Type: query.Int},
+	"response_time":    {Column: "response_time_ms", Type: query.Int},
+	"response_bytes":   {Column: "response_bytes", Type: query.Int},
+	"content_type":     {Column: "content_type", Type: query.String},
+	"created_at":       {Column: "created_at", Type: query.Time},
+	"dns":              {Column: "dns_ms", Type: query.Int},
+	"connect":          {Column: "connect_ms", Type: query.Int},
+	"tls":              {Column: "tls_ms", Type: query.Int},
+	"ttfb":             {Column: "ttfb_ms", Type: query.Int},
+	"transfer":         {Column: "transfer_ms", Type: query.Int},
+	"conn_reused":      {Column: "conn_reused", Type: query.Bool},
+	"throttle":         {Column: "throttle_status", Type: query.String},
+	"queue_wait":       {Column: "queue_wait_ms", Type: query.Int},
+	"outcome":          {Column: "outcome", Type: query.String},
+	"cache_status":     {Column: "cache_status", Type: query.String},
+	"coalesced":        {Column: "coalesced", Type: query.Bool},
+	"upstream_call_id": {Column: "upstream_call_id", Type: query.String},
+	"parent_id":        {Column: "parent_id", Type: query.Int},
+	"attempt":          {Column: "attempt", Type: query.Int},
+}
+
+// ValidateRequestSort checks a sort spec against the fields requests can be sorted by
+func ValidateRequestSort(spec string) error {
+	_, err := requestSortKeys(spec)
+	return err
+}
+
+// requestSortKeys parses filters.SortBy, newest first by default
+func requestSortKeys(sortBy string) ([]sortKey, error) {
+	return parseSort(sortBy, requestSortColumns, []sortKey{{requestSortColumns["created_at"], true}})
+}
+
+// requestSortValue returns the value of a sort column for a request
+func requestSortValue(req models.APIRequest, column string) any {
+	switch column {
+	case "response_time_ms":
+		return req.ResponseTimeMs
+	case "method":
+		return req.Method
+	case "path":
+		return req.Path
+	case "response_status":
+		return req.ResponseStatus
+	case "response_bytes":
+		return req.ResponseBytes
+	case "content_type":
+		return req.ContentType
+	default:
+		return req.CreatedAt
+	}
+}
+
 func (r *RequestRepository) List(filters RequestFilters) ([]models.APIRequest, error) {
-	query := "SELECT id, method, path, response_status, response_time_ms, created_at FROM api_requests"
+	page, err := r.ListPage(filters)
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// ListPage returns one page of requests. Pages are keyset paginated with
+// filters.Cursor, which takes precedence over filters.Offset.
+func (r *RequestRepository) ListPage(filters RequestFilters) (*Page[models.APIRequest], error) {
+	sortKeys, err := requestSortKeys(filters.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	keys := withTieBreaker(sortKeys, "id")
+
+	var c *cursor
+	if filters.Cursor != "" {
+		if c, err = decodeCursor(filters.Cursor, sortKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	where, whereArgs := requestWhere(filters)
+	query, args := keysetQuery("SELECT "+requestColumns+" FROM api_requests", where, whereArgs, keys, c)
+
+	// Pagination, one extra row tells whether there is another page
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 100 // Default limit
+	}
+	query += " LIMIT ?"
+	args = append(args, limit+1)
+
+	if c == nil && filters.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, filters.Offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []models.APIRequest
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *req)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	rows.Close() // Release the connection before counting
+
+	page := paginate(requests, limit, c, sortKeys, func(req models.APIRequest) ([]any, int) {
+		values := make([]any, len(sortKeys))
+		for i, key := range sortKeys {
+			values[i] = requestSortValue(req, key.column)
+		}
+		return values, req.ID
+	})
+
+	if filters.WithTotal {
+		total, err := r.Count(filters)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	return &page, nil
+}
+
+// Count returns the number of requests matching filters, ignoring sorting and pagination
+func (r *RequestRepository) Count(filters RequestFilters) (int, error) {
+	where, args := requestWhere(filters)
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM api_requests"+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count requests: %w", err)
+	}
+	return total, nil
+}
+
+// Each calls fn with every request matching filters in sort order, reading
+// rows one at a time so memory stays bounded whatever the number of rows.
+// A zero filters.Limit means no limit. Iteration stops at the first error.
+func (r *RequestRepository) Each(filters RequestFilters, fn func(*models.APIRequest) error) error {
+	sortKeys, err := requestSortKeys(filters.SortBy)
+	if err != nil {
+		return err
+	}
+	c, err := streamCursor(filters.Cursor, sortKeys)
+	if err != nil {
+		return err
+	}
+
+	where, whereArgs := requestWhere(filters)
+	query, args := keysetQuery("SELECT "+requestColumns+" FROM api_requests", where, whereArgs,
+		withTieBreaker(sortKeys, "id"), c)
+	query, args = limitOffset(query, args, filters.Limit, filters.Offset, c)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query requests: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(req); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
+}
+
+func (r *RequestRepository) GetByID(id int) (*models.APIRequest, error) {
+	row := r.db.QueryRow("SELECT "+requestColumns+" FROM api_requests WHERE id = ?", id)
+
+	req, err := scanRequest(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request: %w", err)
+	}
+
+	return req, nil
+}
+
+// Stats aggregates response times and their breakdown over the requests matching filters.
+// Sorting and pagination fields are ignored.
+func (r *RequestRepository) Stats(filters RequestFilters) (*models.RequestStats, error) {
+	// Counts are of proxied requests, retry attempts being part of one.
+	// Rejected, short-circuited, cached and coalesced requests have no upstream
+	// measurement of their own and retried requests are timed by their
+	// attempts, keep them out of latency figures.
+	counted := proxiedRequest
+	measured := reachedUpstream + ` AND ` + singleCall
+	query := `SELECT
+			COUNT(CASE WHEN ` + counted + ` THEN 1 END),
+			COUNT(CASE WHEN ` + counted + ` AND throttle_status = 'queued' THEN 1 END),
+			COUNT(CASE WHEN ` + counted + ` AND throttle_status = 'rejected' THEN 1 END),
+			COUNT(CASE WHEN ` + counted + ` AND cache_status = 'HIT' THEN 1 END),
+			COUNT(CASE WHEN ` + counted + ` AND cache_status = 'STALE' THEN 1 END),
+			COUNT(CASE WHEN ` + counted + ` AND cache_status IN ('MISS', 'REVALIDATED') THEN 1 END),
+			COUNT(CASE WHEN ` + counted + ` AND coalesced = 1 THEN 1 END),
+			COALESCE(AVG(CASE WHEN ` + counted + ` AND throttle_status = 'queued' THEN queue_wait_ms END), 0),
+			COALESCE(AVG(CASE WHEN ` + measured + ` THEN response_time_ms END), 0),
+			COALESCE(MIN(CASE WHEN ` + measured + ` THEN response_time_ms END), 0),
+			COALESCE(MAX(CASE WHEN ` + measured + ` THEN response_time_ms END), 0),
+			COALESCE(AVG(CASE WHEN ` + measured + ` THEN dns_ms END), 0),
+			COALESCE(AVG(CASE WHEN ` + measured + ` THEN connect_ms END), 0),
+			COALESCE(AVG(CASE WHEN ` + measured + ` THEN tls_ms END), 0),
+			COALESCE(AVG(CASE WHEN ` + measured + ` THEN ttfb_ms END), 0),
+			COALESCE(AVG(CASE WHEN ` + measured + ` THEN transfer_ms END), 0),
+			COALESCE(AVG(CASE WHEN ` + measured + ` THEN conn_reused END), 0),
+			COALESCE(AVG(CASE WHEN ` + measured + ` THEN response_bytes END), 0)
+		FROM api_requests`
+	where, args := requestWhere(filters)
+	query += where
+
+	var stats models.RequestStats
+	err := r.db.QueryRow(query, args...).Scan(
+		&stats.Count,
+		&stats.QueuedCount,
+		&stats.RejectedCount,
+		&stats.CacheHitCount,
+		&stats.CacheStaleCount,
+		&stats.CacheMissCount,
+		&stats.CoalescedCount,
+		&stats.AvgQueueWaitMs,
+		&stats.AvgResponseTimeMs,
+		&stats.MinResponseTimeMs,
+		&stats.MaxResponseTimeMs,
+		&stats.AvgDNSMs,
+		&stats.AvgConnectMs,
+		&stats.AvgTLSMs,
+		&stats.AvgTTFBMs,
+		&stats.AvgTransferMs,
+		&stats.ConnReusedRatio,
+		&stats.AvgResponseBytes,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute request stats: %w", err)
+	}
+
+	return &stats, nil
+}
+
+// requestWhere builds the WHERE clause (with leading space) and its arguments for filters
+func requestWhere(filters RequestFilters) (string, []any) {
 	where := []string{}
 	args := []any{}
 
@@ -63,6 +497,14 @@ func (r *RequestRepository) List(filters RequestFilters) ([]models.APIRequest, e
 		args = append(args, filters.Response)
 	}
 
+	methodWhere, methodArgs := filters.Methods.conditions("method")
+	where = append(where, methodWhere...)
+	args = append(args, methodArgs...)
+
+	statusWhere, statusArgs := filters.Statuses.conditions("response_status")
+	where = append(where, statusWhere...)
+	args = append(args, statusArgs...)
+
 	if filters.MinTime > 0 {
 		where = append(where, "response_time_ms >= ?")
 		args = append(args, filters.MinTime)
@@ -75,92 +517,191 @@ func (r *RequestRepository) List(filters RequestFilters) ([]models.APIRequest, e
 
 	if !filters.CreatedAfter.IsZero() {
 		where = append(where, "created_at >= ?")
-		args = append(args, filters.CreatedAfter)
+		args = append(args, storedTime(filters.CreatedAfter))
 	}
 
 	if !filters.CreatedBefore.IsZero() {
-		where = append(where, "created_at <= ?")
-		args = append(args, filters.CreatedBefore)
+		where = append(where, "created_at < ?")
+		args = append(args, storedTime(filters.CreatedBefore))
 	}
 
-	if filters.Search != "" {
-		where = append(where, "path LIKE ?")
-		args = append(args, "%"+filters.Search+"%")
+	if filters.MinBytes > 0 {
+		where = append(where, "response_bytes >= ?")
+		args = append(args, filters.MinBytes)
 	}
 
-	if len(where) > 0 {
-		query += " WHERE " + strings.Join(where, " AND ")
+	if filters.MaxBytes > 0 {
+		where = append(where, "response_bytes <= ?")
+		args = append(args, filters.MaxBytes)
 	}
 
-	// Sorting
-	var sortBy string
-	switch filters.SortBy {
-	case "response_time":
-		sortBy = "response_time_ms DESC"
-	case "created_at":
-		sortBy = "created_at DESC"
+	if filters.ContentType != "" {
+		// Prefix match so "application/json" also matches "application/json; charset=utf-8"
+		where = append(where, "content_type LIKE ?"+likeEscape)
+		args = append(args, escapeLike(filters.ContentType)+"%")
+	}
+
+	switch filters.CacheStatus {
+	case "":
+	case "none":
+		where = append(where, "cache_status = ''")
 	default:
-		sortBy = "created_at DESC"
+		where = append(where, "cache_status = ?")
+		args = append(args, filters.CacheStatus)
 	}
-	query += " ORDER BY " + sortBy
 
-	// Pagination
-	if filters.Limit > 0 {
-		query += " LIMIT ?"
-		args = append(args, filters.Limit)
-	} else {
-		query += " LIMIT 100" // Default limit
+	if filters.Coalesced != nil {
+		where = append(where, "coalesced = ?")
+		args = append(args, *filters.Coalesced)
 	}
 
-	if filters.Offset > 0 {
-		query += " OFFSET ?"
-		args = append(args, filters.Offset)
+	if filters.UpstreamCallID != "" {
+		where = append(where, "upstream_call_id = ?")
+		args = append(args, filters.UpstreamCallID)
 	}
 
-	rows, err := r.db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query requests: %w", err)
+	switch filters.Outcome {
+	case "":
+	case "none":
+		where = append(where, "outcome = ''")
+	default:
+		where = append(where, "outcome = ?")
+		args = append(args, filters.Outcome)
 	}
-	defer rows.Close()
 
-	var requests []models.APIRequest
-	for rows.Next() {
-		var req models.APIRequest
-		err := rows.Scan(
-			&req.ID,
-			&req.Method,
-			&req.Path,
-			&req.ResponseStatus,
-			&req.ResponseTimeMs,
-			&req.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan request: %w", err)
-		}
-		requests = append(requests, req)
+	if filters.ParentID > 0 {
+		where = append(where, "parent_id = ?")
+		args = append(args, filters.ParentID)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+	if filters.ExcludeAttempts {
+		where = append(where, "parent_id IS NULL")
 	}
 
-	return requests, nil
+	switch filters.Throttle {
+	case "":
+	case "none":
+		where = append(where, "throttle_status = ''")
+	default:
+		where = append(where, "throttle_status = ?")
+		args = append(args, filters.Throttle)
+	}
+
+	for _, name := range sortedKeys(filters.Headers) {
+		where = append(where, "json_extract(upstream_headers, ?) = ?")
+		args = append(args, headerPath(name), filters.Headers[name])
+	}
+
+	if filters.Search != "" {
+		where = append(where, "path LIKE ?"+likeEscape)
+		args = append(args, "%"+escapeLike(filters.Search)+"%")
+	}
+
+	pathWhere, pathArgs := pathConditions("path", filters.PathRegex, filters.PathGlob)
+	where = append(where, pathWhere...)
+	args = append(args, pathArgs...)
+
+	exprWhere, exprArgs := expressionConditions(filters.Expression)
+	where = append(where, exprWhere...)
+	args = append(args, exprArgs...)
+
+	if len(where) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
 }
 
-func (r *RequestRepository) GetByID(id int) (*models.APIRequest, error) {
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRequest reads one api_requests row selected with requestColumns
+func scanRequest(row rowScanner) (*models.APIRequest, error) {
 	var req models.APIRequest
-	err := r.db.QueryRow(
-		`SELECT id, method, path, response_status, response_time_ms, created_at
-		FROM api_requests WHERE id = ?`,
-		id,
-	).Scan(&req.ID, &req.Method, &req.Path, &req.ResponseStatus, &req.ResponseTimeMs, &req.CreatedAt)
+	var headers string
+	var parentID sql.NullInt64
+	err := row.Scan(
+		&req.ID,
+		&req.Method,
+		&req.Path,
+		&req.ResponseStatus,
+		&req.ResponseTimeMs,
+		&req.ResponseBytes,
+		&req.ContentType,
+		&headers,
+		&req.Timing.DNSMs,
+		&req.Timing.ConnectMs,
+		&req.Timing.TLSMs,
+		&req.Timing.TTFBMs,
+		&req.Timing.TransferMs,
+		&req.Timing.ConnReused,
+		&req.ThrottleStatus,
+		&req.QueueWaitMs,
+		&req.Outcome,
+		&req.CacheStatus,
+		&req.Coalesced,
+		&req.UpstreamCallID,
+		&parentID,
+		&req.Attempt,
+		&req.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan request: %w", err)
+	}
 
-	if err == sql.ErrNoRows {
-		return nil, nil
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		req.ParentID = &id
 	}
+
+	req.UpstreamHeaders, err = decodeHeaders(headers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get request: %w", err)
+		return nil, err
 	}
 
 	return &req, nil
 }
+
+// storedTime converts a time bound to the zone created_at values are written
+// in (the server's local time), so the text comparison in SQLite holds
+func storedTime(t time.Time) time.Time {
+	return t.Local()
+}
+
+func encodeHeaders(headers map[string]string) (string, error) {
+	if len(headers) == 0 {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode upstream headers: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func decodeHeaders(encoded string) (map[string]string, error) {
+	headers := map[string]string{}
+	if encoded == "" {
+		return headers, nil
+	}
+	if err := json.Unmarshal([]byte(encoded), &headers); err != nil {
+		return nil, fmt.Errorf("failed to decode upstream headers: %w", err)
+	}
+	return headers, nil
+}
+
+// headerPath builds the json_extract path for an upstream header name,
+// quoting it because header names contain dashes
+func headerPath(name string) string {
+	return `$."` + strings.ReplaceAll(name, `"`, ``) + `"`
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,8 +1,15 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+	"treblle_project/internal/database"
+	"treblle_project/internal/models"
+	"treblle_project/internal/query"
 )
 
 // Test 1:Basic Create
@@ -93,3 +100,538 @@ func TestRequestRepository_Search(t *testing.T) {
 		}
 	}
 }
+
+// Test 4: Response size, content type and upstream headers round-trip and filter
+func TestRequestRepository_ResponseMetadataFilters(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	small := &models.APIRequest{
+		Method:          "GET",
+		Path:            "/anime/1",
+		ResponseStatus:  200,
+		ResponseTimeMs:  100,
+		ResponseBytes:   1024,
+		ContentType:     "application/json; charset=utf-8",
+		UpstreamHeaders: map[string]string{"Cache-Control": "max-age=86400", "X-Request-Fingerprint": "abc"},
+		CreatedAt:       time.Now(),
+	}
+	large := &models.APIRequest{
+		Method:          "GET",
+		Path:            "/top/anime",
+		ResponseStatus:  200,
+		ResponseTimeMs:  100,
+		ResponseBytes:   3 * 1024 * 1024,
+		ContentType:     "text/html",
+		UpstreamHeaders: map[string]string{"Cache-Control": "no-cache"},
+		CreatedAt:       time.Now(),
+	}
+	smallID, err := repo.Create(small)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if _, err := repo.Create(large); err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	saved, err := repo.GetByID(int(smallID))
+	if err != nil {
+		t.Fatalf("Failed to retrieve saved request: %v", err)
+	}
+	if saved.ResponseBytes != 1024 || saved.ContentType != small.ContentType {
+		t.Errorf("Expected size and content type to round-trip, got %d %q", saved.ResponseBytes, saved.ContentType)
+	}
+	if saved.UpstreamHeaders["X-Request-Fingerprint"] != "abc" {
+		t.Errorf("Expected fingerprint header to round-trip, got %v", saved.UpstreamHeaders)
+	}
+
+	tests := []struct {
+		name     string
+		filters  RequestFilters
+		expected string
+	}{
+		{"min bytes", RequestFilters{MinBytes: 2 * 1024 * 1024, Limit: 100}, "/top/anime"},
+		{"max bytes", RequestFilters{MaxBytes: 2048, Limit: 100}, "/anime/1"},
+		{"content type prefix", RequestFilters{ContentType: "application/json", Limit: 100}, "/anime/1"},
+		{"header value", RequestFilters{Headers: map[string]string{"Cache-Control": "no-cache"}, Limit: 100}, "/top/anime"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.List(tt.filters)
+			if err != nil {
+				t.Fatalf("Failed to list requests: %v", err)
+			}
+			if len(results) != 1 || results[0].Path != tt.expected {
+				t.Errorf("Expected only %s, got %v", tt.expected, results)
+			}
+		})
+	}
+}
+
+// Test 5: Stats aggregates response times and the timing breakdown
+func TestRequestRepository_Stats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	for _, timing := range []models.RequestTiming{
+		{DNSMs: 10, ConnectMs: 20, TLSMs: 30, TTFBMs: 100, TransferMs: 40},
+		{TTFBMs: 200, TransferMs: 60, ConnReused: true},
+	} {
+		_, err := repo.Create(&models.APIRequest{
+			Method:         "GET",
+			Path:           "/anime/1",
+			ResponseStatus: 200,
+			ResponseTimeMs: timing.TTFBMs + timing.TransferMs,
+			Timing:         timing,
+			CreatedAt:      time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+	}
+
+	stats, err := repo.Stats(RequestFilters{})
+	if err != nil {
+		t.Fatalf("Failed to compute stats: %v", err)
+	}
+
+	if stats.Count != 2 {
+		t.Errorf("Expected count 2, got %d", stats.Count)
+	}
+	if stats.MinResponseTimeMs != 140 || stats.MaxResponseTimeMs != 260 {
+		t.Errorf("Expected response time range 140-260, got %d-%d", stats.MinResponseTimeMs, stats.MaxResponseTimeMs)
+	}
+	if stats.AvgTTFBMs != 150 {
+		t.Errorf("Expected average TTFB 150, got %v", stats.AvgTTFBMs)
+	}
+	if stats.AvgDNSMs != 5 {
+		t.Errorf("Expected average DNS 5, got %v", stats.AvgDNSMs)
+	}
+	if stats.ConnReusedRatio != 0.5 {
+		t.Errorf("Expected connection reuse ratio 0.5, got %v", stats.ConnReusedRatio)
+	}
+
+	saved, err := repo.List(RequestFilters{Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to list requests: %v", err)
+	}
+	if !saved[0].Timing.ConnReused && !saved[1].Timing.ConnReused {
+		t.Error("Expected conn_reused to round-trip")
+	}
+}
+
+// Test 6: Cache hits are counted but kept out of latency stats, background refreshes the other way round
+func TestRequestRepository_StatsExcludeCacheHits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	for _, req := range []models.APIRequest{
+		{ResponseTimeMs: 200, CacheStatus: "MISS"},
+		{ResponseTimeMs: 1, CacheStatus: "HIT"},
+		{ResponseTimeMs: 3, CacheStatus: "STALE"},
+		{ResponseTimeMs: 400, CacheStatus: "REFRESH"},
+	} {
+		req.Method = "GET"
+		req.Path = "/top/anime"
+		req.ResponseStatus = 200
+		req.CreatedAt = time.Now()
+		if _, err := repo.Create(&req); err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+	}
+
+	stats, err := repo.Stats(RequestFilters{})
+	if err != nil {
+		t.Fatalf("Failed to compute stats: %v", err)
+	}
+
+	if stats.Count != 3 || stats.CacheHitCount != 1 || stats.CacheStaleCount != 1 || stats.CacheMissCount != 1 {
+		t.Errorf("Unexpected counts %+v", stats)
+	}
+	if stats.AvgResponseTimeMs != 300 || stats.MinResponseTimeMs != 200 || stats.MaxResponseTimeMs != 400 {
+		t.Errorf("Expected only the miss and the refresh in latency figures, got avg %v min %d max %d",
+			stats.AvgResponseTimeMs, stats.MinResponseTimeMs, stats.MaxResponseTimeMs)
+	}
+
+	hits, err := repo.List(RequestFilters{CacheStatus: "HIT"})
+	if err != nil {
+		t.Fatalf("Failed to list requests: %v", err)
+	}
+	if len(hits) != 1 || hits[0].CacheStatus != "HIT" {
+		t.Errorf("Expected 1 cache hit, got %v", hits)
+	}
+}
+
+// Test 7: Cursor pages stay stable while new requests are inserted
+func TestRequestRepository_CursorPagination(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	// Equal response times exercise the id tie breaker
+	for i, ms := range []int64{100, 300, 200, 300, 100} {
+		createTestRequest(t, db, "GET", "/anime/"+string(rune('a'+i)), 200, ms)
+	}
+
+	filters := RequestFilters{SortBy: "-response_time", Limit: 2, WithTotal: true}
+	var seen []int64
+	var pages []*Page[models.APIRequest]
+	for {
+		page, err := repo.ListPage(filters)
+		if err != nil {
+			t.Fatalf("Failed to list page: %v", err)
+		}
+		pages = append(pages, page)
+		for _, req := range page.Items {
+			seen = append(seen, req.ResponseTimeMs)
+		}
+		if page.Total == nil || *page.Total < 5 {
+			t.Fatalf("Expected a total of at least 5, got %v", page.Total)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		// A new, slowest request must not shift later pages
+		createTestRequest(t, db, "GET", "/inserted", 200, 999)
+		filters.Cursor = page.NextCursor
+	}
+
+	expected := []int64{300, 300, 200, 100, 100}
+	if len(seen) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, seen)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, seen)
+		}
+	}
+	if pages[0].PrevCursor != "" {
+		t.Error("Expected no prev cursor on the first page")
+	}
+
+	// Going back from the last page returns the middle page
+	back, err := repo.ListPage(RequestFilters{SortBy: "-response_time", Limit: 2, Cursor: pages[2].PrevCursor})
+	if err != nil {
+		t.Fatalf("Failed to list previous page: %v", err)
+	}
+	if len(back.Items) != 2 || back.Items[0].ID != pages[1].Items[0].ID || back.Items[1].ID != pages[1].Items[1].ID {
+		t.Errorf("Expected the middle page, got %+v", back.Items)
+	}
+
+	if _, err := repo.ListPage(RequestFilters{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+	// A cursor of another sort order is rejected too
+	if _, err := repo.ListPage(RequestFilters{Cursor: pages[0].NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a response_time cursor on created_at, got %v", err)
+	}
+}
+
+// Test 8: created_after is inclusive and created_before exclusive, whatever the zone of the bounds
+func TestRequestRepository_CreatedAtRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	base := time.Now().Truncate(time.Second)
+	for i, path := range []string{"/first", "/second", "/third"} {
+		_, err := repo.Create(&models.APIRequest{
+			Method:         "GET",
+			Path:           path,
+			ResponseStatus: 200,
+			CreatedAt:      base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+	}
+
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	results, err := repo.List(RequestFilters{
+		CreatedAfter:  base.Add(time.Minute).In(zone),
+		CreatedBefore: base.Add(2 * time.Minute).UTC(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to list requests: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Path != "/second" {
+		t.Errorf("Expected only /second, got %v", results)
+	}
+}
+
+// Test 9: q= expressions filter rows and injection payloads are matched as plain values
+func TestRequestRepository_Expression(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	createTestRequest(t, db, "GET", "/anime/1", 200, 900)
+	createTestRequest(t, db, "GET", "/anime/2", 200, 100)
+	createTestRequest(t, db, "GET", "/manga/1", 503, 50)
+	createTestRequest(t, db, "GET", "/manga/2", 200, 1200)
+
+	cond, err := query.ParseAndCompile("status >= 500 OR (response_time > 800 AND path ~ '/anime/')", RequestQueryFields, query.Options{})
+	if err != nil {
+		t.Fatalf("Failed to compile expression: %v", err)
+	}
+	results, err := repo.List(RequestFilters{Expression: cond, SortBy: "path"})
+	if err != nil {
+		t.Fatalf("Failed to list requests: %v", err)
+	}
+	if len(results) != 2 || results[0].Path != "/anime/1" || results[1].Path != "/manga/1" {
+		t.Errorf("Expected /anime/1 and /manga/1, got %v", results)
+	}
+
+	for _, payload := range []string{
+		`'; DROP TABLE api_requests; --`,
+		`' OR '1'='1`,
+		`/anime/1' OR 1=1 --`,
+	} {
+		input := "path = '" + strings.ReplaceAll(payload, "'", `\'`) + "' OR path IN ('" + strings.ReplaceAll(payload, "'", `\'`) + "')"
+		cond, err := query.ParseAndCompile(input, RequestQueryFields, query.Options{})
+		if err != nil {
+			t.Fatalf("%q: failed to compile expression: %v", input, err)
+		}
+		results, err := repo.List(RequestFilters{Expression: cond})
+		if err != nil {
+			t.Fatalf("%q: failed to list requests: %v", input, err)
+		}
+		if len(results) != 0 {
+			t.Errorf("%q: expected no matches, got %v", input, results)
+		}
+	}
+
+	all, err := repo.List(RequestFilters{})
+	if err != nil || len(all) != 4 {
+		t.Errorf("Expected the table to still hold 4 requests, got %d (%v)", len(all), err)
+	}
+}
+
+// Test 10: Each streams every matching row in sort order, honouring limit, offset and next cursors
+func TestRequestRepository_Each(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	for i, ms := range []int64{100, 300, 200, 300, 100} {
+		createTestRequest(t, db, "GET", "/anime/"+string(rune('a'+i)), 200, ms)
+	}
+
+	collect := func(filters RequestFilters) ([]int64, error) {
+		var times []int64
+		err := repo.Each(filters, func(req *models.APIRequest) error {
+			times = append(times, req.ResponseTimeMs)
+			return nil
+		})
+		return times, err
+	}
+
+	tests := []struct {
+		filters  RequestFilters
+		expected string
+	}{
+		{RequestFilters{SortBy: "-response_time"}, "[300 300 200 100 100]"},
+		{RequestFilters{SortBy: "-response_time", Limit: 2}, "[300 300]"},
+		{RequestFilters{SortBy: "-response_time", Offset: 3}, "[100 100]"},
+		{RequestFilters{SortBy: "-response_time", Limit: 1, Offset: 2}, "[200]"},
+		{RequestFilters{SortBy: "response_time", MinTime: 150}, "[200 300 300]"},
+	}
+	for _, tt := range tests {
+		times, err := collect(tt.filters)
+		if err != nil || fmt.Sprint(times) != tt.expected {
+			t.Errorf("%+v: expected %s, got %v (%v)", tt.filters, tt.expected, times, err)
+		}
+	}
+
+	page, err := repo.ListPage(RequestFilters{SortBy: "-response_time", Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to list page: %v", err)
+	}
+	if times, err := collect(RequestFilters{SortBy: "-response_time", Cursor: page.NextCursor}); err != nil || fmt.Sprint(times) != "[200 100 100]" {
+		t.Errorf("Expected the rows after the first page, got %v (%v)", times, err)
+	}
+
+	next, err := repo.ListPage(RequestFilters{SortBy: "-response_time", Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("Failed to list page: %v", err)
+	}
+	if _, err := collect(RequestFilters{SortBy: "-response_time", Cursor: next.PrevCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a prev cursor, got %v", err)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.Each(RequestFilters{}, func(*models.APIRequest) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected iteration to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
+
+// Test 11: CreateBatch inserts requests and their problems, and nothing when one insert fails
+func TestRequestRepository_CreateBatch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+	problemRepo := NewProblemRepository(db)
+
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	items := []BatchItem{
+		{Request: &models.APIRequest{Method: "GET", Path: "/anime/1", ResponseStatus: 200, ResponseTimeMs: 100, CreatedAt: created}},
+		{
+			Request:  &models.APIRequest{Method: "GET", Path: "/anime/2", ResponseStatus: 404, ResponseTimeMs: 50, CreatedAt: created},
+			Problems: []*models.Problem{{ProblemType: "not_found", Description: "Not found", CreatedAt: created}},
+		},
+	}
+	if err := repo.CreateBatch(items); err != nil {
+		t.Fatalf("Failed to create batch: %v", err)
+	}
+
+	for _, item := range items {
+		saved, err := repo.GetByID(item.Request.ID)
+		if err != nil || saved.Path != item.Request.Path || !saved.CreatedAt.Equal(created) {
+			t.Errorf("Expected %s to be saved with id %d, got %+v (%v)", item.Request.Path, item.Request.ID, saved, err)
+		}
+	}
+	problems, err := problemRepo.List(ProblemFilters{})
+	if err != nil || len(problems) != 1 || problems[0].RequestID != items[1].Request.ID || problems[0].ID != items[1].Problems[0].ID {
+		t.Errorf("Expected the problem of the second request, got %+v (%v)", problems, err)
+	}
+
+	// A failing problem insert rolls back the requests of the batch
+	if _, err := db.Exec(`CREATE TRIGGER fail_problems BEFORE INSERT ON problems BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+	failing := []BatchItem{
+		{Request: &models.APIRequest{Method: "GET", Path: "/anime/3", ResponseStatus: 200, CreatedAt: created}},
+		{
+			Request:  &models.APIRequest{Method: "GET", Path: "/anime/4", ResponseStatus: 500, CreatedAt: created},
+			Problems: []*models.Problem{{ProblemType: "server_error", Description: "Server error", CreatedAt: created}},
+		},
+	}
+	if err := repo.CreateBatch(failing); err == nil {
+		t.Fatal("Expected the batch to fail")
+	}
+	if count, err := repo.Count(RequestFilters{}); err != nil || count != 2 {
+		t.Errorf("Expected the failed batch to insert nothing, got %d requests (%v)", count, err)
+	}
+}
+
+// Test 12: Stats count a retried request once and time it through its attempts
+func TestRequestRepository_StatsRetries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	create := func(req *models.APIRequest) int {
+		req.Method, req.Path, req.CreatedAt = "GET", "/anime/1", time.Now()
+		id, err := repo.Create(req)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		return int(id)
+	}
+	// Two attempts of 100ms and 300ms with a backoff in between
+	parentID := create(&models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 1500, Attempt: 2, ThrottleStatus: "queued", QueueWaitMs: 20})
+	create(&models.APIRequest{ResponseStatus: 503, ResponseTimeMs: 100, ParentID: &parentID, Attempt: 1, ThrottleStatus: "queued", QueueWaitMs: 10})
+	create(&models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 300, ParentID: &parentID, Attempt: 2, ThrottleStatus: "queued", QueueWaitMs: 10})
+	create(&models.APIRequest{ResponseStatus: 200, ResponseTimeMs: 200, Attempt: 1})
+
+	stats, err := repo.Stats(RequestFilters{})
+	if err != nil {
+		t.Fatalf("Failed to compute stats: %v", err)
+	}
+	if stats.Count != 2 || stats.QueuedCount != 1 || stats.AvgQueueWaitMs != 20 {
+		t.Errorf("Expected 2 requests, one queued for 20ms, got %+v", stats)
+	}
+	if stats.AvgResponseTimeMs != 200 || stats.MinResponseTimeMs != 100 || stats.MaxResponseTimeMs != 300 {
+		t.Errorf("Expected latency over the attempts and the request that wasn't retried, got avg %v min %d max %d",
+			stats.AvgResponseTimeMs, stats.MinResponseTimeMs, stats.MaxResponseTimeMs)
+	}
+}
+
+// Test 13: Search and content type filters match % and _ literally
+func TestRequestRepository_LikeWildcards(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRequestRepository(db)
+
+	for _, req := range []*models.APIRequest{
+		{Method: "GET", Path: "/anime/1/user_updates", ResponseStatus: 200, ContentType: "application/json", CreatedAt: time.Now()},
+		{Method: "GET", Path: "/anime/1/userXupdates", ResponseStatus: 200, ContentType: "application/jsonp", CreatedAt: time.Now()},
+		{Method: "GET", Path: "/search?q=100%25", ResponseStatus: 200, ContentType: "text/html", CreatedAt: time.Now()},
+	} {
+		if _, err := repo.Create(req); err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+	}
+
+	tests := []struct {
+		filters  RequestFilters
+		expected int
+	}{
+		{RequestFilters{Search: "user_updates"}, 1},
+		{RequestFilters{Search: "%"}, 1},
+		{RequestFilters{Search: "anime"}, 2},
+		{RequestFilters{ContentType: "application/%"}, 0},
+		{RequestFilters{ContentType: "application/json"}, 2},
+		{RequestFilters{ContentType: "text_html"}, 0},
+	}
+	for _, tt := range tests {
+		requests, err := repo.List(tt.filters)
+		if err != nil {
+			t.Fatalf("%+v: failed to list requests: %v", tt.filters, err)
+		}
+		if len(requests) != tt.expected {
+			t.Errorf("%+v: expected %d requests, got %d", tt.filters, tt.expected, len(requests))
+		}
+	}
+}
+
+// Test 14: requests can be logged while an export holds its read cursor open
+func TestRequestRepository_CreateDuringEach(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "monitor.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	repo := NewRequestRepository(db)
+
+	for i := 0; i < 3; i++ {
+		createTestRequest(t, db, "GET", "/anime/"+string(rune('a'+i)), 200, 100)
+	}
+
+	streamed := 0
+	err = repo.Each(RequestFilters{}, func(*models.APIRequest) error {
+		streamed++
+		_, err := repo.Create(&models.APIRequest{
+			Method:         "GET",
+			Path:           "/anime/during-export",
+			ResponseStatus: 200,
+			ResponseTimeMs: 50,
+			CreatedAt:      time.Now(),
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Expected writes during the export to succeed, got %v", err)
+	}
+	if streamed == 0 {
+		t.Fatal("Expected the export to stream rows")
+	}
+
+	total, err := repo.Count(RequestFilters{})
+	if err != nil || total != 3+streamed {
+		t.Errorf("Expected %d requests after the export, got %d (%v)", 3+streamed, total, err)
+	}
+}
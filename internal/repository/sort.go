@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidSort is returned (wrapped) when a sort spec names an unknown or repeated field
+var ErrInvalidSort = errors.New("invalid sort")
+
+// parseSort parses a sort spec like "-response_time,path": comma separated
+// field names, ascending unless prefixed with "-". Fields are looked up in
+// columns, and an empty spec yields the default order.
+func parseSort(spec string, columns map[string]sortColumn, defaults []sortKey) ([]sortKey, error) {
+	if strings.TrimSpace(spec) == "" {
+		return defaults, nil
+	}
+
+	var keys []sortKey
+	seen := map[string]bool{}
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		name, desc := strings.CutPrefix(field, "-")
+		if !desc {
+			name = strings.TrimPrefix(name, "+")
+		}
+
+		col, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q, expected one of %s", ErrInvalidSort, name, strings.Join(slices.Sorted(maps.Keys(columns)), ", "))
+		}
+		if seen[col.column] {
+			return nil, fmt.Errorf("%w: field %q given more than once", ErrInvalidSort, name)
+		}
+		seen[col.column] = true
+		keys = append(keys, sortKey{col, desc})
+	}
+	return keys, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"treblle_project/internal/database"
+	"treblle_project/internal/models"
+)
+
+// ErrViewNotFound is returned when changing a view that doesn't exist
+var ErrViewNotFound = errors.New("view not found")
+
+// ErrViewExists is returned when a view name is already taken
+var ErrViewExists = errors.New("view already exists")
+
+const viewColumns = `id, name, resource, filters, sort, columns, created_at, updated_at`
+
+type ViewRepository struct {
+	db *database.DB
+}
+
+func NewViewRepository(db *database.DB) *ViewRepository {
+	return &ViewRepository{db: db}
+}
+
+// Create stores a new view, setting its ID and timestamps
+func (r *ViewRepository) Create(view *models.View) error {
+	filters, columns, err := encodeView(view)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err := r.db.Exec(
+		`INSERT INTO views (name, resource, filters, sort, columns, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		view.Name, view.Resource, filters, view.Sort, columns, now, now,
+	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %s", ErrViewExists, view.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create view: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get view id: %w", err)
+	}
+	view.ID = int(id)
+	view.CreatedAt = now
+	view.UpdatedAt = now
+	return nil
+}
+
+// GetByName returns the view called name, or nil when there is none
+func (r *ViewRepository) GetByName(name string) (*models.View, error) {
+	row := r.db.QueryRow("SELECT "+viewColumns+" FROM views WHERE name = ?", name)
+
+	view, err := scanView(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get view: %w", err)
+	}
+	return view, nil
+}
+
+// List returns every view of resource ordered by name, every view when resource is empty
+func (r *ViewRepository) List(resource string) ([]models.View, error) {
+	query := "SELECT " + viewColumns + " FROM views"
+	args := []any{}
+	if resource != "" {
+		query += " WHERE resource = ?"
+		args = append(args, resource)
+	}
+	query += " ORDER BY name"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list views: %w", err)
+	}
+	defer rows.Close()
+
+	views := []models.View{}
+	for rows.Next() {
+		view, err := scanView(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan view: %w", err)
+		}
+		views = append(views, *view)
+	}
+	return views, rows.Err()
+}
+
+// Update replaces the view called name with view, which may carry a new name
+func (r *ViewRepository) Update(name string, view *models.View) error {
+	filters, columns, err := encodeView(view)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err := r.db.Exec(
+		`UPDATE views SET name = ?, resource = ?, filters = ?, sort = ?, columns = ?, updated_at = ?
+		WHERE name = ?`,
+		view.Name, view.Resource, filters, view.Sort, columns, now, name,
+	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %s", ErrViewExists, view.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update view: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("%w: %s", ErrViewNotFound, name)
+	}
+
+	updated, err := r.GetByName(view.Name)
+	if err != nil {
+		return err
+	}
+	*view = *updated
+	return nil
+}
+
+// Delete removes the view called name
+func (r *ViewRepository) Delete(name string) error {
+	result, err := r.db.Exec("DELETE FROM views WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete view: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("%w: %s", ErrViewNotFound, name)
+	}
+	return nil
+}
+
+// scanView reads one views row selected with viewColumns
+func scanView(row rowScanner) (*models.View, error) {
+	var view models.View
+	var filters, columns string
+	if err := row.Scan(&view.ID, &view.Name, &view.Resource, &filters, &view.Sort, &columns,
+		&view.CreatedAt, &view.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	view.Filters = map[string]string{}
+	if err := json.Unmarshal([]byte(filters), &view.Filters); err != nil {
+		return nil, fmt.Errorf("failed to decode view filters: %w", err)
+	}
+	view.Columns = []string{}
+	if err := json.Unmarshal([]byte(columns), &view.Columns); err != nil {
+		return nil, fmt.Errorf("failed to decode view columns: %w", err)
+	}
+	return &view, nil
+}
+
+// encodeView encodes the filters and columns of view as JSON
+func encodeView(view *models.View) (filters, columns string, err error) {
+	encodedFilters, err := json.Marshal(view.Filters)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode view filters: %w", err)
+	}
+	if view.Filters == nil {
+		encodedFilters = []byte("{}")
+	}
+	encodedColumns, err := json.Marshal(view.Columns)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode view columns: %w", err)
+	}
+	if view.Columns == nil {
+		encodedColumns = []byte("[]")
+	}
+	return string(encodedFilters), string(encodedColumns), nil
+}
+
+// isUniqueViolation reports whether err comes from a UNIQUE constraint
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
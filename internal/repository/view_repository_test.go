@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"treblle_project/internal/models"
+)
+
+// Test 1: Views round trip through create, get, list, update and delete
+func TestViewRepository_CRUD(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewViewRepository(db)
+
+	view := &models.View{
+		Name:     "slow-anime",
+		Resource: models.ViewResourceProblems,
+		Filters:  map[string]string{"problem_type": "slow_response", "path_glob": "/anime/*"},
+		Sort:     "-response_time",
+		Columns:  []string{"path", "response_time"},
+	}
+	if err := repo.Create(view); err != nil {
+		t.Fatalf("Failed to create view: %v", err)
+	}
+	if view.ID == 0 || view.CreatedAt.IsZero() {
+		t.Errorf("Expected ID and timestamps to be set, got %+v", view)
+	}
+
+	got, err := repo.GetByName("slow-anime")
+	if err != nil || got == nil {
+		t.Fatalf("Failed to get view: %v", err)
+	}
+	if got.Filters["path_glob"] != "/anime/*" || got.Sort != "-response_time" || !slices.Equal(got.Columns, view.Columns) {
+		t.Errorf("Unexpected view %+v", got)
+	}
+
+	if err := repo.Create(&models.View{Name: "all-requests", Resource: models.ViewResourceRequests}); err != nil {
+		t.Fatalf("Failed to create view: %v", err)
+	}
+	views, err := repo.List(models.ViewResourceProblems)
+	if err != nil || len(views) != 1 || views[0].Name != "slow-anime" {
+		t.Errorf("Expected only slow-anime, got %v (%v)", views, err)
+	}
+	if views, _ := repo.List(""); len(views) != 2 || views[0].Name != "all-requests" {
+		t.Errorf("Expected both views ordered by name, got %v", views)
+	}
+
+	renamed := &models.View{Name: "slow", Resource: models.ViewResourceProblems, Sort: "path"}
+	if err := repo.Update("slow-anime", renamed); err != nil {
+		t.Fatalf("Failed to update view: %v", err)
+	}
+	if renamed.ID != view.ID || renamed.Sort != "path" || len(renamed.Filters) != 0 || len(renamed.Columns) != 0 {
+		t.Errorf("Unexpected updated view %+v", renamed)
+	}
+	if got, _ := repo.GetByName("slow-anime"); got != nil {
+		t.Errorf("Expected the old name to be gone, got %+v", got)
+	}
+
+	if err := repo.Delete("slow"); err != nil {
+		t.Fatalf("Failed to delete view: %v", err)
+	}
+	if got, _ := repo.GetByName("slow"); got != nil {
+		t.Errorf("Expected the view to be deleted, got %+v", got)
+	}
+}
+
+// Test 2: Duplicate names and missing views are reported with sentinel errors
+func TestViewRepository_Errors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewViewRepository(db)
+
+	for _, name := range []string{"a", "b"} {
+		if err := repo.Create(&models.View{Name: name, Resource: models.ViewResourceRequests}); err != nil {
+			t.Fatalf("Failed to create view: %v", err)
+		}
+	}
+
+	if err := repo.Create(&models.View{Name: "a", Resource: models.ViewResourceRequests}); !errors.Is(err, ErrViewExists) {
+		t.Errorf("Expected ErrViewExists, got %v", err)
+	}
+	if err := repo.Update("b", &models.View{Name: "a", Resource: models.ViewResourceRequests}); !errors.Is(err, ErrViewExists) {
+		t.Errorf("Expected ErrViewExists on rename, got %v", err)
+	}
+	if err := repo.Update("missing", &models.View{Name: "missing", Resource: models.ViewResourceRequests}); !errors.Is(err, ErrViewNotFound) {
+		t.Errorf("Expected ErrViewNotFound, got %v", err)
+	}
+	if err := repo.Delete("missing"); !errors.Is(err, ErrViewNotFound) {
+		t.Errorf("Expected ErrViewNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package timeparse reads the time values of filters: absolute, epoch and
+// relative times, shared by the API and the command line tools.
+package timeparse
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts of absolute times without a zone, read in the tz parameter's location
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// relativePattern matches an offset from now such as -15m, -1d12h or now+2w
+var relativePattern = regexp.MustCompile(`^(?:now)?(?:([+-])((?:\d+[smhdw])+))?$`)
+
+var relativePart = regexp.MustCompile(`(\d+)([smhdw])`)
+
+// epochPattern matches Unix epoch seconds or milliseconds, with an optional fraction
+var epochPattern = regexp.MustCompile(`^\d+(\.\d+)?$`)
+
+var relativeUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ErrFormat is returned for values in none of the accepted formats
+var ErrFormat = errors.New("must be an RFC3339 timestamp, a YYYY-MM-DD date, a Unix epoch or a relative time such as -15m or now-1d")
+
+// Parse reads a time filter value: an RFC3339 timestamp, a date or
+// zone-less date-time in loc, Unix epoch seconds (or milliseconds, from 13
+// digits), or an offset from now like "-15m", "now-1d" and "now".
+func Parse(value string, now time.Time, loc *time.Location) (time.Time, error) {
+	// A '+' left unescaped in a query string arrives as a space
+	if i := strings.LastIndexByte(value, ' '); i >= 0 && (strings.HasPrefix(value, "now ") || len(value)-i == 6) {
+		value = value[:i] + "+" + value[i+1:]
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	if epochPattern.MatchString(value) {
+		epoch, _ := strconv.ParseFloat(value, 64)
+		if whole, _, _ := strings.Cut(value, "."); len(whole) >= 13 {
+			epoch /= 1000
+		}
+		sec, frac := math.Modf(epoch)
+		return time.Unix(int64(sec), int64(frac*1e9)).In(loc), nil
+	}
+
+	if m := relativePattern.FindStringSubmatch(value); m != nil && (m[1] != "" || value == "now") {
+		var offset time.Duration
+		for _, part := range relativePart.FindAllStringSubmatch(m[2], -1) {
+			n, err := strconv.ParseInt(part[1], 10, 64)
+			if err != nil || n > math.MaxInt64/int64(relativeUnits[part[2]]) {
+				return time.Time{}, ErrFormat
+			}
+			offset += time.Duration(n) * relativeUnits[part[2]]
+		}
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return now.Add(offset), nil
+	}
+
+	return time.Time{}, ErrFormat
+}
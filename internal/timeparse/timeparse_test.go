@@ -0,0 +1,53 @@
+package timeparse
+
+import (
+	"testing"
+	"time"
+)
+
+// Test 1: Absolute, epoch and relative time formats
+func TestParse(t *testing.T) {
+	now := time.Date(2025, 10, 24, 12, 0, 0, 0, time.UTC)
+	zagreb, err := time.LoadLocation("Europe/Zagreb")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		value    string
+		loc      *time.Location
+		expected time.Time
+	}{
+		{"2025-10-24T10:30:00Z", time.UTC, time.Date(2025, 10, 24, 10, 30, 0, 0, time.UTC)},
+		{"2025-10-24T10:30:00.5+02:00", time.UTC, time.Date(2025, 10, 24, 8, 30, 0, 5e8, time.UTC)},
+		{"2025-10-24T10:30:00 02:00", time.UTC, time.Date(2025, 10, 24, 8, 30, 0, 0, time.UTC)}, // Unescaped '+'
+		{"2025-10-24", time.UTC, time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)},
+		{"2025-10-24", zagreb, time.Date(2025, 10, 23, 22, 0, 0, 0, time.UTC)},
+		{"2025-10-24T10:30", zagreb, time.Date(2025, 10, 24, 8, 30, 0, 0, time.UTC)},
+		{"1761300000", time.UTC, time.Unix(1761300000, 0)},
+		{"1761300000500", time.UTC, time.Unix(1761300000, 5e8)},
+		{"now", time.UTC, now},
+		{"-15m", time.UTC, now.Add(-15 * time.Minute)},
+		{"-2h", time.UTC, now.Add(-2 * time.Hour)},
+		{"now-1d", time.UTC, now.Add(-24 * time.Hour)},
+		{"now-1d12h", time.UTC, now.Add(-36 * time.Hour)},
+		{"now 1w", time.UTC, now.Add(7 * 24 * time.Hour)}, // Unescaped '+'
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.value, now, tt.loc)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.value, tt.expected, got)
+		}
+	}
+
+	for _, value := range []string{"yesterday", "-15", "15m", "now-", "2025-13-01", "1e9", "-1y"} {
+		if _, err := Parse(value, now, time.UTC); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}